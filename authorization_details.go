@@ -0,0 +1,127 @@
+package openid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/alextanhongpin/go-openid/pkg/schema"
+)
+
+// AuthorizationDetail is an object of the authorization_details parameter of
+// Rich Authorization Requests (RFC 9396). Only the type field is common, the
+// other fields are defined by the type.
+type AuthorizationDetail map[string]interface{}
+
+// Type returns the type of the authorization detail.
+func (d AuthorizationDetail) Type() string {
+	t, _ := d["type"].(string)
+	return t
+}
+
+// ParseAuthorizationDetails parses the json array of the
+// authorization_details parameter. An empty parameter has no details.
+func ParseAuthorizationDetails(s string) ([]AuthorizationDetail, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var details []AuthorizationDetail
+	if err := json.Unmarshal([]byte(s), &details); err != nil {
+		return nil, errors.New("authorization_details must be a json array of objects")
+	}
+	return details, nil
+}
+
+// AuthorizationDetailType is a type of authorization details that the
+// clients can request, e.g. "payment_initiation".
+type AuthorizationDetailType struct {
+	Type string `json:"type"`
+
+	// Description is shown to the end-user on the consent page.
+	Description string `json:"description,omitempty"`
+
+	// Schema is the json schema that the authorization details of the
+	// type must conform to.
+	Schema json.RawMessage `json:"schema"`
+
+	validator schema.Validator
+}
+
+// AuthorizationDetailTypes holds the types of authorization details that the
+// clients can request.
+type AuthorizationDetailTypes struct {
+	mu    sync.RWMutex
+	types map[string]AuthorizationDetailType
+}
+
+// NewAuthorizationDetailTypes returns a new registry without types.
+func NewAuthorizationDetailTypes() *AuthorizationDetailTypes {
+	return &AuthorizationDetailTypes{
+		types: make(map[string]AuthorizationDetailType),
+	}
+}
+
+// DefaultAuthorizationDetailTypes is the registry that the types are
+// registered to when the server is assembled.
+var DefaultAuthorizationDetailTypes = NewAuthorizationDetailTypes()
+
+// Register adds the type to the registry. The schema is compiled once, when
+// the type is registered.
+func (r *AuthorizationDetailTypes) Register(t AuthorizationDetailType) error {
+	if t.Type == "" {
+		return errors.New("authorization details type is required")
+	}
+	if len(t.Schema) == 0 {
+		return fmt.Errorf("authorization details type %q has no schema", t.Type)
+	}
+	v, err := schema.NewJSONValidator(string(t.Schema))
+	if err != nil {
+		return fmt.Errorf("authorization details type %q has an invalid schema: %v", t.Type, err)
+	}
+	t.validator = v
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exist := r.types[t.Type]; exist {
+		return fmt.Errorf("authorization details type %q is already registered", t.Type)
+	}
+	r.types[t.Type] = t
+	return nil
+}
+
+// Lookup returns the type with the given name.
+func (r *AuthorizationDetailTypes) Lookup(name string) (AuthorizationDetailType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Names returns the sorted names of the registered types.
+func (r *AuthorizationDetailTypes) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.types))
+	for name := range r.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that the type of every authorization detail is registered,
+// and that the detail conforms to the schema of the type.
+func (r *AuthorizationDetailTypes) Validate(details []AuthorizationDetail) error {
+	for _, d := range details {
+		t, ok := r.Lookup(d.Type())
+		if !ok {
+			return ErrInvalidAuthorizationDetails.WithDescription(fmt.Sprintf("authorization details type %q is not supported", d.Type()))
+		}
+		if _, err := t.validator.Validate(map[string]interface{}(d)); err != nil {
+			return ErrInvalidAuthorizationDetails.WithDescription(fmt.Sprintf("authorization details of type %q are invalid: %v", d.Type(), err))
+		}
+	}
+	return nil
+}
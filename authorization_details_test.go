@@ -0,0 +1,65 @@
+package openid_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizationDetailTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	r := openid.NewAuthorizationDetailTypes()
+	payment := openid.AuthorizationDetailType{
+		Type:        "payment_initiation",
+		Description: "Make a payment",
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"required": ["instructedAmount", "creditorName"],
+			"properties": {
+				"instructedAmount": {
+					"type": "object",
+					"required": ["currency", "amount"],
+					"properties": {
+						"currency": {"type": "string"},
+						"amount": {"type": "string"}
+					}
+				},
+				"creditorName": {"type": "string"}
+			}
+		}`),
+	}
+	assert.Nil(r.Register(payment))
+	assert.NotNil(r.Register(payment), "should reject duplicate types")
+	assert.NotNil(r.Register(openid.AuthorizationDetailType{Type: "account_information"}), "should require a schema")
+	assert.NotNil(r.Register(openid.AuthorizationDetailType{Type: "account_information", Schema: json.RawMessage(`{`)}), "should reject invalid schemas")
+	assert.Equal([]string{"payment_initiation"}, r.Names())
+
+	details, err := openid.ParseAuthorizationDetails(`[{
+		"type": "payment_initiation",
+		"instructedAmount": {"currency": "EUR", "amount": "123.50"},
+		"creditorName": "Merchant A"
+	}]`)
+	assert.Nil(err)
+	assert.Equal("payment_initiation", details[0].Type())
+	assert.Nil(r.Validate(details))
+
+	details, err = openid.ParseAuthorizationDetails(`[{"type": "payment_initiation", "creditorName": "Merchant A"}]`)
+	assert.Nil(err)
+	err = r.Validate(details)
+	assert.Equal("invalid_authorization_details", err.(*openid.ErrorJSON).Code, "should validate against the schema")
+
+	details, err = openid.ParseAuthorizationDetails(`[{"type": "account_information"}]`)
+	assert.Nil(err)
+	err = r.Validate(details)
+	assert.Equal("invalid_authorization_details", err.(*openid.ErrorJSON).Code, "should reject unregistered types")
+
+	_, err = openid.ParseAuthorizationDetails(`{"type": "payment_initiation"}`)
+	assert.NotNil(err, "should require an array")
+
+	details, err = openid.ParseAuthorizationDetails("")
+	assert.Nil(err)
+	assert.Nil(details)
+}
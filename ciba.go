@@ -0,0 +1,103 @@
+package openid
+
+import "time"
+
+// Token delivery modes of Client-Initiated Backchannel Authentication.
+const (
+	CIBAPoll = "poll"
+	CIBAPing = "ping"
+	CIBAPush = "push"
+)
+
+// CIBADeliveryModes are the supported token delivery modes.
+var CIBADeliveryModes = []string{CIBAPoll, CIBAPing, CIBAPush}
+
+// IsValidCIBADeliveryMode returns true if the token delivery mode is
+// supported.
+func IsValidCIBADeliveryMode(mode string) bool {
+	for _, m := range CIBADeliveryModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// BackchannelAuthenticationRequest represents the request of the client to
+// the backchannel authentication endpoint.
+type BackchannelAuthenticationRequest struct {
+	Scope                   string `json:"scope,omitempty"`
+	ClientNotificationToken string `json:"client_notification_token,omitempty"`
+	ACRValues               string `json:"acr_values,omitempty"`
+	LoginHintToken          string `json:"login_hint_token,omitempty"`
+	IDTokenHint             string `json:"id_token_hint,omitempty"`
+	LoginHint               string `json:"login_hint,omitempty"`
+	BindingMessage          string `json:"binding_message,omitempty"`
+	RequestedExpiry         int64  `json:"requested_expiry,omitempty"`
+}
+
+// BackchannelAuthenticationResponse represents the acknowledgement of the
+// backchannel authentication request.
+type BackchannelAuthenticationResponse struct {
+	AuthReqID string `json:"auth_req_id"`
+	ExpiresIn int64  `json:"expires_in"`
+	Interval  int64  `json:"interval,omitempty"`
+}
+
+// Statuses of the backchannel authentication.
+const (
+	BackchannelPending  = "pending"
+	BackchannelApproved = "approved"
+	BackchannelDenied   = "denied"
+)
+
+// BackchannelAuthentication is the state of a backchannel authentication
+// request, until the client obtains the tokens.
+type BackchannelAuthentication struct {
+	// ID is the auth_req_id, which only the client knows.
+	ID string
+
+	// ApprovalID identifies the request to the authentication device of
+	// the end-user.
+	ApprovalID string
+
+	ClientID       string
+	UserID         string
+	Scope          string
+	ACRValues      string
+	BindingMessage string
+	Status         string
+
+	// DeliveryMode is the token delivery mode registered by the client.
+	// The ping and push modes notify the client at its endpoint with the
+	// bearer token it provided.
+	DeliveryMode               string
+	ClientNotificationEndpoint string
+	ClientNotificationToken    string
+
+	// Confirmation is the client certificate and the DPoP key that the
+	// client made a pushed request with. The pushed tokens are bound to
+	// them, since the client does not request them.
+	Confirmation *Confirmation
+
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	ApprovedAt time.Time
+	PolledAt   time.Time
+	Interval   time.Duration
+}
+
+// Expired returns true if the request can no longer be approved.
+func (b *BackchannelAuthentication) Expired() bool {
+	return time.Now().After(b.ExpiresAt)
+}
+
+// BackchannelNotification is sent to the client notification endpoint. In
+// the ping mode it only carries the auth_req_id, while in the push mode it
+// carries either the tokens or the error.
+type BackchannelNotification struct {
+	AuthReqID string `json:"auth_req_id"`
+	*AccessTokenResponse
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
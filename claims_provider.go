@@ -0,0 +1,157 @@
+package openid
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ClaimsProviderRequest describes the token or response that the claims are
+// provided for.
+type ClaimsProviderRequest struct {
+	User     *User
+	ClientID string
+
+	// Scopes are the scopes that were granted to the client.
+	Scopes []string
+
+	// Claims are the claims that were requested individually through the
+	// claims request parameter.
+	Claims []string
+}
+
+// ClaimsProvider provides custom claims that are not part of the User, such
+// as the groups of the user from a directory.
+type ClaimsProvider interface {
+	// Claims returns the names of the claims that the provider provides.
+	Claims() []string
+
+	// Provide returns the claims to release for the request. Claims that
+	// are not returned by Claims are ignored, and so are the claims that
+	// no granted scope releases unless they were requested individually.
+	Provide(ctx context.Context, req ClaimsProviderRequest) (map[string]interface{}, error)
+}
+
+// standardClaims are the claims of the tokens and responses that cannot be
+// provided by the claims providers.
+var standardClaims = map[string]bool{
+	// Registered and OpenID Connect token claims.
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true,
+	"iat": true, "jti": true, "azp": true, "nonce": true, "auth_time": true,
+	"at_hash": true, "c_hash": true, "acr": true, "amr": true, "sid": true,
+	"sub_jwk": true, "cnf": true, "scope": true, "client_id": true,
+	"active": true, "token_type": true, "events": true,
+
+	// Standard claims of the end-user.
+	"name": true, "given_name": true, "family_name": true, "middle_name": true,
+	"nickname": true, "preferred_username": true, "profile": true,
+	"picture": true, "website": true, "email": true, "email_verified": true,
+	"gender": true, "birthdate": true, "zoneinfo": true, "locale": true,
+	"phone_number": true, "phone_number_verified": true, "address": true,
+	"updated_at": true,
+}
+
+type registeredClaimsProvider struct {
+	provider ClaimsProvider
+	timeout  time.Duration
+	claims   map[string]bool
+}
+
+// ClaimsProviders holds the registered claims providers.
+type ClaimsProviders struct {
+	mu        sync.RWMutex
+	providers []registeredClaimsProvider
+	claims    map[string]bool
+}
+
+// NewClaimsProviders returns a new registry without providers.
+func NewClaimsProviders() *ClaimsProviders {
+	return &ClaimsProviders{
+		claims: make(map[string]bool),
+	}
+}
+
+// DefaultClaimsProviders is the registry that the providers are registered to
+// when the server is assembled.
+var DefaultClaimsProviders = NewClaimsProviders()
+
+// Register adds the provider, which is given at most the timeout to provide
+// the claims. Providers cannot provide the standard claims, or the claims of
+// another provider.
+func (c *ClaimsProviders) Register(p ClaimsProvider, timeout time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	claims := make(map[string]bool)
+	for _, name := range p.Claims() {
+		if standardClaims[name] {
+			return fmt.Errorf("claim %q is a standard claim", name)
+		}
+		if c.claims[name] {
+			return fmt.Errorf("claim %q is already provided", name)
+		}
+		claims[name] = true
+	}
+	for name := range claims {
+		c.claims[name] = true
+	}
+	c.providers = append(c.providers, registeredClaimsProvider{p, timeout, claims})
+	return nil
+}
+
+// Provide returns the claims of all the providers, which are called
+// concurrently. A provider that fails or times out is skipped, so that it
+// does not prevent the tokens from being issued.
+func (c *ClaimsProviders) Provide(ctx context.Context, req ClaimsProviderRequest) map[string]interface{} {
+	c.mu.RLock()
+	providers := c.providers
+	c.mu.RUnlock()
+
+	type result struct {
+		claims map[string]interface{}
+		err    error
+	}
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		claims = make(map[string]interface{})
+	)
+	for _, p := range providers {
+		wg.Add(1)
+		go func(p registeredClaimsProvider) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, p.timeout)
+			defer cancel()
+
+			ch := make(chan result, 1)
+			go func() {
+				res, err := p.provider.Provide(ctx, req)
+				ch <- result{res, err}
+			}()
+
+			var res result
+			select {
+			case res = <-ch:
+			case <-ctx.Done():
+				res.err = ctx.Err()
+			}
+			if res.err != nil {
+				log.Printf("claims provider: %v\n", res.err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for k, v := range res.claims {
+				if p.claims[k] {
+					claims[k] = v
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	return claims
+}
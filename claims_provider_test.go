@@ -0,0 +1,86 @@
+package openid_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/stretchr/testify/assert"
+)
+
+type claimsProvider struct {
+	claims  []string
+	values  map[string]interface{}
+	delay   time.Duration
+	err     error
+	request openid.ClaimsProviderRequest
+}
+
+func (p *claimsProvider) Claims() []string {
+	return p.claims
+}
+
+func (p *claimsProvider) Provide(ctx context.Context, req openid.ClaimsProviderRequest) (map[string]interface{}, error) {
+	p.request = req
+	time.Sleep(p.delay)
+	return p.values, p.err
+}
+
+func TestClaimsProviders(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("reject conflicting claims", func(t *testing.T) {
+		c := openid.NewClaimsProviders()
+		assert.NotNil(c.Register(&claimsProvider{claims: []string{"email"}}, time.Second), "should reject standard claims")
+		assert.Nil(c.Register(&claimsProvider{claims: []string{"groups"}}, time.Second))
+		assert.NotNil(c.Register(&claimsProvider{claims: []string{"groups"}}, time.Second), "should reject claims of other providers")
+	})
+
+	t.Run("provide declared claims", func(t *testing.T) {
+		c := openid.NewClaimsProviders()
+		groups := &claimsProvider{
+			claims: []string{"groups"},
+			values: map[string]interface{}{"groups": []string{"admin"}, "sub": "evil"},
+		}
+		tenant := &claimsProvider{
+			claims: []string{"tenant_id"},
+			values: map[string]interface{}{"tenant_id": "t1"},
+		}
+		assert.Nil(c.Register(groups, time.Second))
+		assert.Nil(c.Register(tenant, time.Second))
+
+		req := openid.ClaimsProviderRequest{Scopes: []string{"openid"}, Claims: []string{"groups"}}
+		claims := c.Provide(context.Background(), req)
+		assert.Equal(map[string]interface{}{"groups": []string{"admin"}, "tenant_id": "t1"}, claims, "should ignore undeclared claims")
+		assert.Equal(req, groups.request)
+	})
+
+	t.Run("skip failing and slow providers", func(t *testing.T) {
+		c := openid.NewClaimsProviders()
+		assert.Nil(c.Register(&claimsProvider{claims: []string{"a"}, err: errors.New("down")}, time.Second))
+		assert.Nil(c.Register(&claimsProvider{claims: []string{"b"}, values: map[string]interface{}{"b": 1}, delay: time.Second}, 10*time.Millisecond))
+		assert.Nil(c.Register(&claimsProvider{claims: []string{"c"}, values: map[string]interface{}{"c": 1}}, time.Second))
+
+		start := time.Now()
+		claims := c.Provide(context.Background(), openid.ClaimsProviderRequest{})
+		assert.True(time.Since(start) < 500*time.Millisecond, "should not wait for slow providers")
+		assert.Equal(map[string]interface{}{"c": 1}, claims)
+	})
+}
+
+func TestUserInfoClaims(t *testing.T) {
+	assert := assert.New(t)
+
+	info := openid.NewUserInfo("1", &openid.User{})
+	info.Claims = map[string]interface{}{"tenant_id": "t1", "sub": "2"}
+	b, err := json.Marshal(info)
+	assert.Nil(err)
+
+	var claims map[string]interface{}
+	assert.Nil(json.Unmarshal(b, &claims))
+	assert.Equal("1", claims["sub"], "should not override the standard claims")
+	assert.Equal("t1", claims["tenant_id"])
+}
@@ -0,0 +1,61 @@
+package openid
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// ClaimRequest represents the request for an individual claim.
+type ClaimRequest struct {
+	Essential bool          `json:"essential,omitempty"`
+	Value     interface{}   `json:"value,omitempty"`
+	Values    []interface{} `json:"values,omitempty"`
+}
+
+// ClaimsRequest represents the claims request parameter, which requests
+// individual claims to be returned from the userinfo endpoint or in the id
+// token.
+type ClaimsRequest struct {
+	UserInfo map[string]*ClaimRequest `json:"userinfo,omitempty"`
+	IDToken  map[string]*ClaimRequest `json:"id_token,omitempty"`
+}
+
+// ParseClaimsRequest parses the json claims request parameter. An empty
+// parameter returns an empty request.
+func ParseClaimsRequest(claims string) (*ClaimsRequest, error) {
+	var req ClaimsRequest
+	if claims == "" {
+		return &req, nil
+	}
+	if err := json.Unmarshal([]byte(claims), &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Names returns the sorted names of the claims requested for either the
+// userinfo endpoint or the id token.
+func (c *ClaimsRequest) Names() []string {
+	return claimNames(c.UserInfo, c.IDToken)
+}
+
+// IDTokenNames returns the sorted names of the claims requested for the id
+// token.
+func (c *ClaimsRequest) IDTokenNames() []string {
+	return claimNames(c.IDToken)
+}
+
+func claimNames(ms ...map[string]*ClaimRequest) []string {
+	seen := make(map[string]bool)
+	for _, m := range ms {
+		for k := range m {
+			seen[k] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
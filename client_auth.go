@@ -0,0 +1,50 @@
+package openid
+
+// Client authentication methods for the token endpoint.
+const (
+	ClientSecretBasic = "client_secret_basic"
+	ClientSecretPost  = "client_secret_post"
+	ClientSecretJWT   = "client_secret_jwt"
+	PrivateKeyJWT     = "private_key_jwt"
+	None              = "none"
+
+	// Mutual-TLS client authentication methods, see RFC 8705.
+	TLSClientAuth           = "tls_client_auth"
+	SelfSignedTLSClientAuth = "self_signed_tls_client_auth"
+)
+
+// ClientAssertionTypeJWTBearer is the client_assertion_type for the
+// client_secret_jwt and private_key_jwt authentication methods.
+const ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// TokenEndpointAuthMethods represents the supported client authentication
+// methods, with the default method first.
+var TokenEndpointAuthMethods = []string{
+	ClientSecretBasic,
+	ClientSecretPost,
+	ClientSecretJWT,
+	PrivateKeyJWT,
+	TLSClientAuth,
+	SelfSignedTLSClientAuth,
+	None,
+}
+
+// IsValidTokenEndpointAuthMethod returns true if the authentication method is
+// supported.
+func IsValidTokenEndpointAuthMethod(method string) bool {
+	for _, m := range TokenEndpointAuthMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientCredentials represents the client authentication parameters that are
+// sent in the request body.
+type ClientCredentials struct {
+	ClientID            string `json:"client_id,omitempty"`
+	ClientSecret        string `json:"client_secret,omitempty"`
+	ClientAssertionType string `json:"client_assertion_type,omitempty"`
+	ClientAssertion     string `json:"client_assertion,omitempty"`
+}
@@ -35,16 +35,17 @@ func main() {
 
 	getAuthorize := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		// Construct redirect url here.
-		// Based on the prompt type, if it is the popup, an iframe
-		// should be displayed to let the users login without being
-		// redirected. The question is, how to maintain the login
-		// token/session in the popup.
+		// For the popup display, the authorization server posts the
+		// authentication response back to this window through
+		// window.opener, and the page exchanges the code through the
+		// callback endpoint.
 		req := openid.AuthenticationRequest{
 			ResponseType: "code",
 			Scope:        "openid profile email",
 			ClientID:     cfg.ClientID,
 			State:        "abc",
 			RedirectURI:  "http://localhost:4000/authorize/callback",
+			Display:      r.URL.Query().Get("display"),
 
 			Prompt: "login",
 		}
@@ -1,6 +1,18 @@
 package main
 
-import "time"
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/alextanhongpin/go-openid/pkg/jwks"
+	"github.com/alextanhongpin/go-openid/pkg/randstr"
+)
 
 // TODO: Don't use global variable, scope it at the initialization in a Config
 // struct.
@@ -19,3 +31,198 @@ type Config struct {
 func NewConfig() *Config {
 	return &Config{}
 }
+
+// loadScopes registers the custom scopes defined in the json file, which
+// contains an array of scope definitions.
+func loadScopes(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var defs []openid.ScopeDefinition
+	if err := json.NewDecoder(f).Decode(&defs); err != nil {
+		return err
+	}
+	for _, def := range defs {
+		if err := openid.DefaultScopeRegistry.Register(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadResources registers the protected apis defined in the json file, which
+// contains an array of resources.
+func loadResources(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var resources []openid.Resource
+	if err := json.NewDecoder(f).Decode(&resources); err != nil {
+		return err
+	}
+	for _, res := range resources {
+		if err := openid.DefaultResourceRegistry.Register(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAuthorizationDetailTypes registers the authorization details types
+// defined in the json file, which contains an array of types with their json
+// schemas.
+func loadAuthorizationDetailTypes(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var types []openid.AuthorizationDetailType
+	if err := json.NewDecoder(f).Decode(&types); err != nil {
+		return err
+	}
+	for _, t := range types {
+		if err := openid.DefaultAuthorizationDetailTypes.Register(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTokenExchangePolicies registers the token exchange policies defined in
+// the json file, which contains an array of policies.
+func loadTokenExchangePolicies(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var policies []openid.TokenExchangePolicy
+	if err := json.NewDecoder(f).Decode(&policies); err != nil {
+		return err
+	}
+	for _, p := range policies {
+		if err := openid.DefaultTokenExchangePolicies.Register(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTrustedIssuers registers the issuers defined in the json file, which
+// contains an array of trusted issuers.
+func loadTrustedIssuers(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var issuers []openid.TrustedIssuer
+	if err := json.NewDecoder(f).Decode(&issuers); err != nil {
+		return err
+	}
+	for _, issuer := range issuers {
+		if err := openid.DefaultTrustedIssuers.Register(issuer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSigningKey adds the pem encoded key as the signing key of the access
+// tokens.
+func loadSigningKey(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key, err := jwks.ParsePEM(b)
+	if err != nil {
+		return err
+	}
+	jwks.DefaultKeySet.Add(key)
+	return nil
+}
+
+// loadDecryptionKey returns the pem encoded rsa key that decrypts the
+// id_token_hint that the clients encrypted for the provider.
+func loadDecryptionKey(path string) (*rsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwks.ParsePEM(b)
+	if err != nil {
+		return nil, err
+	}
+	return key.PrivateKey, nil
+}
+
+// loadClientCAs returns the pool of the pem encoded certificate authorities
+// that issue the client certificates for tls_client_auth.
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// loadPairwiseSalt returns the salt of the pairwise subject identifiers that
+// is stored in the file. The salt is generated and stored on the first start,
+// since changing it changes the subject of every user of the pairwise clients.
+func loadPairwiseSalt(path string) ([]byte, error) {
+	salt, err := ioutil.ReadFile(path)
+	if err == nil {
+		if len(salt) == 0 {
+			return nil, fmt.Errorf("pairwise salt %s is empty", path)
+		}
+		return salt, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	salt, err = randstr.RandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, salt, 0600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// claimsProvider is a provider of custom claims, such as groups or
+// tenant_id, with the time it is given to provide them.
+type claimsProvider struct {
+	provider openid.ClaimsProvider
+	timeout  time.Duration
+}
+
+// claimsProviders are the claims providers of the deployment. Providers are
+// added here, or from an init function in this package.
+var claimsProviders []claimsProvider
+
+// registerClaimsProviders registers the claims providers, and fails when a
+// provider conflicts with the standard claims or another provider.
+func registerClaimsProviders() error {
+	for _, p := range claimsProviders {
+		if err := openid.DefaultClaimsProviders.Register(p.provider, p.timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"crypto/x509"
 	"flag"
+	"fmt"
 	"log"
 
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/controller"
 	"github.com/alextanhongpin/go-openid/internal/client"
+	"github.com/alextanhongpin/go-openid/internal/core"
 	"github.com/alextanhongpin/go-openid/middleware"
 	"github.com/alextanhongpin/go-openid/pkg/appsensor"
+	"github.com/alextanhongpin/go-openid/pkg/backchannel"
 	"github.com/alextanhongpin/go-openid/pkg/gsrv"
 	"github.com/alextanhongpin/go-openid/pkg/html5"
+	"github.com/alextanhongpin/go-openid/pkg/schema"
 	"github.com/alextanhongpin/go-openid/pkg/session"
 )
 
@@ -19,24 +25,114 @@ func main() {
 	var (
 		port   = flag.Int("port", 8080, "the port of the application")
 		tplDir = flag.String("tpldir", "templates", "the datadir of the html templates")
+
+		tlsCert = flag.String("tls-cert", "", "the tls certificate, enables https when set")
+		tlsKey  = flag.String("tls-key", "", "the tls private key")
+		caFile  = flag.String("client-ca", "", "the pem encoded certificate authorities of the client certificates for tls_client_auth")
+
+		mtlsPort = flag.Int("mtls-port", 8443, "the port of the mutual-tls endpoint aliases, served when the tls certificate is set")
+
+		outboxDir  = flag.String("outbox", "outbox", "the directory of the undelivered back-channel logout tokens")
+		scopes     = flag.String("scopes", "", "the json file of the custom scope definitions")
+		resources  = flag.String("resources", "", "the json file of the protected apis that access tokens are issued for")
+		signingKey = flag.String("signing-key", "", "the pem encoded rsa key that signs the access tokens, generated when not set")
+		decryptKey = flag.String("decryption-key", "", "the pem encoded rsa key that decrypts the id_token_hint encrypted by the clients")
+		exchange   = flag.String("token-exchange", "", "the json file of the token exchange policies of the clients")
+		issuers    = flag.String("trusted-issuers", "", "the json file of the issuers trusted by the jwt bearer grant")
+		details    = flag.String("authorization-details", "", "the json file of the authorization details types and their schemas")
+		saltFile   = flag.String("pairwise-salt", "pairwise.salt", "the file of the salt of the pairwise subject identifiers, generated when it does not exist")
 	)
 	flag.Parse()
 
+	// Custom scopes must be registered before the endpoints are created.
+	if *scopes != "" {
+		if err := loadScopes(*scopes); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *resources != "" {
+		if err := loadResources(*resources); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *signingKey != "" {
+		if err := loadSigningKey(*signingKey); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *exchange != "" {
+		if err := loadTokenExchangePolicies(*exchange); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *issuers != "" {
+		if err := loadTrustedIssuers(*issuers); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *details != "" {
+		if err := loadAuthorizationDetailTypes(*details); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := registerClaimsProviders(); err != nil {
+		log.Fatal(err)
+	}
+	salt, err := loadPairwiseSalt(*saltFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The key is only set when configured, since the model checks for a
+	// nil interface.
+	var decryptionKey interface{}
+	if *decryptKey != "" {
+		key, err := loadDecryptionKey(*decryptKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		decryptionKey = key
+	}
+	var clientCAs *x509.CertPool
+	if *caFile != "" {
+		if clientCAs, err = loadClientCAs(*caFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// Create new router.
 	r := httprouter.New()
 
 	// Load templates.
-	tpl := html5.New(*tplDir)
-	tpl.Load("login", "register", "client-register", "consent", "index")
+	tpl := html5.New(*tplDir,
+		html5.Locales("en", "ja"),
+		html5.Displays("popup", "touch", "wap"),
+	)
+	tpl.Load("login", "register", "client-register", "consent", "end-session", "frontchannel-logout", "check-session", "index", "popup-callback", "backchannel-approval", "error", "form-post")
 
 	sessMgr := session.NewManager()
 	sessMgr.Start()
 	defer sessMgr.Stop()
 
+	// Logout tokens are kept in the outbox until delivered, so that they
+	// survive restarts.
+	outbox, err := backchannel.NewFileOutbox(*outboxDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dispatcher := backchannel.NewDispatcher(backchannel.WithOutbox(outbox))
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
 	// TODO: Run a cron job that handles deletion of unused data for a
 	// certain period of time.
 	aps := appsensor.NewLoginDetector()
 
+	m := core.NewModel(
+		core.ModelPairwiseSalt(salt),
+		core.ModelClientCAs(clientCAs),
+		core.ModelDecryptionKey(decryptionKey),
+	)
+
 	// -- endpoints
 	{
 		c := controller.NewIndex(
@@ -51,7 +147,6 @@ func main() {
 			controller.UserAppSensor(aps),
 			controller.UserTemplate(tpl),
 		)
-		r.POST("/logout", c.PostLogout)
 		// Middleware is good for extracting business logic - but it
 		// masks out business logic for testing too.
 		r.GET("/register", middleware.RedirectIfSessionExists(c.GetRegister, sessMgr, "/"))
@@ -64,23 +159,70 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+		// The grants of a deleted client are revoked.
+		s.AddClientRevoker(&m)
+		s.AddClientRevoker(sessMgr)
+		v, err := schema.NewClientValidator()
+		if err != nil {
+			log.Fatal(err)
+		}
 		c := controller.NewClient(
 			controller.ClientTemplate(tpl),
 			controller.ClientService(s),
+			controller.ClientValidator(v),
 		)
 		r.GET("/connect/register", c.GetClientRegister)
 		r.POST("/connect/register", c.PostClientRegister)
+		r.GET("/connect/register/:client_id", c.GetClient)
+		r.PUT("/connect/register/:client_id", c.PutClient)
+		r.DELETE("/connect/register/:client_id", c.DeleteClient)
 	}
 	{
+		s := core.NewService(&m)
 		c := controller.NewCore(
+			controller.CoreService(&s),
 			controller.CoreSession(sessMgr),
 			controller.CoreTemplate(tpl),
+			controller.CoreBackChannel(dispatcher),
 		)
 		r.GET("/authorize", c.GetAuthorize)
 		r.POST("/authorize", c.PostAuthorize)
 		r.POST("/token", c.PostToken)
+		r.GET("/userinfo", c.GetUserInfo)
+		r.POST("/userinfo", c.GetUserInfo)
+		r.POST("/introspect", c.PostIntrospect)
+		r.POST("/revoke", c.PostRevoke)
+		r.POST("/bc-authorize", c.PostBackchannelAuthorize)
+		r.GET("/bc-approve", c.GetBackchannelApproval)
+		r.POST("/bc-approve", c.PostBackchannelApproval)
+		r.GET("/check_session", c.GetCheckSession)
+		r.GET("/end_session", c.GetEndSession)
+		r.POST("/end_session", c.GetEndSession)
+		r.POST("/logout", c.PostLogout)
+
+		// The clients of the expired sessions are notified through
+		// the back-channel.
+		sessMgr.OnExpire(c.ExpireSession)
+	}
+	{
+		metadata := openid.NewProviderMetadata("http://localhost:8080")
+		if *tlsCert != "" {
+			metadata.SetMTLSEndpointAliases(fmt.Sprintf("https://localhost:%d", *mtlsPort))
+		}
+		c := controller.NewDiscovery(controller.DiscoveryMetadata(metadata))
+		r.GET("/.well-known/openid-configuration", c.GetOpenIDConfiguration)
+		r.GET("/jwks.json", c.GetJWKS)
+	}
+	var srv <-chan struct{}
+	if *tlsCert != "" {
+		// Client certificates are only requested by the mutual-TLS
+		// endpoint aliases, so that the browsers are never asked for
+		// a certificate.
+		go gsrv.NewTLS(*mtlsPort, r, *tlsCert, *tlsKey, gsrv.RequestClientCert())
+		srv = gsrv.NewTLS(*port, r, *tlsCert, *tlsKey)
+	} else {
+		srv = gsrv.New(*port, r)
 	}
-	srv := gsrv.New(*port, r)
 	<-srv
 	log.Println("Gracefully shutdown HTTP server.")
 }
@@ -0,0 +1,52 @@
+package openid
+
+import "time"
+
+// CodeTTL represents the time-to-live for the authorization code.
+const CodeTTL = 10 * time.Minute
+
+// Code represents the authorization code, together with the parts of the
+// authentication request that are required at the token endpoint.
+type Code struct {
+	Code          string
+	CreatedAt     time.Time
+	TTL           time.Duration
+	ClientID      string
+	ClaimsLocales string
+
+	// Scope is the scope that was granted to the client.
+	Scope string
+
+	// SessionID is the sid of the session in which the code was issued,
+	// which is included in the id token.
+	SessionID string
+
+	// Claims is the claims request parameter of the authentication request.
+	Claims string
+
+	// Resource are the resource indicators that the client was granted.
+	Resource []string
+
+	// AuthorizationDetails are the fine-grained permissions that the
+	// end-user granted to the client.
+	AuthorizationDetails []AuthorizationDetail
+
+	// AuthTime and ACR describe the authentication of the end-user, and
+	// are included in the access tokens.
+	AuthTime time.Time
+	ACR      string
+}
+
+// NewCode returns a new code with the default TTL.
+func NewCode(code string) *Code {
+	return &Code{
+		Code:      code,
+		CreatedAt: time.Now().UTC(),
+		TTL:       CodeTTL,
+	}
+}
+
+// Expired returns if the code has reached pass the expiration limit.
+func (c *Code) Expired() bool {
+	return time.Since(c.CreatedAt) > c.TTL
+}
@@ -0,0 +1,76 @@
+package openid
+
+import "time"
+
+// Consent represents the scopes and claims that the end-user granted to the
+// client.
+type Consent struct {
+	UserID    string
+	ClientID  string
+	Scopes    []string
+	Claims    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NewConsent returns a new consent without any grants.
+func NewConsent(userID, clientID string) *Consent {
+	now := time.Now().UTC()
+	return &Consent{
+		UserID:    userID,
+		ClientID:  clientID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Missing returns the scopes and claims that have not been granted yet.
+func (c *Consent) Missing(scopes, claims []string) ([]string, []string) {
+	return difference(scopes, c.Scopes), difference(claims, c.Claims)
+}
+
+// Grant adds the scopes and claims to the previous grants.
+func (c *Consent) Grant(scopes, claims []string) {
+	c.Scopes = append(c.Scopes, difference(scopes, c.Scopes)...)
+	c.Claims = append(c.Claims, difference(claims, c.Claims)...)
+	c.UpdatedAt = time.Now().UTC()
+}
+
+// ConsentPrompt represents what the end-user is asked to consent to.
+type ConsentPrompt struct {
+	ClientName string
+
+	// Scopes and Claims are requested, but not granted yet.
+	Scopes []string
+	Claims []string
+
+	// GrantedScopes are granted in a previous consent.
+	GrantedScopes []string
+
+	// ConsentText contains the registered consent text of the scopes.
+	ConsentText map[string]string
+
+	// AuthorizationDetails are the fine-grained permissions of the
+	// request, which are asked for every time.
+	AuthorizationDetails []AuthorizationDetail
+
+	// AuthorizationDetailText contains the registered description of the
+	// authorization details types.
+	AuthorizationDetailText map[string]string
+}
+
+// difference returns the unique values of a that are not in b.
+func difference(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, v := range b {
+		seen[v] = true
+	}
+	var res []string
+	for _, v := range a {
+		if !seen[v] {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,39 @@
+package openid_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsent(t *testing.T) {
+	assert := assert.New(t)
+
+	consent := openid.NewConsent("1", "app")
+	scopes, claims := consent.Missing([]string{"openid", "email"}, []string{"email"})
+	assert.Equal([]string{"openid", "email"}, scopes)
+	assert.Equal([]string{"email"}, claims)
+
+	consent.Grant([]string{"openid", "email", "email"}, []string{"email"})
+	assert.Equal([]string{"openid", "email"}, consent.Scopes, "should not duplicate grants")
+
+	scopes, claims = consent.Missing([]string{"openid"}, nil)
+	assert.Empty(scopes, "should cover the granted subset")
+	assert.Empty(claims)
+
+	scopes, _ = consent.Missing([]string{"openid", "profile"}, nil)
+	assert.Equal([]string{"profile"}, scopes, "should only return new scopes")
+}
+
+func TestClaimsRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	req, err := openid.ParseClaimsRequest(`{"userinfo":{"email":null,"name":{"essential":true}},"id_token":{"email":{"essential":true}}}`)
+	assert.Nil(err)
+	assert.Equal([]string{"email", "name"}, req.Names())
+	assert.True(req.UserInfo["name"].Essential)
+
+	_, err = openid.ParseClaimsRequest("{")
+	assert.NotNil(err)
+}
@@ -1,6 +1,10 @@
 package openid
 
-import "context"
+import (
+	"context"
+	"crypto/x509"
+	"time"
+)
 
 type ContextKey string
 
@@ -9,8 +13,14 @@ func (c ContextKey) String() string {
 }
 
 var (
-	UserIDContextKey = ContextKey("user_id")
-	AuthContextKey   = ContextKey("authorization")
+	UserIDContextKey       = ContextKey("user_id")
+	AuthContextKey         = ContextKey("authorization")
+	CertContextKey         = ContextKey("client_certificate")
+	CertChainContextKey    = ContextKey("client_certificate_chain")
+	DPoPContextKey         = ContextKey("dpop_jkt")
+	SessionContextKey      = ContextKey("sid")
+	BrowserStateContextKey = ContextKey("browser_state")
+	AuthTimeContextKey     = ContextKey("auth_time")
 )
 
 func SetUserIDContextKey(ctx context.Context, userID string) context.Context {
@@ -30,3 +40,75 @@ func GetAuthContextKey(ctx context.Context) (string, bool) {
 	auth, ok := ctx.Value(AuthContextKey).(string)
 	return auth, ok
 }
+
+// SetCertContextKey sets the TLS client certificate presented with the
+// request.
+func SetCertContextKey(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, CertContextKey, cert)
+}
+
+// GetCertContextKey returns the TLS client certificate of the request.
+func GetCertContextKey(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(CertContextKey).(*x509.Certificate)
+	return cert, ok && cert != nil
+}
+
+// SetCertChainContextKey sets the intermediate certificates that were
+// presented with the client certificate.
+func SetCertChainContextKey(ctx context.Context, intermediates []*x509.Certificate) context.Context {
+	return context.WithValue(ctx, CertChainContextKey, intermediates)
+}
+
+// GetCertChainContextKey returns the intermediate certificates of the request.
+func GetCertChainContextKey(ctx context.Context) []*x509.Certificate {
+	intermediates, _ := ctx.Value(CertChainContextKey).([]*x509.Certificate)
+	return intermediates
+}
+
+// SetDPoPContextKey sets the JWK thumbprint of the validated DPoP proof of the
+// request.
+func SetDPoPContextKey(ctx context.Context, jkt string) context.Context {
+	return context.WithValue(ctx, DPoPContextKey, jkt)
+}
+
+// GetDPoPContextKey returns the JWK thumbprint of the DPoP proof.
+func GetDPoPContextKey(ctx context.Context) (string, bool) {
+	jkt, ok := ctx.Value(DPoPContextKey).(string)
+	return jkt, ok && jkt != ""
+}
+
+// SetSessionContextKey sets the sid of the end-user's session with the
+// provider.
+func SetSessionContextKey(ctx context.Context, sid string) context.Context {
+	return context.WithValue(ctx, SessionContextKey, sid)
+}
+
+// GetSessionContextKey returns the sid of the end-user's session.
+func GetSessionContextKey(ctx context.Context) (string, bool) {
+	sid, ok := ctx.Value(SessionContextKey).(string)
+	return sid, ok && sid != ""
+}
+
+// SetBrowserStateContextKey sets the browser state of the end-user's session,
+// which is used to compute the session_state.
+func SetBrowserStateContextKey(ctx context.Context, browserState string) context.Context {
+	return context.WithValue(ctx, BrowserStateContextKey, browserState)
+}
+
+// GetBrowserStateContextKey returns the browser state of the end-user's
+// session.
+func GetBrowserStateContextKey(ctx context.Context) (string, bool) {
+	state, ok := ctx.Value(BrowserStateContextKey).(string)
+	return state, ok && state != ""
+}
+
+// SetAuthTimeContextKey sets the time when the end-user authenticated.
+func SetAuthTimeContextKey(ctx context.Context, authTime time.Time) context.Context {
+	return context.WithValue(ctx, AuthTimeContextKey, authTime)
+}
+
+// GetAuthTimeContextKey returns the time when the end-user authenticated.
+func GetAuthTimeContextKey(ctx context.Context) (time.Time, bool) {
+	authTime, ok := ctx.Value(AuthTimeContextKey).(time.Time)
+	return authTime, ok && !authTime.IsZero()
+}
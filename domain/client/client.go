@@ -1,5 +1,11 @@
 package client
 
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+)
+
 // // ClientToken represents the access token that is provided to the client
 // // during registration.
 // type ClientToken struct {
@@ -9,42 +15,58 @@ package client
 
 // Client represents the openid Client Metadata.
 type Client struct {
-	ApplicationType              string   `json:"application_type,omitempty"`
-	ClientName                   string   `json:"client_name,omitempty"`
-	ClientURI                    string   `json:"client_uri,omitempty"`
-	Contacts                     []string `json:"contacts,omitempty"`
-	DefaultAcrValues             string   `json:"default_acr_values,omitempty"`
-	DefaultMaxAge                int64    `json:"default_maxa_age,omitempty"`
-	GrantTypes                   []string `json:"grant_types,omitempty"`
-	IDTokenEncryptedResponseAlg  string   `json:"id_token_encrypted_response_alg,omitempty"`
-	IDTokenEncryptedResponseEnc  string   `json:"id_token_encryption_response_enc,omitempty"`
-	IDTokenSignedResponseAlg     string   `json:"id_token_signed_response_alg,omitempty"`
-	InitiateLoginURI             string   `json:"initiate_login_uri,omitempty"`
-	Jwks                         string   `json:"jwks,omitempty"`
-	JwksURI                      string   `json:"jwks_uri,omitempty"`
-	LogoURI                      string   `json:"logo_uri,omitempty"`
-	PolicyURI                    string   `json:"policy_uri,omitempty"`
-	RedirectURIs                 []string `json:"redirect_uris,omitempty"`
-	RequestObjectEncryptionAlg   string   `json:"request_object_encryption_alg,omitempty"`
-	RequestObjectEncryptionEnc   string   `json:"request_object_encryption_enc,omitempty"`
-	RequestObjectSigningAlg      string   `json:"request_object_signing_alg,omitempty"`
-	RequestURIs                  []string `json:"request_uris,omitempty"`
-	RequireAuthTime              int64    `json:"require_auth_time,omitempty"`
-	ResponseTypes                []string `json:"response_types,omitempty"`
-	SectorIdentifierURI          string   `json:"sector_identifier_uri,omitempty"`
-	SubjectType                  string   `json:"subject_type,omitempty"`
-	TokenEndpointAuthMethod      string   `json:"token_endpoint_auth_method,omitempty"`
-	TokenEndpointAuthSigningAlg  string   `json:"token_endpoint_auth_signing_alg,omitempty"`
-	TosURI                       string   `json:"tos_uri,omitempty"`
-	UserinfoEncryptedResponseAlg string   `json:"userinfo_encrypted_response_alg,omitempty"`
-	UserinfoEncryptedResponseEnc string   `json:"userinfo_encrypted_response_enc,omitempty"`
-	UserinfoSignedResponseAlg    string   `json:"userinfo_signed_response_alg,omitempty"`
-	ClientID                     string   `json:"client_id,omitempty"`
-	ClientIDIssuedAt             int64    `json:"client_id_issued_at,omitempty"`
-	ClientSecret                 string   `json:"client_secret,omitempty"`
-	ClientSecretExpiresAt        int64    `json:"client_secret_expires_at,omitempty"`
-	RegistrationAccessToken      string   `json:"registration_access_token,omitempty"`
-	RegistrationClientURI        string   `json:"registration_client_uri,omitempty"`
+	ApplicationType                   string   `json:"application_type,omitempty"`
+	ClientName                        string   `json:"client_name,omitempty"`
+	ClientURI                         string   `json:"client_uri,omitempty"`
+	Contacts                          []string `json:"contacts,omitempty"`
+	DefaultAcrValues                  string   `json:"default_acr_values,omitempty"`
+	DefaultMaxAge                     int64    `json:"default_max_age,omitempty"`
+	GrantTypes                        []string `json:"grant_types,omitempty"`
+	IDTokenEncryptedResponseAlg       string   `json:"id_token_encrypted_response_alg,omitempty"`
+	IDTokenEncryptedResponseEnc       string   `json:"id_token_encrypted_response_enc,omitempty"`
+	IDTokenSignedResponseAlg          string   `json:"id_token_signed_response_alg,omitempty"`
+	InitiateLoginURI                  string   `json:"initiate_login_uri,omitempty"`
+	Jwks                              string   `json:"jwks,omitempty"`
+	JwksURI                           string   `json:"jwks_uri,omitempty"`
+	LogoURI                           string   `json:"logo_uri,omitempty"`
+	PolicyURI                         string   `json:"policy_uri,omitempty"`
+	PostLogoutRedirectURIs            []string `json:"post_logout_redirect_uris,omitempty"`
+	RedirectURIs                      []string `json:"redirect_uris,omitempty"`
+	RequestObjectEncryptionAlg        string   `json:"request_object_encryption_alg,omitempty"`
+	RequestObjectEncryptionEnc        string   `json:"request_object_encryption_enc,omitempty"`
+	RequestObjectSigningAlg           string   `json:"request_object_signing_alg,omitempty"`
+	RequestURIs                       []string `json:"request_uris,omitempty"`
+	RequireAuthTime                   bool     `json:"require_auth_time,omitempty"`
+	ResponseTypes                     []string `json:"response_types,omitempty"`
+	SectorIdentifierURI               string   `json:"sector_identifier_uri,omitempty"`
+	SubjectType                       string   `json:"subject_type,omitempty"`
+	TokenEndpointAuthMethod           string   `json:"token_endpoint_auth_method,omitempty"`
+	TokenEndpointAuthSigningAlg       string   `json:"token_endpoint_auth_signing_alg,omitempty"`
+	TLSClientAuthSubjectDN            string   `json:"tls_client_auth_subject_dn,omitempty"`
+	TLSClientAuthSANDNS               string   `json:"tls_client_auth_san_dns,omitempty"`
+	TLSClientAuthSANURI               string   `json:"tls_client_auth_san_uri,omitempty"`
+	TLSClientAuthSANIP                string   `json:"tls_client_auth_san_ip,omitempty"`
+	TLSClientAuthSANEmail             string   `json:"tls_client_auth_san_email,omitempty"`
+	TLSClientCertBoundTokens          bool     `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	DPoPBoundAccessTokens             bool     `json:"dpop_bound_access_tokens,omitempty"`
+	FrontchannelLogoutURI             string   `json:"frontchannel_logout_uri,omitempty"`
+	FrontchannelLogoutSessionRequired bool     `json:"frontchannel_logout_session_required,omitempty"`
+	BackchannelLogoutURI              string   `json:"backchannel_logout_uri,omitempty"`
+	BackchannelLogoutSessionRequired  bool     `json:"backchannel_logout_session_required,omitempty"`
+	TosURI                            string   `json:"tos_uri,omitempty"`
+	UserinfoEncryptedResponseAlg      string   `json:"userinfo_encrypted_response_alg,omitempty"`
+	UserinfoEncryptedResponseEnc      string   `json:"userinfo_encrypted_response_enc,omitempty"`
+	UserinfoSignedResponseAlg         string   `json:"userinfo_signed_response_alg,omitempty"`
+	ClientID                          string   `json:"client_id,omitempty"`
+	ClientIDIssuedAt                  int64    `json:"client_id_issued_at,omitempty"`
+	ClientSecret                      string   `json:"client_secret,omitempty"`
+	ClientSecretExpiresAt             int64    `json:"client_secret_expires_at,omitempty"`
+	RegistrationAccessToken           string   `json:"registration_access_token,omitempty"`
+	RegistrationClientURI             string   `json:"registration_client_uri,omitempty"`
+
+	// Client-Initiated Backchannel Authentication.
+	BackchannelTokenDeliveryMode          string `json:"backchannel_token_delivery_mode,omitempty"`
+	BackchannelClientNotificationEndpoint string `json:"backchannel_client_notification_endpoint,omitempty"`
 }
 
 // NewClient returns a new client with default values.
@@ -54,10 +76,46 @@ func NewClient() *Client {
 		GrantTypes:                   []string{"authorization_code"},
 		RequestObjectEncryptionEnc:   "A128CBC-HS256",
 		ResponseTypes:                []string{"code"},
+		SubjectType:                  "public",
+		TokenEndpointAuthMethod:      "client_secret_basic",
 		UserinfoEncryptedResponseEnc: "A128CBC-HS256",
 	}
 }
 
+// UnmarshalJSON decodes the client metadata. Clients that were stored before
+// the metadata followed the registration spec are still decoded, with
+// default_maxa_age, id_token_encryption_response_enc and a numeric
+// require_auth_time.
+func (c *Client) UnmarshalJSON(b []byte) error {
+	type client Client
+	aux := struct {
+		*client
+		RequireAuthTime interface{} `json:"require_auth_time,omitempty"`
+
+		LegacyDefaultMaxAge               int64  `json:"default_maxa_age,omitempty"`
+		LegacyIDTokenEncryptedResponseEnc string `json:"id_token_encryption_response_enc,omitempty"`
+	}{client: (*client)(c)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	switch v := aux.RequireAuthTime.(type) {
+	case nil:
+	case bool:
+		c.RequireAuthTime = v
+	case float64:
+		c.RequireAuthTime = v != 0
+	default:
+		return errors.New("require_auth_time must be a boolean")
+	}
+	if c.DefaultMaxAge == 0 {
+		c.DefaultMaxAge = aux.LegacyDefaultMaxAge
+	}
+	if c.IDTokenEncryptedResponseEnc == "" {
+		c.IDTokenEncryptedResponseEnc = aux.LegacyIDTokenEncryptedResponseEnc
+	}
+	return nil
+}
+
 // GetRedirectURIs returns the redirect_uris as a type.
 func (c *Client) GetRedirectURIs() RedirectURIs {
 	return RedirectURIs(c.RedirectURIs)
@@ -69,3 +127,48 @@ func (c *Client) Clone() *Client {
 	*copy = *c
 	return copy
 }
+
+// IsPostLogoutRedirectURI returns true if the uri is one of the registered
+// post_logout_redirect_uris. The uri must match exactly.
+func (c *Client) IsPostLogoutRedirectURI(uri string) bool {
+	for _, u := range c.PostLogoutRedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPairwise returns true if the client requested pairwise subject
+// identifiers.
+func (c *Client) IsPairwise() bool {
+	return c.SubjectType == "pairwise"
+}
+
+// SectorIdentifier returns the host used to derive pairwise subject
+// identifiers. The host of the sector_identifier_uri is used if provided,
+// otherwise all redirect_uris must share the same host.
+func (c *Client) SectorIdentifier() (string, error) {
+	if c.SectorIdentifierURI != "" {
+		u, err := url.Parse(c.SectorIdentifierURI)
+		if err != nil {
+			return "", err
+		}
+		return u.Host, nil
+	}
+	var host string
+	for _, uri := range c.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return "", err
+		}
+		if host != "" && host != u.Host {
+			return "", errors.New("sector_identifier_uri is required when redirect_uris have multiple hosts")
+		}
+		host = u.Host
+	}
+	if host == "" {
+		return "", errors.New("redirect_uris is required")
+	}
+	return host, nil
+}
@@ -5,4 +5,7 @@ import openid "github.com/alextanhongpin/go-openid"
 type Repository interface {
 	Create(client openid.Client) (string, error)
 	WithCredentials(clientID, clientSecret string) (*openid.Client, error)
+	Get(clientID string) (*openid.Client, error)
+	Update(client openid.Client) error
+	Delete(clientID string) error
 }
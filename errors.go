@@ -2,6 +2,11 @@ package openid
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alextanhongpin/go-openid/pkg/querystring"
 )
 
 const (
@@ -12,6 +17,16 @@ const (
 	TemporarilyUnavailable  = "temporarily_unavailable"
 	UnauthorizedClient      = "unauthorized_client"
 	UnsupportedResponseType = "unsupported_response_type"
+
+	AccountSelectionRequired = "account_selection_required"
+	ConsentRequired          = "consent_required"
+	InteractionRequired      = "interaction_required"
+	LoginRequired            = "login_required"
+
+	InvalidClient        = "invalid_client"
+	InvalidGrant         = "invalid_grant"
+	InvalidToken         = "invalid_token"
+	UnsupportedGrantType = "unsupported_grant_type"
 )
 
 // Authorization errors
@@ -33,6 +48,57 @@ var errorCodeDescriptions = map[string]string{
 	TemporarilyUnavailable:  "the authorization server is unable to handle the request due to a temporary overloading or maintenance of the server",
 	UnauthorizedClient:      "the client is not authorized to request an authorization code using this method",
 	UnsupportedResponseType: "the authorization server does not support obtaining an authorization code using this method",
+
+	AccountSelectionRequired: "the end-user is required to select a session at the authorization server",
+	ConsentRequired:          "the authorization server requires end-user consent",
+	InteractionRequired:      "the authorization server requires end-user interaction of some form to proceed",
+	LoginRequired:            "the authorization server requires end-user authentication",
+
+	InvalidClient:        "client authentication failed",
+	InvalidGrant:         "the authorization grant or refresh token is invalid, expired, revoked, does not match the redirect uri, or was issued to another client",
+	InvalidToken:         "the access token is expired, revoked, malformed, or invalid for other reasons",
+	UnsupportedGrantType: "the authorization grant type is not supported by the authorization server",
+}
+
+// errorCodeStatuses contains the http status of the error codes that are
+// not returned with 400 Bad Request.
+var errorCodeStatuses = map[string]int{
+	InvalidClient:          http.StatusUnauthorized,
+	InvalidToken:           http.StatusUnauthorized,
+	ServerError:            http.StatusInternalServerError,
+	TemporarilyUnavailable: http.StatusServiceUnavailable,
+}
+
+// Authentication errors
+var (
+	ErrAccountSelectionRequired = NewError(AccountSelectionRequired)
+	ErrConsentRequired          = NewError(ConsentRequired)
+	ErrInteractionRequired      = NewError(InteractionRequired)
+	ErrLoginRequired            = NewError(LoginRequired)
+)
+
+// localizedErrorCodeDescriptions contains the error descriptions for locales
+// other than English, keyed by the primary language subtag.
+var localizedErrorCodeDescriptions = map[string]map[string]string{
+	"ja": {
+		AccessDenied:            "リソースオーナーまたは認可サーバーがリクエストを拒否しました",
+		InvalidRequest:          "リクエストに必須パラメーターが含まれていないか、無効なパラメーター値が含まれているか、その他の形式に誤りがあります",
+		InvalidScope:            "リクエストされたスコープが無効、不明、または不正な形式です",
+		ServerError:             "認可サーバーで予期しない状態が発生したため、リクエストを処理できませんでした",
+		TemporarilyUnavailable:  "認可サーバーは一時的な過負荷またはメンテナンスのため、リクエストを処理できません",
+		UnauthorizedClient:      "クライアントはこの方法で認可コードをリクエストする権限がありません",
+		UnsupportedResponseType: "認可サーバーはこの方法による認可コードの取得をサポートしていません",
+
+		AccountSelectionRequired: "認可サーバーでセッションを選択する必要があります",
+		ConsentRequired:          "認可サーバーはエンドユーザーの同意を必要としています",
+		InteractionRequired:      "続行するには、エンドユーザーの操作が必要です",
+		LoginRequired:            "認可サーバーはエンドユーザーの認証を必要としています",
+
+		InvalidClient:        "クライアント認証に失敗しました",
+		InvalidGrant:         "認可グラントまたはリフレッシュトークンが無効、期限切れ、失効済み、リダイレクト URI と一致しない、または別のクライアントに発行されたものです",
+		InvalidToken:         "アクセストークンが期限切れ、失効済み、不正な形式、またはその他の理由で無効です",
+		UnsupportedGrantType: "認可サーバーはこの認可グラントタイプをサポートしていません",
+	},
 }
 
 // ErrorText return the general description based on the error code.
@@ -40,6 +106,27 @@ func ErrorText(code string) string {
 	return errorCodeDescriptions[code]
 }
 
+// ErrorStatus returns the http status of the error code.
+func ErrorStatus(code string) int {
+	if status, ok := errorCodeStatuses[code]; ok {
+		return status
+	}
+	return http.StatusBadRequest
+}
+
+// LocalizedErrorText returns the description of the error code in the given
+// locale, or the English description if the locale is not supported.
+func LocalizedErrorText(code, locale string) string {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	if desc, ok := localizedErrorCodeDescriptions[lang][code]; ok {
+		return desc
+	}
+	return ErrorText(code)
+}
+
 // Client errors.
 var (
 	// ErrInvalidClientMetadata occurs when the value of one of the client metadata fields is invalid and the server has rejected this request.
@@ -49,18 +136,68 @@ var (
 	ErrInvalidRedirectURI = NewError("invalid_redirect_uri")
 )
 
+// Token errors.
+var (
+	// ErrInvalidClient occurs when the client authentication failed.
+	ErrInvalidClient = NewError(InvalidClient)
+
+	// ErrInvalidToken occurs when the access token is expired, revoked, malformed, or invalid for other reasons.
+	ErrInvalidToken = NewError(InvalidToken)
+
+	// ErrInvalidDPoPProof occurs when the DPoP proof is missing or invalid.
+	ErrInvalidDPoPProof = NewError("invalid_dpop_proof")
+
+	// ErrUseDPoPNonce occurs when the DPoP proof does not contain the nonce provided by the server.
+	ErrUseDPoPNonce = NewError("use_dpop_nonce")
+
+	// ErrInvalidGrant occurs when the authorization grant, refresh token or assertion is invalid, expired, revoked, or was issued to another client.
+	ErrInvalidGrant = NewError(InvalidGrant)
+
+	// ErrUnsupportedGrantType occurs when the grant type is not supported by the authorization server.
+	ErrUnsupportedGrantType = NewError(UnsupportedGrantType)
+
+	// ErrInvalidTarget occurs when the requested resource is invalid, unknown, or not allowed for the request.
+	ErrInvalidTarget = NewError("invalid_target")
+
+	// ErrInvalidAuthorizationDetails occurs when the authorization_details are malformed, of an unsupported type, or do not conform to the schema of the type.
+	ErrInvalidAuthorizationDetails = NewError("invalid_authorization_details")
+)
+
+// Backchannel authentication errors.
+var (
+	// ErrAuthorizationPending occurs when the end-user has not yet approved the backchannel authentication request.
+	ErrAuthorizationPending = NewError("authorization_pending")
+
+	// ErrSlowDown occurs when the client polls the token endpoint more frequently than the interval.
+	ErrSlowDown = NewError("slow_down")
+
+	// ErrExpiredToken occurs when the backchannel authentication request expired before it was approved.
+	ErrExpiredToken = NewError("expired_token")
+
+	// ErrUnknownUserID occurs when the hint does not identify a valid end-user.
+	ErrUnknownUserID = NewError("unknown_user_id")
+
+	// ErrExpiredLoginHintToken occurs when the login_hint_token has expired.
+	ErrExpiredLoginHintToken = NewError("expired_login_hint_token")
+
+	// ErrInvalidBindingMessage occurs when the binding_message is too long or contains invalid characters.
+	ErrInvalidBindingMessage = NewError("invalid_binding_message")
+)
+
 // NewError returns a new custom error.
 func NewError(code string) *ErrorJSON {
 	desc := errorCodeDescriptions[code]
-	return &ErrorJSON{Code: code, Description: desc}
+	return &ErrorJSON{Code: code, Description: desc, Status: ErrorStatus(code)}
 }
 
-// ErrorJSON represents the json error.
+// ErrorJSON represents the json error. The status is the http status the
+// error is returned with, and is not part of the error response.
 type ErrorJSON struct {
 	Code        string `json:"error,omitempty"`
 	Description string `json:"error_description,omitempty"`
 	State       string `json:"state,omitempty"`
 	URI         string `json:"error_uri,omitempty"`
+	Status      int    `json:"-"`
 }
 
 // Error fulfils the error interface methods.
@@ -83,7 +220,51 @@ func (e *ErrorJSON) SetState(s string) {
 	e.State = s
 }
 
-func (e *ErrorJSON) WithDescription(s string) error {
-	e.SetDescription(s)
-	return e
+// Localize returns a copy of the error with the description in the given
+// locale. Custom descriptions are left untranslated.
+func (e *ErrorJSON) Localize(locale string) *ErrorJSON {
+	copy := *e
+	if e.Description == ErrorText(e.Code) {
+		copy.Description = LocalizedErrorText(e.Code, locale)
+	}
+	return &copy
+}
+
+// WithState returns a copy of the error with the state of the authorization
+// request, so that the shared errors are left unchanged.
+func (e *ErrorJSON) WithState(s string) *ErrorJSON {
+	copy := *e
+	copy.State = s
+	return &copy
+}
+
+// ToQueryString converts the error into the parameters of the error
+// response.
+func (e *ErrorJSON) ToQueryString() url.Values {
+	return querystring.Encode(url.Values{}, e)
+}
+
+// WithDescription returns a copy of the error with the description, so that
+// the shared errors are left unchanged.
+func (e *ErrorJSON) WithDescription(s string) *ErrorJSON {
+	copy := *e
+	copy.Description = s
+	return &copy
+}
+
+// WithStatus returns a copy of the error that is returned with the given http
+// status.
+func (e *ErrorJSON) WithStatus(status int) *ErrorJSON {
+	copy := *e
+	copy.Status = status
+	return &copy
+}
+
+// StatusCode returns the http status of the error, which defaults to the
+// status of the error code.
+func (e *ErrorJSON) StatusCode() int {
+	if e.Status == 0 {
+		return ErrorStatus(e.Code)
+	}
+	return e.Status
 }
@@ -0,0 +1,54 @@
+package openid
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDescription(t *testing.T) {
+	assert := assert.New(t)
+
+	a := ErrInvalidRequest.WithDescription("a")
+	b := ErrInvalidRequest.WithDescription("b")
+	assert.Equal("invalid_request: a", a.Error())
+	assert.Equal("invalid_request: b", b.Error())
+	assert.Equal(ErrorText(InvalidRequest), ErrInvalidRequest.Description, "should not change the shared error")
+}
+
+func TestErrorResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	err := ErrAccessDenied.WithState("xyz")
+	assert.Equal("xyz", err.State)
+	assert.Equal("", ErrAccessDenied.State, "should not change the shared error")
+
+	q := err.ToQueryString()
+	assert.Equal("access_denied", q.Get("error"))
+	assert.Equal("xyz", q.Get("state"))
+	assert.Empty(q.Get("-"), "should not include the status")
+}
+
+func TestErrorStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(http.StatusUnauthorized, ErrInvalidClient.StatusCode())
+	assert.Equal(http.StatusBadRequest, ErrInvalidGrant.StatusCode())
+	assert.Equal(http.StatusInternalServerError, ErrServerError.StatusCode())
+	assert.Equal(http.StatusBadRequest, (&ErrorJSON{Code: "custom_error"}).StatusCode())
+
+	err := ErrInvalidGrant.WithDescription("code expired")
+	assert.Equal("code expired", err.Description)
+	assert.Equal(ErrorText(InvalidGrant), ErrInvalidGrant.Description, "should not change the shared error")
+	assert.Equal(http.StatusBadRequest, err.StatusCode())
+	assert.Equal(http.StatusTooManyRequests, err.WithStatus(http.StatusTooManyRequests).StatusCode())
+}
+
+func TestResponseMode(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsValidResponseMode(""))
+	assert.True(IsValidResponseMode(ResponseModeFormPost))
+	assert.False(IsValidResponseMode("web_message"))
+}
@@ -8,6 +8,16 @@ var displaymap = map[string]struct{}{
 	"wap":   struct{}{},
 }
 
+// IsValidDisplay returns true if the display is one of page, popup, touch or
+// wap. An empty display is valid and defaults to page.
+func IsValidDisplay(display string) bool {
+	if display == "" {
+		return true
+	}
+	_, ok := displaymap[display]
+	return ok
+}
+
 // -- flow
 
 // CheckFlow returns the current openid registration flow.
@@ -10,4 +10,10 @@ func (g GrantType) Equal(grantType string) bool {
 	return string(g) == grantType
 }
 
-var AuthorizationCode GrantType = "authorization_code"
+var (
+	AuthorizationCode GrantType = "authorization_code"
+	RefreshToken      GrantType = "refresh_token"
+	TokenExchange     GrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	JWTBearer         GrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	CIBA              GrantType = "urn:openid:params:grant-type:ciba"
+)
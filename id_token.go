@@ -1,6 +1,7 @@
 package openid
 
 import (
+	"encoding/json"
 	"errors"
 	"strings"
 
@@ -26,6 +27,39 @@ type IDToken struct {
 	Email                               *Email
 	Phone                               *Phone
 	Profile                             *Profile
+
+	// Claims contains additional claims, such as localized claims, that
+	// are merged into the token when it is signed.
+	Claims map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON merges the additional claims into the id token claims. The
+// additional claims cannot override the existing claims.
+func (i *IDToken) MarshalJSON() ([]byte, error) {
+	type idToken IDToken
+	b, err := json.Marshal((*idToken)(i))
+	if err != nil {
+		return nil, err
+	}
+	return mergeClaims(b, i.Claims)
+}
+
+// mergeClaims adds the claims that are not present yet to the marshalled
+// json object.
+func mergeClaims(b []byte, extra map[string]interface{}) ([]byte, error) {
+	if len(extra) == 0 {
+		return b, nil
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, err
+	}
+	for k, v := range extra {
+		if _, exist := claims[k]; !exist {
+			claims[k] = v
+		}
+	}
+	return json.Marshal(claims)
 }
 
 // NewIDToken returns a pointer to a new id token with empty fields.
@@ -45,6 +79,9 @@ func (i *IDToken) SignHS256(key []byte) (string, error) {
 }
 
 func (i *IDToken) ParseHS256(str string, key []byte) error {
+	if isLogoutToken(str) {
+		return errors.New("invalid id_token")
+	}
 	token, err := jwt.ParseWithClaims(str, i, func(token *jwt.Token) (interface{}, error) {
 		return key, nil
 	})
@@ -59,6 +96,39 @@ func (i *IDToken) ParseHS256(str string, key []byte) error {
 	return errors.New("invalid id_token")
 }
 
+// ParseHintHS256 parses an id_token_hint. Unlike ParseHS256, an expired token
+// is still accepted, since the hint is only used to identify the end-user the
+// client believes is logged in. The signature must be valid.
+func (i *IDToken) ParseHintHS256(str string, key []byte) error {
+	if isLogoutToken(str) {
+		return errors.New("invalid id_token_hint")
+	}
+	_, err := jwt.ParseWithClaims(str, i, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid id_token_hint signing method")
+		}
+		return key, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
+		return nil
+	}
+	return errors.New("invalid id_token_hint")
+}
+
+// isLogoutToken returns true if the token carries the events claim of a
+// logout token, which must never be accepted as an id token.
+func isLogoutToken(str string) bool {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(str, claims); err != nil {
+		return false
+	}
+	_, ok := claims["events"]
+	return ok
+}
+
 // TODO: Check other libraries to see their validation.
 
 // Validate performs validation on required fields.
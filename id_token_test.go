@@ -2,6 +2,7 @@ package openid_test
 
 import (
 	"testing"
+	"time"
 
 	openid "github.com/alextanhongpin/go-openid"
 
@@ -81,3 +82,67 @@ func TestIDToken(t *testing.T) {
 		assert.Equal(&o, &oo, "should have different address")
 	})
 }
+
+func TestIDTokenHint(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		key = []byte("secret")
+		sub = "100"
+	)
+
+	o := openid.NewIDToken()
+	o.StandardClaims.Subject = sub
+	o.StandardClaims.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	expired, err := o.SignHS256(key)
+	assert.Nil(err)
+
+	t.Run("parse expired hint", func(t *testing.T) {
+		hint := openid.NewIDToken()
+		err := hint.ParseHintHS256(expired, key)
+		assert.Nil(err, "should accept expired id_token_hint")
+		assert.Equal(sub, hint.StandardClaims.Subject, "subject should be equal")
+	})
+
+	t.Run("parse hint with invalid signature", func(t *testing.T) {
+		hint := openid.NewIDToken()
+		err := hint.ParseHintHS256(expired, []byte("other"))
+		assert.NotNil(err, "should reject id_token_hint with invalid signature")
+	})
+
+	t.Run("parse logout token", func(t *testing.T) {
+		logout, err := jwt.NewWithClaims(jwt.SigningMethodHS256, openid.NewLogoutToken()).SignedString(key)
+		assert.Nil(err)
+
+		assert.NotNil(openid.NewIDToken().ParseHintHS256(logout, key), "should reject logout tokens as id_token_hint")
+		assert.NotNil(openid.NewIDToken().ParseHS256(logout, key), "should reject logout tokens as id_token")
+	})
+}
+
+func TestIDTokenLocalizedClaims(t *testing.T) {
+	assert := assert.New(t)
+
+	user := &openid.User{
+		LocalizedClaims: map[string]string{
+			"name#ja-Kana-JP":        "ヤマダタロウ",
+			"name#ja-Hani-JP":        "山田太郎",
+			"family_name#ja-Kana-JP": "ヤマダ",
+		},
+	}
+
+	idToken := user.ToIDToken()
+	idToken.Claims = user.ClaimsForLocales([]string{"ja-Kana-JP"})
+
+	key := []byte("secret")
+	ss, err := idToken.SignHS256(key)
+	assert.Nil(err)
+
+	var claims jwt.MapClaims
+	_, err = jwt.ParseWithClaims(ss, &claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	})
+	assert.Nil(err)
+	assert.Equal("ヤマダタロウ", claims["name#ja-Kana-JP"], "should include the localized name")
+	assert.Equal("ヤマダ", claims["family_name#ja-Kana-JP"], "should include the localized family name")
+	assert.Nil(claims["name#ja-Hani-JP"], "should exclude claims in other locales")
+}
@@ -2,11 +2,13 @@ package controller
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
-	"strings"
 
 	"github.com/alextanhongpin/go-openid"
+	"github.com/alextanhongpin/go-openid/pkg/authheader"
 	"github.com/alextanhongpin/go-openid/pkg/html5"
+	"github.com/alextanhongpin/go-openid/pkg/schema"
 	"github.com/alextanhongpin/go-openid/service"
 
 	"github.com/julienschmidt/httprouter"
@@ -14,8 +16,9 @@ import (
 
 // Client represents the client controller.
 type Client struct {
-	service  service.Client
-	template *html5.Template
+	service   service.Client
+	template  *html5.Template
+	validator schema.Validator
 }
 
 // NewClient returns a new client controller with the given options.
@@ -29,69 +32,78 @@ func NewClient(opts ...clientOption) Client {
 
 // GetClientRegister returns the client registration page.
 func (c *Client) GetClientRegister(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// sess, err := c.session.GetSession(r)
-	// if err != nil {
-	//         writeError(w, http.StatusBadRequest, err)
-	//         return
-	// }
-
-	// TODO: Check if the user is authorized to read the client
-	// details.
-	id := r.URL.Query().Get("client_id")
-	if id == "" {
-		c.template.Render(w, "client-register", nil)
+	c.template.Render(w, "client-register", nil, html5.Locale(negotiateLocale(c.template, r)))
+}
+
+// GetClient returns the current configuration of the client. The request
+// must be authorized with the registration access token.
+func (c *Client) GetClient(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	setNoCache(w)
+	token, ok := registrationAccessToken(w, r)
+	if !ok {
 		return
 	}
-	client, err := c.service.Read(id)
+	client, err := c.service.Read(r.Context(), ps.ByName("client_id"), token)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(client)
 }
 
-// GetClient returns a client by client id.
-func (c *Client) GetClient(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	id := ps.ByName("client_id")
-	client, err := c.service.Read(id)
+// PutClient replaces the metadata of the client. The client secret is
+// rotated when the rotate_secret query parameter is set to true, and a new
+// registration access token is returned on every update.
+func (c *Client) PutClient(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	setNoCache(w)
+	token, ok := registrationAccessToken(w, r)
+	if !ok {
+		return
+	}
+	client, err := c.decodeClientMetadata(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(client)
+	rotateSecret := r.URL.Query().Get("rotate_secret") == "true"
+	res, err := c.service.Update(r.Context(), ps.ByName("client_id"), token, client, rotateSecret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
 }
 
-// PostClientRegister handles the client registration request.
+// DeleteClient deprovisions the client.
+func (c *Client) DeleteClient(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	setNoCache(w)
+	token, ok := registrationAccessToken(w, r)
+	if !ok {
+		return
+	}
+	if err := c.service.Delete(r.Context(), ps.ByName("client_id"), token); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostClientRegister handles the client registration request. The body is
+// the json client metadata document as described in RFC 7591.
 func (c *Client) PostClientRegister(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	r.ParseForm()
 	// TODO: Check if the user is authorized to perform client
 	// registration.
 
-	w.Header().Set("Cache-Control", "no-store")
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Pragma", "no-cache")
-
-	buildClient := func(r *http.Request) *openid.Client {
-		var (
-			clientName   = r.FormValue("client_name")
-			redirectURIs = strings.Split(r.FormValue("redirect_uris"), " ")
-		)
-		client := openid.NewClient()
-		client.ClientName = clientName
-		client.RedirectURIs = redirectURIs
-		return client
+	setNoCache(w)
+	client, err := c.decodeClientMetadata(r)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 
-	client := buildClient(r)
-
-	newClient, err := c.service.Register(client)
+	newClient, err := c.service.Register(r.Context(), client)
 	if err != nil {
-		v, ok := err.(*openid.ErrorJSON)
-		if ok {
-			json.NewEncoder(w).Encode(v)
-		} else {
-			json.NewEncoder(w).Encode(M{"error": err.Error()})
-		}
+		writeError(w, err)
 		return
 	}
 
@@ -99,6 +111,70 @@ func (c *Client) PostClientRegister(w http.ResponseWriter, r *http.Request, _ ht
 	json.NewEncoder(w).Encode(newClient)
 }
 
+// decodeClientMetadata validates the client metadata in the request body and
+// returns the client with the server defaults for the omitted fields.
+func (c *Client) decodeClientMetadata(r *http.Request) (*openid.Client, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, openid.ErrInvalidClientMetadata.WithDescription(err.Error())
+	}
+
+	// Validate the metadata as it was sent, before the defaults are
+	// applied.
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return nil, openid.ErrInvalidClientMetadata.WithDescription("request body must be a json object")
+	}
+	if c.validator != nil {
+		// The credentials are included when updating the client, but
+		// are not part of the client metadata.
+		data := make(map[string]interface{})
+		for k, v := range metadata {
+			if !isClientCredential(k) {
+				data[k] = v
+			}
+		}
+		if _, err := c.validator.Validate(data); err != nil {
+			return nil, openid.ErrInvalidClientMetadata.WithDescription(err.Error())
+		}
+	}
+
+	// Fields that are not provided keep the server defaults.
+	client := openid.NewClient()
+	if err := json.Unmarshal(body, client); err != nil {
+		return nil, openid.ErrInvalidClientMetadata.WithDescription(err.Error())
+	}
+	return client, nil
+}
+
+func isClientCredential(field string) bool {
+	switch field {
+	case "client_id", "client_secret", "client_id_issued_at", "client_secret_expires_at",
+		"registration_access_token", "registration_client_uri":
+		return true
+	default:
+		return false
+	}
+}
+
+// registrationAccessToken returns the bearer token of the request, or writes
+// the error if it is missing.
+func registrationAccessToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	token, err := authheader.Bearer(r.Header.Get("Authorization"))
+	if err != nil || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, openid.ErrInvalidToken)
+		return "", false
+	}
+	return token, true
+}
+
+func setNoCache(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Pragma", "no-cache")
+}
+
 // -- options
 
 type clientOption func(c *Client)
@@ -116,3 +192,10 @@ func ClientTemplate(h *html5.Template) clientOption {
 		c.template = h
 	}
 }
+
+// ClientValidator sets the validator for the client metadata.
+func ClientValidator(v schema.Validator) clientOption {
+	return func(c *Client) {
+		c.validator = v
+	}
+}
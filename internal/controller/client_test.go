@@ -5,15 +5,18 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	openid "github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/controller"
+	"github.com/alextanhongpin/go-openid/pkg/schema"
 	"github.com/alextanhongpin/go-openid/service"
 	"github.com/alextanhongpin/go-openid/testdata"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestGetClient(t *testing.T) {
@@ -28,8 +31,8 @@ func TestGetClient(t *testing.T) {
 		}
 	)
 	s := testdata.NewClientService()
-	s.On("Read", clientID).Return(&client, nil)
-	rr := curlClient(s, "GET", "/clients/"+clientID, nil)
+	s.On("Read", mock.Anything, clientID, "token").Return(&client, nil)
+	rr := curlClient(s, "GET", "/connect/register/"+clientID, nil)
 
 	assert.Equal(http.StatusOK, rr.Code)
 
@@ -40,14 +43,127 @@ func TestGetClient(t *testing.T) {
 	assert.Equal(clientSecret, res.ClientSecret)
 }
 
+func TestPostClientRegister(t *testing.T) {
+	assert := assert.New(t)
+
+	validator, err := schema.NewClientValidator()
+	assert.Nil(err)
+
+	t.Run("register with client metadata", func(t *testing.T) {
+		s := testdata.NewClientService()
+		s.On("Register", mock.Anything, mock.AnythingOfType("*client.Client")).Return(&openid.Client{
+			ClientID:                "1",
+			ClientSecret:            "secret",
+			RegistrationAccessToken: "token",
+			RegistrationClientURI:   "http://localhost:8080/connect/register?client_id=1",
+		}, nil)
+
+		payload := strings.NewReader(`{"client_name": "app", "redirect_uris": ["https://client.example.com/cb"]}`)
+		rr := curlClientRegister(s, validator, payload)
+		assert.Equal(http.StatusCreated, rr.Code)
+		assert.Equal("no-store", rr.Header().Get("Cache-Control"))
+
+		var res openid.Client
+		err := json.NewDecoder(rr.Body).Decode(&res)
+		assert.Nil(err)
+		assert.Equal("1", res.ClientID)
+		assert.Equal("http://localhost:8080/connect/register?client_id=1", res.RegistrationClientURI)
+
+		req := s.Calls[0].Arguments.Get(1).(*openid.Client)
+		assert.Equal("web", req.ApplicationType, "should apply the server defaults")
+		assert.Equal("client_secret_basic", req.TokenEndpointAuthMethod)
+	})
+
+	t.Run("register with invalid client metadata", func(t *testing.T) {
+		s := testdata.NewClientService()
+		payload := strings.NewReader(`{"client_name": "app", "subject_type": "unknown", "redirect_uris": ["https://client.example.com/cb"]}`)
+		rr := curlClientRegister(s, validator, payload)
+		assert.Equal(http.StatusBadRequest, rr.Code)
+
+		var res openid.ErrorJSON
+		err := json.NewDecoder(rr.Body).Decode(&res)
+		assert.Nil(err)
+		assert.Equal("invalid_client_metadata", res.Code)
+	})
+}
+
+func curlClientRegister(service service.Client, validator schema.Validator, payload io.Reader) *httptest.ResponseRecorder {
+	ctl := controller.NewClient(
+		controller.ClientService(service),
+		controller.ClientValidator(validator),
+	)
+
+	router := httprouter.New()
+	router.POST("/connect/register", ctl.PostClientRegister)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/connect/register", payload)
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGetClientUnauthorized(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testdata.NewClientService()
+	s.On("Read", mock.Anything, "1", "token").Return(nil, openid.ErrInvalidToken)
+	rr := curlClient(s, "GET", "/connect/register/1", nil)
+
+	assert.Equal(http.StatusUnauthorized, rr.Code)
+	assert.Equal(`Bearer error="invalid_token"`, rr.Header().Get("WWW-Authenticate"))
+}
+
+func TestPutClient(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testdata.NewClientService()
+	s.On("Update", mock.Anything, "1", "token", mock.AnythingOfType("*client.Client"), true).Return(&openid.Client{
+		ClientID:                "1",
+		ClientSecret:            "new secret",
+		RegistrationAccessToken: "new token",
+	}, nil)
+
+	payload := strings.NewReader(`{"client_id": "1", "client_name": "app", "redirect_uris": ["https://client.example.com/cb"]}`)
+	rr := curlClient(s, "PUT", "/connect/register/1?rotate_secret=true", payload)
+	assert.Equal(http.StatusOK, rr.Code)
+
+	var res openid.Client
+	err := json.NewDecoder(rr.Body).Decode(&res)
+	assert.Nil(err)
+	assert.Equal("new token", res.RegistrationAccessToken)
+
+	req := s.Calls[0].Arguments.Get(3).(*openid.Client)
+	assert.Equal("1", req.ClientID)
+	assert.Equal("app", req.ClientName)
+}
+
+func TestDeleteClient(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testdata.NewClientService()
+	s.On("Delete", mock.Anything, "1", "token").Return(nil)
+	rr := curlClient(s, "DELETE", "/connect/register/1", nil)
+
+	assert.Equal(http.StatusNoContent, rr.Code)
+	s.AssertExpectations(t)
+}
+
 func curlClient(service service.Client, method, endpoint string, payload io.Reader) *httptest.ResponseRecorder {
-	ctl := controller.NewClient(controller.ClientService(service))
+	validator, _ := schema.NewClientValidator()
+	ctl := controller.NewClient(
+		controller.ClientService(service),
+		controller.ClientValidator(validator),
+	)
 
 	router := httprouter.New()
-	router.GET("/clients/:client_id", ctl.GetClient)
+	router.GET("/connect/register/:client_id", ctl.GetClient)
+	router.PUT("/connect/register/:client_id", ctl.PutClient)
+	router.DELETE("/connect/register/:client_id", ctl.DeleteClient)
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(method, endpoint, payload)
+	req.Header.Set("Authorization", "Bearer token")
 	router.ServeHTTP(rr, req)
 	return rr
 }
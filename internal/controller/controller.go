@@ -1,10 +1,18 @@
 package controller
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"net/url"
+	"strings"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/alextanhongpin/go-openid/pkg/html5"
+	"github.com/alextanhongpin/go-openid/pkg/mtls"
 )
 
 // M represents simple map interface.
@@ -21,19 +29,68 @@ func encodeBase64(in string) string {
 	return base64.URLEncoding.EncodeToString([]byte(in))
 }
 
-func writeError(w http.ResponseWriter, status int, err error) {
+// writeError writes the error as a protocol error with the status of the
+// error. Client authentication failures are returned with a challenge, unless
+// the caller has set one already.
+func writeError(w http.ResponseWriter, err error) {
+	e := protocolError(err)
+	status := e.StatusCode()
+	if status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+		switch e.Code {
+		case openid.InvalidClient:
+			w.Header().Set("WWW-Authenticate", `Basic realm="openid"`)
+		default:
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="%s"`, e.Code))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(M{
-		"error": err.Error(),
-	})
+	json.NewEncoder(w).Encode(e)
+}
+
+// protocolError returns the error as a protocol error. Other errors are
+// unexpected failures, which are logged and returned as server_error without
+// revealing their message.
+func protocolError(err error) *openid.ErrorJSON {
+	if v, ok := err.(*openid.ErrorJSON); ok {
+		return v
+	}
+	log.Printf("error: %v\n", err)
+	return openid.ErrServerError
+}
+
+// isForm returns true if the request body is form encoded.
+func isForm(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
+
+// negotiateLocale returns the locale for the end-user based on the ui_locales
+// querystring, falling back to the Accept-Language header.
+func negotiateLocale(t *html5.Template, r *http.Request) string {
+	return t.Negotiate(r.URL.Query().Get("ui_locales"), r.Header.Get("Accept-Language"))
+}
+
+// localize translates the error description to the given locale.
+func localize(err error, locale string) error {
+	if v, ok := err.(*openid.ErrorJSON); ok {
+		return v.Localize(locale)
+	}
+	return err
 }
 
+// buildURL adds the parameters to the query of the uri, keeping the query
+// that the uri already has.
 func buildURL(uri string, q url.Values) (string, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
 		return "", err
 	}
-	u.RawQuery = q.Encode()
+	query := u.Query()
+	for k, v := range q {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
 	return u.String(), nil
 }
 
@@ -62,3 +119,14 @@ func getHost(r *http.Request) *url.URL {
 
 	return u
 }
+
+// withClientCert attaches the TLS client certificate of the request and its
+// intermediates to the context.
+func withClientCert(r *http.Request) context.Context {
+	cert, ok := mtls.Certificate(r)
+	if !ok {
+		return r.Context()
+	}
+	ctx := openid.SetCertContextKey(r.Context(), cert)
+	return openid.SetCertChainContextKey(ctx, mtls.Intermediates(r))
+}
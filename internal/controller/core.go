@@ -1,13 +1,21 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/internal/core"
+	"github.com/alextanhongpin/go-openid/pkg/authheader"
+	"github.com/alextanhongpin/go-openid/pkg/backchannel"
+	"github.com/alextanhongpin/go-openid/pkg/ciba"
+	"github.com/alextanhongpin/go-openid/pkg/dpop"
 	"github.com/alextanhongpin/go-openid/pkg/html5"
 	"github.com/alextanhongpin/go-openid/pkg/querystring"
 	"github.com/alextanhongpin/go-openid/pkg/session"
@@ -21,6 +29,20 @@ type Core struct {
 	service  service.Core
 	template *html5.Template
 	session  *session.Manager
+	dpop     *dpop.Verifier
+
+	// issuer is included in the authorization responses.
+	issuer string
+
+	// backchannel delivers the logout tokens to the clients when the
+	// session ends.
+	backchannel *backchannel.Dispatcher
+
+	// notifier asks the end-users to approve the backchannel
+	// authentication requests on their authentication devices, and
+	// clientNotifier notifies the ping and push clients of the decision.
+	notifier       ciba.Notifier
+	clientNotifier *ciba.ClientNotifier
 }
 
 // NewCore takes an optional list of core options and returns a Core
@@ -29,6 +51,13 @@ func NewCore(opts ...coreOption) Core {
 	c := Core{
 		service: core.New(),
 		session: session.NewManager(),
+		dpop:    dpop.NewVerifier(),
+		issuer:  "http://localhost:8080",
+
+		backchannel: backchannel.NewDispatcher(),
+
+		notifier:       ciba.NewLogNotifier(nil),
+		clientNotifier: ciba.NewClientNotifier(nil),
 	}
 	for _, o := range opts {
 		o(&c)
@@ -39,15 +68,22 @@ func NewCore(opts ...coreOption) Core {
 // GetAuthorize represents the authorize endpoint.
 func (c *Core) GetAuthorize(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	q := r.URL.Query()
+	locale := negotiateLocale(c.template, r)
 
 	var req openid.AuthenticationRequest
 	if err := querystring.Decode(q, &req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		c.renderError(w, r, openid.ErrInvalidRequest)
 		return
 	}
 
+	// Errors about the client or the redirect uri are shown to the
+	// end-user, the other errors are sent to the validated redirect uri.
+	if err := c.service.ValidateRedirectURI(&req); err != nil {
+		c.renderError(w, r, err)
+		return
+	}
 	if err := c.service.PreAuthenticate(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		c.redirectError(w, r, &req, err)
 		return
 	}
 
@@ -56,6 +92,15 @@ func (c *Core) GetAuthorize(w http.ResponseWriter, r *http.Request, _ httprouter
 		redirectURI.RawQuery = q.Encode()
 		base64uri := encodeBase64(redirectURI.String())
 		u := fmt.Sprintf(`http://localhost:8080/login?return_url=%s`, base64uri)
+		if req.LoginHint != "" {
+			u += "&login_hint=" + url.QueryEscape(req.LoginHint)
+		}
+		if req.UILocales != "" {
+			u += "&ui_locales=" + url.QueryEscape(req.UILocales)
+		}
+		if req.Display != "" {
+			u += "&display=" + url.QueryEscape(req.Display)
+		}
 		http.Redirect(w, r, u, http.StatusFound)
 	}
 
@@ -66,7 +111,7 @@ func (c *Core) GetAuthorize(w http.ResponseWriter, r *http.Request, _ httprouter
 	// an error should be returned indicating that login is
 	// required.
 	if prompt.Is(openid.PromptNone) && !isAuthorized {
-		http.Error(w, openid.ErrLoginRequired.Error(), http.StatusBadRequest)
+		c.redirectError(w, r, &req, openid.ErrLoginRequired)
 		return
 	}
 
@@ -76,90 +121,700 @@ func (c *Core) GetAuthorize(w http.ResponseWriter, r *http.Request, _ httprouter
 		return
 	}
 
+	// The hints may refer to a different user than the one in the
+	// current session. The error is returned to the client, and the
+	// session is kept, since any site can send the user here.
+	if err := c.checkSession(r, &req); err != nil {
+		c.redirectError(w, r, &req, err)
+		return
+	}
+
+	sess, err := c.session.GetSession(r)
+	if err != nil {
+		redirectToLogin()
+		return
+	}
+	ctx := openid.SetUserIDContextKey(r.Context(), sess.UserID)
+	consent, err := c.service.Consent(ctx, &req)
+	if err != nil {
+		c.redirectError(w, r, &req, err)
+		return
+	}
+
+	// The end-user granted the request previously, so the client is
+	// authorized without asking again.
+	if consent == nil {
+		c.authorize(w, r, &req, sess)
+		return
+	}
+
+	type item struct {
+		Name        string
+		Description string
+	}
+	// The translated text of the standard scopes takes precedence over
+	// the registered consent text.
+	describe := func(scopes []string) []item {
+		items := make([]item, len(scopes))
+		for i, s := range scopes {
+			key := "consent.scope." + s
+			desc := c.template.Translate(locale, key)
+			if desc == key {
+				desc = consent.ConsentText[s]
+			}
+			if desc == "" {
+				desc = s
+			}
+			items[i] = item{s, desc}
+		}
+		return items
+	}
+	type field struct {
+		Name  string
+		Value string
+	}
+	type detail struct {
+		Description string
+		Fields      []field
+	}
+	// The fields of the authorization details are shown as they are,
+	// since only the client knows how to present them.
+	details := make([]detail, len(consent.AuthorizationDetails))
+	for i, d := range consent.AuthorizationDetails {
+		desc := consent.AuthorizationDetailText[d.Type()]
+		if desc == "" {
+			desc = d.Type()
+		}
+		var fields []field
+		for name, v := range d {
+			if name == "type" {
+				continue
+			}
+			value, ok := v.(string)
+			if !ok {
+				b, _ := json.Marshal(v)
+				value = string(b)
+			}
+			fields = append(fields, field{name, value})
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Name < fields[j].Name
+		})
+		details[i] = detail{desc, fields}
+	}
 	type response struct {
-		QueryString string
+		Action               string
+		ConsentToken         string
+		ClientName           string
+		Scopes               []item
+		Claims               []string
+		GrantedScopes        []item
+		AuthorizationDetails []detail
+	}
+	res := response{
+		Action:               "/authorize?" + q.Encode(),
+		ConsentToken:         sess.CSRFToken(q.Encode()),
+		ClientName:           consent.ClientName,
+		Scopes:               describe(consent.Scopes),
+		Claims:               consent.Claims,
+		GrantedScopes:        describe(consent.GrantedScopes),
+		AuthorizationDetails: details,
 	}
-	res := response{q.Encode()}
-	c.template.Render(w, "consent", res)
+	c.template.Render(w, "consent", res, html5.Locale(locale), html5.Display(req.Display))
 }
 
-// PostAuthorize represents the post authorize endpoint.
-func (c *Core) PostAuthorize(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ctx := r.Context()
-
-	// User needs to have a session in order to call the post
-	// authorize endpoint.
+// checkSession validates the authentication request against the user in the
+// current session.
+func (c *Core) checkSession(r *http.Request, req *openid.AuthenticationRequest) error {
 	sess, err := c.session.GetSession(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		return openid.ErrLoginRequired
+	}
+	ctx := openid.SetUserIDContextKey(r.Context(), sess.UserID)
+	return c.service.CheckSession(ctx, req)
+}
+
+// logout removes the current session so that the user has to login again.
+// The clients of the session are notified through the back-channel.
+func (c *Core) logout(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(session.Key)
+	if err != nil {
 		return
 	}
+	if sess, err := c.session.GetSession(r); err == nil {
+		c.backChannelLogout(r.Context(), sess)
+	}
+	c.session.Delete(cookie.Value)
+	http.SetCookie(w, &http.Cookie{
+		Name:   session.Key,
+		Path:   "/",
+		MaxAge: -1,
+	})
+	c.session.SetBrowserState(w)
+}
 
-	// Attach the user_id to the context.
-	ctx = openid.SetUserIDContextKey(ctx, sess.UserID)
+// backChannelLogout queues the logout tokens for the clients of the session.
+// The logout proceeds even if the clients cannot be notified.
+func (c *Core) backChannelLogout(ctx context.Context, sess *session.Session) {
+	for _, req := range c.service.BackChannelLogout(ctx, sess.UserID, sess.SID, sess.ClientIDs) {
+		if err := c.backchannel.Enqueue(req.URI, req.LogoutToken); err != nil {
+			log.Printf("backchannel logout: client %s: %v\n", req.ClientID, err)
+		}
+	}
+}
 
+// PostAuthorize represents the post authorize endpoint.
+func (c *Core) PostAuthorize(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	if len(r.URL.Query()) == 0 {
-		writeError(w, http.StatusUnprocessableEntity, errors.New("request is empty"))
+		c.renderError(w, r, openid.ErrInvalidRequest)
 		return
 	}
 	// Construct the request payload from the querystring.
 	var req openid.AuthenticationRequest
 	if err := req.FromQueryString(r.URL.Query()); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		c.renderError(w, r, openid.ErrInvalidRequest)
+		return
+	}
+	if err := c.service.ValidateRedirectURI(&req); err != nil {
+		c.renderError(w, r, err)
+		return
+	}
+
+	// User needs to have a session in order to call the post
+	// authorize endpoint.
+	sess, err := c.session.GetSession(r)
+	if err != nil {
+		c.redirectError(w, r, &req, openid.ErrLoginRequired)
 		return
 	}
 
+	// The consent is only accepted from the form that was shown to the
+	// end-user, since it is recorded for the later requests.
+	if !sess.VerifyCSRFToken(r.URL.Query().Encode(), r.PostFormValue("consent_token")) {
+		c.renderError(w, r, openid.ErrInvalidRequest.WithDescription("consent_token is invalid"))
+		return
+	}
+
+	// The end-user denied the consent.
+	if r.PostFormValue("decision") == "deny" {
+		c.redirectError(w, r, &req, openid.ErrAccessDenied)
+		return
+	}
+	c.authorize(w, r, &req, sess)
+}
+
+// authorize authenticates the request for the end-user of the session, who
+// has consented to it.
+func (c *Core) authorize(w http.ResponseWriter, r *http.Request, req *openid.AuthenticationRequest, sess *session.Session) {
+	// Attach the user_id and the sid to the context.
+	ctx := openid.SetUserIDContextKey(r.Context(), sess.UserID)
+	ctx = openid.SetSessionContextKey(ctx, sess.SID)
+	ctx = openid.SetAuthTimeContextKey(ctx, sess.CreatedAt)
+	if cookie, err := r.Cookie(session.BrowserStateKey); err == nil {
+		ctx = openid.SetBrowserStateContextKey(ctx, cookie.Value)
+	}
+
 	// Attempt to authenticate the user.
-	res, err := c.service.Authenticate(ctx, &req)
+	res, err := c.service.Authenticate(ctx, req)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		c.redirectError(w, r, req, err)
 		return
 	}
 
-	u, err := buildURL(req.RedirectURI, res.ToQueryString())
+	// Track the client, so that it is notified when the session ends.
+	if err := c.session.AddClient(sess.SessionID, req.ClientID); err != nil {
+		log.Printf("authorize: %v\n", err)
+		c.redirectError(w, r, req, openid.ErrServerError)
+		return
+	}
+
+	c.authorizationResponse(w, r, req, res.ToQueryString())
+}
+
+// authorizationResponse sends the parameters to the redirect uri of the
+// request in its response mode. The iss parameter lets the client check that
+// the response comes from us (RFC 9207).
+func (c *Core) authorizationResponse(w http.ResponseWriter, r *http.Request, req *openid.AuthenticationRequest, q url.Values) {
+	q.Set("iss", c.issuer)
+
+	// For popup, the response is posted back to the window that opened
+	// the popup instead.
+	if req.Display == "popup" {
+		u, err := buildURL(req.RedirectURI, q)
+		if err != nil {
+			c.renderError(w, r, err)
+			return
+		}
+		c.renderPopupCallback(w, r, req.RedirectURI, u, q)
+		return
+	}
+
+	switch req.ResponseMode {
+	case openid.ResponseModeFormPost:
+		type data struct {
+			RedirectURI string
+			Params      url.Values
+		}
+		c.template.Render(w, "form-post", data{req.RedirectURI, q}, html5.Locale(negotiateLocale(c.template, r)))
+	case openid.ResponseModeFragment:
+		u, err := url.Parse(req.RedirectURI)
+		if err != nil {
+			c.renderError(w, r, err)
+			return
+		}
+		u.Fragment = ""
+		http.Redirect(w, r, u.String()+"#"+q.Encode(), http.StatusFound)
+	default:
+		u, err := buildURL(req.RedirectURI, q)
+		if err != nil {
+			c.renderError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, u, http.StatusFound)
+	}
+}
+
+// redirectError sends the error to the redirect uri of the request, which
+// must have been validated, together with the state of the request.
+func (c *Core) redirectError(w http.ResponseWriter, r *http.Request, req *openid.AuthenticationRequest, err error) {
+	c.authorizationResponse(w, r, req, protocolError(err).WithState(req.State).ToQueryString())
+}
+
+// renderError shows the error on our own error page, for the errors that
+// cannot be sent to the client because the client or the redirect uri is
+// invalid.
+func (c *Core) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	if c.template == nil {
+		writeError(w, err)
+		return
+	}
+	locale := negotiateLocale(c.template, r)
+	verr := protocolError(err)
+	c.template.Render(w, "error", verr.Localize(locale), html5.Locale(locale), html5.Status(verr.StatusCode()))
+}
+
+// renderPopupCallback renders the page that posts the authentication response
+// back to the opener of the popup. The message can only be received by the
+// origin of the redirect uri.
+func (c *Core) renderPopupCallback(w http.ResponseWriter, r *http.Request, redirectURI, redirectURL string, q url.Values) {
+	target, err := url.Parse(redirectURI)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, err)
 		return
 	}
+	response := make(map[string]string)
+	for k := range q {
+		response[k] = q.Get(k)
+	}
+	type data struct {
+		RedirectURL  string
+		TargetOrigin string
+		Response     map[string]string
+	}
+	d := data{
+		RedirectURL:  redirectURL,
+		TargetOrigin: fmt.Sprintf("%s://%s", target.Scheme, target.Host),
+		Response:     response,
+	}
+	c.template.Render(w, "popup-callback", d, html5.Locale(negotiateLocale(c.template, r)))
+}
 
-	http.Redirect(w, r, u, http.StatusFound)
+// offlineGrants are the grants that do not require the session of the user.
+var offlineGrants = map[string]bool{
+	openid.RefreshToken.String():  true,
+	openid.TokenExchange.String(): true,
+	openid.JWTBearer.String():     true,
+	openid.CIBA.String():          true,
 }
 
 // PostToken represents the post token endpoint.
 func (c *Core) PostToken(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ctx := r.Context()
+	ctx := withClientCert(r)
+
+	// Parse request body. Token requests are form encoded, and json
+	// bodies are still accepted for the existing clients.
+	var req openid.AccessTokenRequest
+	if isForm(r) {
+		if err := decodeTokenForm(r, &req); err != nil {
+			writeError(w, openid.ErrInvalidRequest.WithDescription("request body is malformed"))
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, openid.ErrInvalidRequest.WithDescription("request body is malformed"))
+		return
+	}
 
 	// Checks if the user has an active session. If the session is
 	// not active, user does not have the right credentials to
-	// access the token endpoint.
-	sess, err := c.session.GetSession(r)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
+	// access the token endpoint. The other grants identify the
+	// user with a token or an assertion, and are used when the
+	// user is offline.
+	if !offlineGrants[req.GrantType] {
+		sess, err := c.session.GetSession(r)
+		if err != nil {
+			writeError(w, openid.ErrInvalidRequest.WithDescription("end-user session is required"))
+			return
+		}
+		ctx = openid.SetUserIDContextKey(ctx, sess.UserID)
 	}
-	ctx = openid.SetUserIDContextKey(ctx, sess.UserID)
 
 	// Put the extra data in the context to be validated by the
 	// service.
 	authorization := r.Header.Get("Authorization")
 	ctx = openid.SetAuthContextKey(ctx, authorization)
 
-	// Parse request body.
-	var req openid.AccessTokenRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
+	// The tokens are bound to the key of the DPoP proof, if any.
+	if r.Header.Get(dpop.Header) != "" {
+		proof, err := c.dpop.Verify(r, "")
+		if err != nil {
+			c.writeDPoPError(w, http.StatusBadRequest, err)
+			return
+		}
+		ctx = openid.SetDPoPContextKey(ctx, proof.Thumbprint)
 	}
 
 	res, err := c.service.Token(ctx, &req)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
+
+// decodeTokenForm decodes the form encoded token request, including the
+// client credentials of client_secret_post and client_assertion.
+func decodeTokenForm(r *http.Request, req *openid.AccessTokenRequest) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if err := querystring.Decode(r.PostForm, req); err != nil {
+		return err
+	}
+	return querystring.Decode(r.PostForm, &req.ClientCredentials)
+}
+
+// GetUserInfo represents the userinfo endpoint. The access token can be sent
+// in the Authorization header, or as the access_token form parameter for
+// POST requests. DPoP-bound tokens must be sent with the DPoP scheme together
+// with the proof.
+func (c *Core) GetUserInfo(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := withClientCert(r)
+	authorization := r.Header.Get("Authorization")
+
+	token, err := authheader.DPoP(authorization)
+	if err == nil {
+		proof, err := c.dpop.Verify(r, token)
+		if err != nil {
+			c.writeDPoPError(w, http.StatusUnauthorized, err)
+			return
+		}
+		ctx = openid.SetDPoPContextKey(ctx, proof.Thumbprint)
+	} else {
+		token, err = authheader.Bearer(authorization)
+	}
+	if err != nil && r.Method == http.MethodPost {
+		token, err = r.FormValue("access_token"), nil
+	}
+	if err != nil || token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeError(w, openid.ErrInvalidToken)
+		return
+	}
+
+	res, err := c.service.UserInfo(ctx, token)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeError(w, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(res)
 }
 
+// PostIntrospect represents the token introspection endpoint. The client
+// must authenticate in the same way as at the token endpoint.
+func (c *Core) PostIntrospect(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := openid.SetAuthContextKey(withClientCert(r), r.Header.Get("Authorization"))
+
+	token := r.FormValue("token")
+	if token == "" {
+		writeError(w, openid.ErrInvalidRequest)
+		return
+	}
+
+	creds := openid.ClientCredentials{
+		ClientID:            r.FormValue("client_id"),
+		ClientSecret:        r.FormValue("client_secret"),
+		ClientAssertionType: r.FormValue("client_assertion_type"),
+		ClientAssertion:     r.FormValue("client_assertion"),
+	}
+	res, err := c.service.Introspect(ctx, token, creds)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// PostRevoke represents the token revocation endpoint. The client must
+// authenticate in the same way as at the token endpoint, and the response is
+// empty whether or not the token was valid.
+func (c *Core) PostRevoke(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := openid.SetAuthContextKey(withClientCert(r), r.Header.Get("Authorization"))
+
+	token := r.FormValue("token")
+	if token == "" {
+		writeError(w, openid.ErrInvalidRequest)
+		return
+	}
+
+	creds := openid.ClientCredentials{
+		ClientID:            r.FormValue("client_id"),
+		ClientSecret:        r.FormValue("client_secret"),
+		ClientAssertionType: r.FormValue("client_assertion_type"),
+		ClientAssertion:     r.FormValue("client_assertion"),
+	}
+	if err := c.service.Revoke(ctx, token, creds); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// PostBackchannelAuthorize represents the backchannel authentication endpoint.
+// The end-user identified by the hint is asked to approve the request on their
+// authentication device, and the client obtains the tokens with the
+// auth_req_id.
+func (c *Core) PostBackchannelAuthorize(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := openid.SetAuthContextKey(withClientCert(r), r.Header.Get("Authorization"))
+	if err := r.ParseForm(); err != nil {
+		writeError(w, openid.ErrInvalidRequest.WithDescription("request body is malformed"))
+		return
+	}
+
+	var req openid.BackchannelAuthenticationRequest
+	if err := querystring.Decode(r.PostForm, &req); err != nil {
+		writeError(w, openid.ErrInvalidRequest)
+		return
+	}
+	creds := openid.ClientCredentials{
+		ClientID:            r.PostForm.Get("client_id"),
+		ClientSecret:        r.PostForm.Get("client_secret"),
+		ClientAssertionType: r.PostForm.Get("client_assertion_type"),
+		ClientAssertion:     r.PostForm.Get("client_assertion"),
+	}
+	// Pushed tokens are bound to the key of the DPoP proof, if any.
+	if r.Header.Get(dpop.Header) != "" {
+		proof, err := c.dpop.Verify(r, "")
+		if err != nil {
+			c.writeDPoPError(w, http.StatusBadRequest, err)
+			return
+		}
+		ctx = openid.SetDPoPContextKey(ctx, proof.Thumbprint)
+	}
+
+	auth, err := c.service.BackchannelAuthenticate(ctx, &req, creds)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	approvalURI := getHost(r)
+	approvalURI.Path = "/bc-approve"
+	approvalURI.RawQuery = url.Values{"id": {auth.ApprovalID}}.Encode()
+	if err := c.notifier.Notify(ctx, ciba.Notification{
+		UserID:         auth.UserID,
+		ClientID:       auth.ClientID,
+		Scope:          auth.Scope,
+		BindingMessage: auth.BindingMessage,
+		ApprovalURI:    approvalURI.String(),
+	}); err != nil {
+		log.Printf("ciba: %v\n", err)
+		writeError(w, openid.ErrServerError)
+		return
+	}
+
+	res := openid.BackchannelAuthenticationResponse{
+		AuthReqID: auth.ID,
+		ExpiresIn: int64(auth.ExpiresAt.Sub(auth.CreatedAt).Seconds()),
+	}
+	if auth.DeliveryMode != openid.CIBAPush {
+		res.Interval = int64(auth.Interval.Seconds())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// GetBackchannelApproval represents the page where the end-user approves or
+// denies the backchannel authentication request of a client.
+func (c *Core) GetBackchannelApproval(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	locale := negotiateLocale(c.template, r)
+	sess, err := c.session.GetSession(r)
+	if err != nil {
+		returnURL := encodeBase64(getHost(r).String())
+		http.Redirect(w, r, "/login?return_url="+returnURL, http.StatusFound)
+		return
+	}
+	ctx := openid.SetUserIDContextKey(r.Context(), sess.UserID)
+	auth, err := c.service.BackchannelApproval(ctx, r.URL.Query().Get("id"))
+	if err != nil {
+		writeError(w, localize(err, locale))
+		return
+	}
+
+	type response struct {
+		ApprovalID     string
+		ClientID       string
+		Scopes         []string
+		BindingMessage string
+	}
+	res := response{
+		ApprovalID:     auth.ApprovalID,
+		ClientID:       auth.ClientID,
+		Scopes:         strings.Fields(auth.Scope),
+		BindingMessage: auth.BindingMessage,
+	}
+	c.template.Render(w, "backchannel-approval", res, html5.Locale(locale))
+}
+
+// PostBackchannelApproval records the decision of the end-user, and notifies
+// the client if it registered the ping or push mode.
+func (c *Core) PostBackchannelApproval(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	locale := negotiateLocale(c.template, r)
+	sess, err := c.session.GetSession(r)
+	if err != nil {
+		writeError(w, openid.ErrLoginRequired.Localize(locale).WithStatus(http.StatusUnauthorized))
+		return
+	}
+	ctx := openid.SetUserIDContextKey(r.Context(), sess.UserID)
+	approved := r.FormValue("decision") == "allow"
+	auth, msg, err := c.service.CompleteBackchannelAuthentication(ctx, r.FormValue("id"), approved)
+	if err != nil {
+		writeError(w, localize(err, locale))
+		return
+	}
+
+	// The client is notified in the background, the end-user does not
+	// wait for it.
+	if msg != nil {
+		go func() {
+			if err := c.clientNotifier.Notify(context.Background(), auth.ClientNotificationEndpoint, auth.ClientNotificationToken, msg); err != nil {
+				log.Printf("ciba: %v\n", err)
+			}
+		}()
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// GetCheckSession represents the check_session_iframe, which the clients
+// embed to detect changes to the end-user's session without redirects.
+func (c *Core) GetCheckSession(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c.template.Render(w, "check-session", nil)
+}
+
+// GetEndSession represents the end_session endpoint for RP-initiated logout.
+// The end-user is asked to confirm the logout when the request does not
+// identify the user of the current session. The confirmation is only accepted
+// through POST, so that the logout cannot be forced by a link.
+func (c *Core) GetEndSession(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, openid.ErrInvalidRequest.WithDescription("request body is malformed"))
+		return
+	}
+	locale := c.template.Negotiate(r.Form.Get("ui_locales"), r.Header.Get("Accept-Language"))
+
+	var req openid.EndSessionRequest
+	if err := querystring.Decode(r.Form, &req); err != nil {
+		writeError(w, openid.ErrInvalidRequest)
+		return
+	}
+
+	ctx := r.Context()
+	sess, err := c.session.GetSession(r)
+	hasSession := err == nil
+	if hasSession {
+		ctx = openid.SetUserIDContextKey(ctx, sess.UserID)
+	}
+
+	res, err := c.service.EndSession(ctx, &req)
+	if err != nil {
+		writeError(w, localize(err, locale))
+		return
+	}
+
+	confirmed := r.Method == http.MethodPost && r.PostForm.Get("confirm") == "true"
+	if hasSession && res.Confirm && !confirmed {
+		data := req
+		data.ClientID = res.ClientID
+		c.template.Render(w, "end-session", data, html5.Locale(locale))
+		return
+	}
+
+	redirectURI := res.RedirectURI
+	if redirectURI == "" {
+		redirectURI = "/"
+	}
+	c.endSession(w, r, redirectURI, locale)
+}
+
+// PostLogout represents the logout of the end-user from our own pages. The
+// clients of the session are notified like in the end_session endpoint.
+func (c *Core) PostLogout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c.endSession(w, r, "/", negotiateLocale(c.template, r))
+}
+
+// endSession logs the user out, and notifies the clients of the session
+// through their front-channel logout uris before redirecting.
+func (c *Core) endSession(w http.ResponseWriter, r *http.Request, redirectURI, locale string) {
+	sess, err := c.session.GetSession(r)
+	hasSession := err == nil
+
+	c.logout(w, r)
+	if hasSession {
+		if uris := c.service.FrontChannelLogout(r.Context(), sess.SID, sess.ClientIDs); len(uris) > 0 {
+			type response struct {
+				LogoutURIs  []string
+				RedirectURI string
+			}
+			data := response{uris, redirectURI}
+			c.template.Render(w, "frontchannel-logout", data, html5.Locale(locale))
+			return
+		}
+	}
+	http.Redirect(w, r, redirectURI, http.StatusFound)
+}
+
+// ExpireSession notifies the clients of the expired session through the
+// back-channel. The front-channel is not used, since the end-user is not
+// present when the session expires.
+func (c *Core) ExpireSession(sess *session.Session) {
+	c.backChannelLogout(context.Background(), sess)
+}
+
+// writeDPoPError writes the error for an invalid DPoP proof. A new nonce is
+// provided when the proof did not contain a valid one.
+func (c *Core) writeDPoPError(w http.ResponseWriter, status int, err error) {
+	verr := openid.ErrInvalidDPoPProof.WithDescription(err.Error())
+	if err == dpop.ErrUseNonce {
+		nonce, nerr := c.dpop.Nonce()
+		if nerr != nil {
+			log.Printf("dpop: %v\n", nerr)
+			writeError(w, openid.ErrServerError)
+			return
+		}
+		w.Header().Set(dpop.NonceHeader, nonce)
+		verr = openid.ErrUseDPoPNonce.WithDescription(err.Error())
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`DPoP error="%s"`, verr.Code))
+	}
+	writeError(w, verr.WithStatus(status))
+}
+
 // -- options
 
 type coreOption func(*Core)
@@ -178,6 +833,36 @@ func CoreTemplate(h *html5.Template) coreOption {
 	}
 }
 
+// CoreDPoP sets the DPoP proof verifier for the Core controller.
+func CoreDPoP(v *dpop.Verifier) coreOption {
+	return func(c *Core) {
+		c.dpop = v
+	}
+}
+
+// CoreBackChannel sets the dispatcher of the back-channel logout tokens.
+func CoreBackChannel(d *backchannel.Dispatcher) coreOption {
+	return func(c *Core) {
+		c.backchannel = d
+	}
+}
+
+// CoreNotifier sets the notifier of the end-users' authentication devices
+// for the backchannel authentication.
+func CoreNotifier(n ciba.Notifier) coreOption {
+	return func(c *Core) {
+		c.notifier = n
+	}
+}
+
+// CoreIssuer sets the issuer identifier that is included in the
+// authorization responses.
+func CoreIssuer(issuer string) coreOption {
+	return func(c *Core) {
+		c.issuer = issuer
+	}
+}
+
 // CoreSession sets the session for the Core controller.
 func CoreSession(s *session.Manager) coreOption {
 	return func(c *Core) {
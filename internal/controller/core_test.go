@@ -8,10 +8,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/controller"
+	"github.com/alextanhongpin/go-openid/pkg/html5"
 	"github.com/alextanhongpin/go-openid/pkg/querystring"
 	"github.com/alextanhongpin/go-openid/pkg/session"
 	"github.com/alextanhongpin/go-openid/service"
@@ -19,6 +21,7 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestPostAuthorize(t *testing.T) {
@@ -38,37 +41,37 @@ func TestPostAuthorize(t *testing.T) {
 		}
 	)
 
-	// Setup context with injected values.
-	ctx := context.Background()
-	ctx = openid.SetUserIDContextKey(ctx, "john.doe@mail.com")
-
 	s := testdata.NewCoreService()
+	s.On("ValidateRedirectURI", req).Return(nil)
 	t.Run("call with valid parameters and invalid session", func(t *testing.T) {
-		s.On("Authenticate", context.Background(), req).Return(res, nil)
 		u := querystring.Encode(url.Values{}, req)
 		rr := corecurl(&s, false, "POST", "/authorize?"+u.Encode(), nil)
 
-		var (
-			code = http.StatusBadRequest
-			msg  = "http: named cookie not present"
-		)
+		assert.Equal(http.StatusFound, rr.Code, "should redirect the error to the client")
 
-		assert.Equal(code, rr.Code, "should equal response status found")
-
-		var res openid.ErrorJSON
-		err := json.NewDecoder(rr.Body).Decode(&res)
+		location, err := url.Parse(rr.Header().Get("Location"))
 		assert.Nil(err)
-		assert.Equal(msg, res.Code)
+		assert.Equal("client.example", location.Host)
+		assert.Equal("/cb", location.Path)
+
+		q := location.Query()
+		assert.Equal("login_required", q.Get("error"))
+		assert.Equal("xyz", q.Get("state"))
+		assert.Equal("http://localhost:8080", q.Get("iss"))
 	})
 
 	t.Run("call with valid parameters", func(t *testing.T) {
-		s.On("Authenticate", ctx, req).Return(res, nil)
+		s.On("Authenticate", mock.MatchedBy(func(c context.Context) bool {
+			userID, _ := openid.GetUserIDContextKey(c)
+			_, ok := openid.GetSessionContextKey(c)
+			return userID == "john.doe@mail.com" && ok
+		}), req).Return(res, nil)
 		u := querystring.Encode(url.Values{}, req)
 		rr := corecurl(&s, true, "POST", "/authorize?"+u.Encode(), nil)
 
 		var (
 			code     = http.StatusFound
-			location = "http://client.example/cb?code=code123&state=xyz"
+			location = "http://client.example/cb?code=code123&iss=http%3A%2F%2Flocalhost%3A8080&state=xyz"
 		)
 
 		assert.Equal(code, rr.Code, "should equal response status found")
@@ -76,22 +79,84 @@ func TestPostAuthorize(t *testing.T) {
 	})
 
 	t.Run("call with empty requests", func(t *testing.T) {
-		s.On("Authenticate", ctx, nil).Return(nil, errors.New("bad request"))
 		rr := corecurl(&s, true, "POST", "/authorize", nil)
 
-		var (
-			code = http.StatusUnprocessableEntity
-		)
-		assert.Equal(code, rr.Code, "should return status 400 - Bad Request")
+		assert.Equal(http.StatusBadRequest, rr.Code, "should return status 400 - Bad Request")
 
 		var res openid.ErrorJSON
 		err := json.NewDecoder(rr.Body).Decode(&res)
 		assert.Nil(err)
-		assert.Equal("request is empty", res.Code)
+		assert.Equal("invalid_request", res.Code)
+	})
+
+	t.Run("call without consent token", func(t *testing.T) {
+		u := querystring.Encode(url.Values{}, req)
+		rr := corecurl(&s, true, "POST", "/authorize?"+u.Encode(), strings.NewReader(""))
+
+		assert.Equal(http.StatusBadRequest, rr.Code, "should not accept the consent of other sites")
+		assert.Empty(rr.Header().Get("Location"))
+	})
+
+	t.Run("call with unregistered redirect uri", func(t *testing.T) {
+		bad := *req
+		bad.RedirectURI = "http://attacker.example/cb"
+		s.On("ValidateRedirectURI", &bad).Return(openid.ErrInvalidRequest.WithDescription("redirect_uri is not registered for the client"))
+		u := querystring.Encode(url.Values{}, &bad)
+		rr := corecurl(&s, true, "POST", "/authorize?"+u.Encode(), nil)
+
+		assert.Equal(http.StatusBadRequest, rr.Code, "should not redirect to an unregistered uri")
+		assert.Empty(rr.Header().Get("Location"))
 	})
 
 }
 
+func TestGetAuthorizeHintMismatch(t *testing.T) {
+	assert := assert.New(t)
+
+	req := &openid.AuthenticationRequest{
+		ClientID:     "1",
+		Scope:        "openid",
+		ResponseType: "code",
+		RedirectURI:  "http://client.example/cb",
+		State:        "xyz",
+		LoginHint:    "jane.doe@mail.com",
+	}
+	s := testdata.NewCoreService()
+	s.On("ValidateRedirectURI", req).Return(nil)
+	s.On("PreAuthenticate", req).Return(nil)
+	s.On("CheckSession", mock.Anything, req).Return(openid.ErrLoginRequired)
+
+	sess := session.NewManager()
+	ctl := controller.NewCore(
+		controller.CoreSession(sess),
+		controller.CoreService(&s),
+		controller.CoreTemplate(html5.New("../../cmd/server/templates", html5.Locales("en"))),
+	)
+	router := httprouter.New()
+	router.GET("/authorize", ctl.GetAuthorize)
+
+	login := httptest.NewRecorder()
+	sess.SetSession(login, "john.doe@mail.com")
+	cookie := login.Header().Get("Set-Cookie")
+
+	u := querystring.Encode(url.Values{}, req)
+	r := httptest.NewRequest("GET", "/authorize?"+u.Encode(), nil)
+	r.Header.Set("Cookie", cookie)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, r)
+
+	assert.Equal(http.StatusFound, rr.Code, "should redirect the error to the client")
+	location, err := url.Parse(rr.Header().Get("Location"))
+	assert.Nil(err)
+	assert.Equal("client.example", location.Host)
+	assert.Equal("login_required", location.Query().Get("error"))
+	assert.Empty(rr.Header().Get("Set-Cookie"), "should not clear the session cookie")
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", cookie)
+	assert.True(sess.HasSession(r), "should keep the session")
+}
+
 func corecurl(svc service.Core, enableSession bool, method, endpoint string, payload io.Reader) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 
@@ -112,11 +177,235 @@ func corecurl(svc service.Core, enableSession bool, method, endpoint string, pay
 	ctx := context.Background()
 	req := httptest.NewRequest(method, endpoint, payload)
 	req = req.WithContext(ctx)
-	// Set the cookie.
+	// Set the cookie, and submit the consent form of the session.
 	if enableSession {
 		req.Header.Set("Cookie", rr.HeaderMap["Set-Cookie"][0])
+		if s, err := sess.GetSession(req); err == nil && payload == nil {
+			form := url.Values{"consent_token": {s.CSRFToken(req.URL.Query().Encode())}}
+			req = httptest.NewRequest(method, endpoint, strings.NewReader(form.Encode())).WithContext(ctx)
+			req.Header.Set("Cookie", rr.HeaderMap["Set-Cookie"][0])
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGetUserInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testdata.NewCoreService()
+	s.On("UserInfo", mock.Anything, "token").Return(&openid.UserInfo{Subject: "1"}, nil)
+
+	t.Run("call with bearer token", func(t *testing.T) {
+		rr := userinfocurl(&s, "Bearer token", "")
+		assert.Equal(http.StatusOK, rr.Code)
+
+		var res openid.UserInfo
+		err := json.NewDecoder(rr.Body).Decode(&res)
+		assert.Nil(err)
+		assert.Equal("1", res.Subject)
+	})
+
+	t.Run("call with dpop token without proof", func(t *testing.T) {
+		rr := userinfocurl(&s, "DPoP token", "")
+		assert.Equal(http.StatusUnauthorized, rr.Code)
+		assert.Equal(`DPoP error="invalid_dpop_proof"`, rr.Header().Get("WWW-Authenticate"))
+	})
+}
+
+func userinfocurl(svc service.Core, authorization, proof string) *httptest.ResponseRecorder {
+	ctl := controller.NewCore(controller.CoreService(svc))
+
+	router := httprouter.New()
+	router.GET("/userinfo", ctl.GetUserInfo)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/userinfo", nil)
+	req.Header.Set("Authorization", authorization)
+	if proof != "" {
+		req.Header.Set("DPoP", proof)
 	}
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPostToken(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testdata.NewCoreService()
+	s.On("Token", mock.Anything, &openid.AccessTokenRequest{GrantType: "refresh_token", RefreshToken: "bad"}).
+		Return(nil, openid.ErrInvalidGrant.WithDescription("token is not a refresh token"))
+	s.On("Token", mock.Anything, &openid.AccessTokenRequest{GrantType: "refresh_token", RefreshToken: "token"}).
+		Return(nil, openid.ErrInvalidClient)
+	s.On("Token", mock.Anything, &openid.AccessTokenRequest{GrantType: "refresh_token", RefreshToken: "down"}).
+		Return(nil, errors.New("connection refused"))
+
+	t.Run("call with invalid grant", func(t *testing.T) {
+		rr := tokencurl(&s, `{"grant_type":"refresh_token","refresh_token":"bad"}`)
+		assert.Equal(http.StatusBadRequest, rr.Code)
+		assert.Equal("no-store", rr.Header().Get("Cache-Control"))
+
+		var res openid.ErrorJSON
+		err := json.NewDecoder(rr.Body).Decode(&res)
+		assert.Nil(err)
+		assert.Equal("invalid_grant", res.Code)
+		assert.Equal("token is not a refresh token", res.Description)
+	})
+
+	t.Run("call with invalid client", func(t *testing.T) {
+		rr := tokencurl(&s, `{"grant_type":"refresh_token","refresh_token":"token"}`)
+		assert.Equal(http.StatusUnauthorized, rr.Code)
+		assert.Equal(`Basic realm="openid"`, rr.Header().Get("WWW-Authenticate"))
+
+		var res openid.ErrorJSON
+		err := json.NewDecoder(rr.Body).Decode(&res)
+		assert.Nil(err)
+		assert.Equal("invalid_client", res.Code)
+	})
 
+	t.Run("call with form encoded body", func(t *testing.T) {
+		s.On("Token", mock.Anything, &openid.AccessTokenRequest{
+			GrantType:    "refresh_token",
+			RefreshToken: "form",
+			Resource:     []string{"https://a.example.com", "https://b.example.com"},
+			ClientCredentials: openid.ClientCredentials{
+				ClientID:     "1",
+				ClientSecret: "secret",
+			},
+		}).Return(&openid.AccessTokenResponse{AccessToken: "access"}, nil)
+
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {"form"},
+			"resource":      {"https://a.example.com", "https://b.example.com"},
+			"client_id":     {"1"},
+			"client_secret": {"secret"},
+		}
+		rr := tokenformcurl(&s, form)
+		assert.Equal(http.StatusOK, rr.Code)
+
+		var res openid.AccessTokenResponse
+		err := json.NewDecoder(rr.Body).Decode(&res)
+		assert.Nil(err)
+		assert.Equal("access", res.AccessToken)
+	})
+
+	t.Run("call with unexpected failure", func(t *testing.T) {
+		rr := tokencurl(&s, `{"grant_type":"refresh_token","refresh_token":"down"}`)
+		assert.Equal(http.StatusInternalServerError, rr.Code)
+
+		var res openid.ErrorJSON
+		err := json.NewDecoder(rr.Body).Decode(&res)
+		assert.Nil(err)
+		assert.Equal("server_error", res.Code)
+		assert.NotContains(res.Description, "connection refused", "should not reveal the error")
+	})
+}
+
+func tokenformcurl(svc service.Core, form url.Values) *httptest.ResponseRecorder {
+	ctl := controller.NewCore(controller.CoreService(svc))
+
+	router := httprouter.New()
+	router.POST("/token", ctl.PostToken)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func tokencurl(svc service.Core, body string) *httptest.ResponseRecorder {
+	ctl := controller.NewCore(controller.CoreService(svc))
+
+	router := httprouter.New()
+	router.POST("/token", ctl.PostToken)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(body))
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGetEndSession(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testdata.NewCoreService()
+	s.On("EndSession", mock.Anything, &openid.EndSessionRequest{ClientID: "1"}).
+		Return(&openid.EndSessionResponse{ClientID: "1"}, nil)
+	s.On("EndSession", mock.Anything, &openid.EndSessionRequest{
+		ClientID:              "1",
+		PostLogoutRedirectURI: "http://client.example/logout",
+		State:                 "xyz",
+	}).Return(&openid.EndSessionResponse{
+		ClientID:    "1",
+		RedirectURI: "http://client.example/logout?state=xyz",
+	}, nil)
+	s.On("EndSession", mock.Anything, &openid.EndSessionRequest{ClientID: "2"}).
+		Return(nil, openid.ErrInvalidRequest)
+
+	t.Run("call without post_logout_redirect_uri", func(t *testing.T) {
+		rr := endsessioncurl(&s, "/end_session?client_id=1")
+		assert.Equal(http.StatusFound, rr.Code)
+		assert.Equal("/", rr.Header().Get("Location"))
+	})
+
+	t.Run("call with post_logout_redirect_uri", func(t *testing.T) {
+		rr := endsessioncurl(&s, "/end_session?client_id=1&post_logout_redirect_uri=http%3A%2F%2Fclient.example%2Flogout&state=xyz")
+		assert.Equal(http.StatusFound, rr.Code)
+		assert.Equal("http://client.example/logout?state=xyz", rr.Header().Get("Location"))
+	})
+
+	t.Run("call with invalid request", func(t *testing.T) {
+		rr := endsessioncurl(&s, "/end_session?client_id=2")
+		assert.Equal(http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestPostLogout(t *testing.T) {
+	assert := assert.New(t)
+
+	sess := session.NewManager()
+	login := httptest.NewRecorder()
+	sess.SetSession(login, "john.doe@mail.com")
+	cookie := login.Header().Get("Set-Cookie")
+
+	r := httptest.NewRequest("POST", "/logout", nil)
+	r.Header.Set("Cookie", cookie)
+	current, err := sess.GetSession(r)
+	assert.Nil(err)
+	assert.Nil(sess.AddClient(current.SessionID, "1"))
+
+	s := testdata.NewCoreService()
+	s.On("FrontChannelLogout", mock.Anything, current.SID, []string{"1"}).Return(nil)
+	s.On("BackChannelLogout", mock.Anything, "john.doe@mail.com", current.SID, []string{"1"}).Return(nil)
+
+	ctl := controller.NewCore(
+		controller.CoreSession(sess),
+		controller.CoreService(&s),
+		controller.CoreTemplate(html5.New("../../cmd/server/templates", html5.Locales("en"))),
+	)
+	router := httprouter.New()
+	router.POST("/logout", ctl.PostLogout)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, r)
+	assert.Equal(http.StatusFound, rr.Code)
+	assert.Equal("/", rr.Header().Get("Location"))
+	assert.False(sess.HasSession(r), "should end the session")
+	s.AssertCalled(t, "BackChannelLogout", mock.Anything, "john.doe@mail.com", current.SID, []string{"1"})
+}
+
+func endsessioncurl(svc service.Core, endpoint string) *httptest.ResponseRecorder {
+	ctl := controller.NewCore(controller.CoreService(svc))
+
+	router := httprouter.New()
+	router.GET("/end_session", ctl.GetEndSession)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", endpoint, nil)
 	router.ServeHTTP(rr, req)
 	return rr
 }
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/alextanhongpin/go-openid/pkg/jwks"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Discovery represents the discovery controller.
+type Discovery struct {
+	metadata *openid.ProviderMetadata
+	keys     *jwks.KeySet
+}
+
+// NewDiscovery returns a new discovery controller.
+func NewDiscovery(opts ...discoveryOption) Discovery {
+	d := Discovery{
+		metadata: openid.NewProviderMetadata("http://localhost:8080"),
+		keys:     jwks.DefaultKeySet,
+	}
+	for _, o := range opts {
+		o(&d)
+	}
+	return d
+}
+
+type discoveryOption func(d *Discovery)
+
+// DiscoveryMetadata sets the provider metadata.
+func DiscoveryMetadata(m *openid.ProviderMetadata) discoveryOption {
+	return func(d *Discovery) {
+		d.metadata = m
+	}
+}
+
+// DiscoveryKeySet sets the keys that are published at the jwks_uri.
+func DiscoveryKeySet(keys *jwks.KeySet) discoveryOption {
+	return func(d *Discovery) {
+		d.keys = keys
+	}
+}
+
+// GetOpenIDConfiguration represents the provider configuration endpoint.
+func (d *Discovery) GetOpenIDConfiguration(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.metadata)
+}
+
+// GetJWKS represents the jwks_uri endpoint, which publishes the public keys
+// that the resources use to validate the access tokens.
+func (d *Discovery) GetJWKS(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	// The signing key is generated if none was configured, so that the
+	// key set is never empty.
+	if _, err := d.keys.SigningKey(); err != nil {
+		log.Printf("jwks: %v\n", err)
+		writeError(w, openid.ErrServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.keys.JSONWebKeySet())
+}
@@ -55,5 +55,5 @@ func (i *Index) GetIndex(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 	if sess != nil {
 		res.IsLoggedIn = true
 	}
-	i.template.Render(w, "index", res)
+	i.template.Render(w, "index", res, html5.Locale(negotiateLocale(i.template, r)))
 }
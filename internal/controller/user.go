@@ -2,11 +2,12 @@ package controller
 
 import (
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/internal/user"
 	"github.com/alextanhongpin/go-openid/pkg/appsensor"
 	"github.com/alextanhongpin/go-openid/pkg/crypto"
@@ -30,13 +31,33 @@ type (
 		template  *html5.Template
 		session   *session.Manager
 		appsensor appsensor.LoginDetector
+
+		// issuer is the only origin the user is redirected to after
+		// login.
+		issuer string
 	}
 )
 
+var (
+	errMalformedBody   = openid.ErrInvalidRequest.WithDescription("request body is malformed")
+	errTooManyAttempts = openid.ErrAccessDenied.WithDescription("too many attempts").WithStatus(http.StatusTooManyRequests)
+)
+
+// credentialsError returns the error of a failed login or registration as
+// invalid_request. The user service validates the credentials, and its
+// errors are meant to be shown to the end-user.
+func credentialsError(err error) error {
+	if _, ok := err.(*openid.ErrorJSON); ok {
+		return err
+	}
+	return openid.ErrInvalidRequest.WithDescription(err.Error())
+}
+
 // NewUser returns a new user controller with a predefined service.
 func NewUser(opts ...userOption) User {
 	u := User{
 		service: user.NewService(),
+		issuer:  "http://localhost:8080",
 	}
 	for _, o := range opts {
 		o(&u)
@@ -75,6 +96,14 @@ func UserAppSensor(a appsensor.LoginDetector) userOption {
 	}
 }
 
+// UserIssuer sets the issuer identifier, whose origin is the only one
+// allowed in the return url.
+func UserIssuer(issuer string) userOption {
+	return func(u *User) {
+		u.issuer = issuer
+	}
+}
+
 // type GetLoginResponse struct {
 //         ReturnURL string
 // }
@@ -86,6 +115,7 @@ func (u *User) GetLogin(w http.ResponseWriter, r *http.Request, _ httprouter.Par
 	// If yes, send it into the body as the request body.
 	type templateData struct {
 		ReturnURL string
+		LoginHint string
 	}
 
 	// TODO: The user might have a session, but the session has
@@ -106,12 +136,17 @@ func (u *User) GetLogin(w http.ResponseWriter, r *http.Request, _ httprouter.Par
 
 	uri, err := parseURI(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, openid.ErrInvalidRequest.WithDescription("return_url is invalid"))
 		return
 	}
 
-	d := templateData{uri}
-	u.template.Render(w, "login", d)
+	// Only email hints are used to pre-fill the login form, since the
+	// form only accepts email.
+	email, _ := openid.LoginHint(r.URL.Query().Get("login_hint")).Email()
+
+	d := templateData{uri, email}
+	display := r.URL.Query().Get("display")
+	u.template.Render(w, "login", d, html5.Locale(negotiateLocale(u.template, r)), html5.Display(display))
 }
 
 // PostLogin represents the post login endpoint.
@@ -127,15 +162,22 @@ func (u *User) PostLogin(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 	//         return
 	// }
 
+	// Devices without javascript, e.g. the wap display, post the
+	// credentials as a form and are redirected on success.
+	if isForm(r) {
+		u.postLoginForm(w, r)
+		return
+	}
+
 	var req Credentials
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, errMalformedBody)
 		return
 	}
 
 	// Check if the user is logging with many failed attempts.
 	if locked := u.appsensor.IsLocked(req.Email); locked {
-		writeError(w, http.StatusTooManyRequests, errors.New("too many attempts"))
+		writeError(w, errTooManyAttempts)
 		return
 	}
 
@@ -143,7 +185,7 @@ func (u *User) PostLogin(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 	if err != nil {
 		// Log attempts here.
 		u.appsensor.Increment(req.Email)
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, credentialsError(err))
 		return
 	}
 
@@ -163,7 +205,7 @@ func (u *User) PostLogin(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 
 	accessToken, err := provideToken(user.ID)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, err)
 		return
 	}
 
@@ -177,6 +219,60 @@ func (u *User) PostLogin(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 	})
 }
 
+// postLoginForm handles the login with form values, and redirects the user to
+// the return url on success.
+func (u *User) postLoginForm(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var (
+		email     = r.FormValue("email")
+		password  = r.FormValue("password")
+		returnURL = r.FormValue("return_url")
+	)
+	if locked := u.appsensor.IsLocked(email); locked {
+		writeError(w, errTooManyAttempts)
+		return
+	}
+	user, err := u.service.Login(email, password)
+	if err != nil {
+		u.appsensor.Increment(email)
+		writeError(w, credentialsError(err))
+		return
+	}
+	u.session.SetSession(w, user.ID)
+
+	// Only redirect to the issuer to avoid open redirects.
+	if !isLocalURL(u.issuer, returnURL) {
+		returnURL = "/"
+	}
+	http.Redirect(w, r, returnURL, http.StatusFound)
+}
+
+// isLocalURL checks if the uri is either a path, or an absolute url with
+// the same origin as the issuer. Browsers treat backslashes as slashes, so
+// "/\evil.com" is a protocol-relative url just like "//evil.com".
+func isLocalURL(issuer, uri string) bool {
+	if uri == "" || strings.Contains(uri, `\`) {
+		return false
+	}
+	for _, r := range uri {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	if uri[0] == '/' {
+		return len(uri) == 1 || uri[1] != '/'
+	}
+	target, err := url.Parse(uri)
+	if err != nil || target.User != nil || target.Opaque != "" {
+		return false
+	}
+	origin, err := url.Parse(issuer)
+	if err != nil {
+		return false
+	}
+	return target.Scheme == origin.Scheme && target.Host == origin.Host
+}
+
 // GetRegister renders the user registration page.
 func (u *User) GetRegister(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	// If the user already has a session (is logged in), redirect
@@ -185,7 +281,7 @@ func (u *User) GetRegister(w http.ResponseWriter, r *http.Request, _ httprouter.
 	//         http.Redirect(w, r, "/", http.StatusFound)
 	//         return
 	// }
-	u.template.Render(w, "register", nil)
+	u.template.Render(w, "register", nil, html5.Locale(negotiateLocale(u.template, r)))
 }
 
 // PostRegister represents the post register endpoint.
@@ -208,13 +304,13 @@ func (u *User) PostRegister(w http.ResponseWriter, r *http.Request, _ httprouter
 
 	var req Credentials
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, errMalformedBody)
 		return
 	}
 
 	user, err := u.service.Register(req.Email, req.Password)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, credentialsError(err))
 		return
 	}
 
@@ -234,7 +330,7 @@ func (u *User) PostRegister(w http.ResponseWriter, r *http.Request, _ httprouter
 
 	accessToken, err := provideToken(user.ID)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, err)
 		return
 	}
 
@@ -245,21 +341,3 @@ func (u *User) PostRegister(w http.ResponseWriter, r *http.Request, _ httprouter
 		"access_token": accessToken,
 	})
 }
-
-// PostLogout logs the user out of the session.
-func (u *User) PostLogout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	cookie, err := r.Cookie(session.Key)
-	if err != nil {
-		// ErrNoCookie should be handled as success.
-		writeError(w, http.StatusBadRequest, err)
-		return
-	}
-
-	if err := u.session.Delete(cookie.Value); err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
-	}
-
-	// TODO: Look into the PRG pattern.
-	http.Redirect(w, r, "/", http.StatusFound)
-}
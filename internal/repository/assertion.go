@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrAssertionReplayed is returned when the jti of the assertion has already
+// been used.
+var ErrAssertionReplayed = errors.New("jti has already been used")
+
+// AssertionKV represents the in-memory store of the client assertion ids that
+// have been used, to prevent the assertions from being replayed.
+type AssertionKV struct {
+	sync.Mutex
+	// Maps the client id and jti to the expiry of the assertion.
+	db map[string]time.Time
+}
+
+// NewAssertionKV returns a new assertion key-value store.
+func NewAssertionKV() *AssertionKV {
+	return &AssertionKV{
+		db: make(map[string]time.Time),
+	}
+}
+
+// Put records the jti of the client until the assertion expires. An error is
+// returned if the jti has already been used.
+func (a *AssertionKV) Put(clientID, jti string, exp time.Time) error {
+	a.Lock()
+	defer a.Unlock()
+
+	now := time.Now()
+	// Expired assertions are rejected by the expiry check, so they no
+	// longer need to be remembered.
+	for k, v := range a.db {
+		if now.After(v) {
+			delete(a.db, k)
+		}
+	}
+	key := clientID + " " + jti
+	if _, exist := a.db[key]; exist {
+		return ErrAssertionReplayed
+	}
+	a.db[key] = exp
+	return nil
+}
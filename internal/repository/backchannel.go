@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/alextanhongpin/go-openid"
+)
+
+// BackchannelKV represents the in-memory store of the pending backchannel
+// authentication requests.
+type BackchannelKV struct {
+	sync.RWMutex
+	db map[string]openid.BackchannelAuthentication
+
+	// Maps the approval id to the auth_req_id.
+	approvals map[string]string
+}
+
+// NewBackchannelKV returns a new backchannel authentication key-value store.
+func NewBackchannelKV() *BackchannelKV {
+	return &BackchannelKV{
+		db:        make(map[string]openid.BackchannelAuthentication),
+		approvals: make(map[string]string),
+	}
+}
+
+// Get returns the request with the given auth_req_id.
+func (b *BackchannelKV) Get(id string) (*openid.BackchannelAuthentication, error) {
+	b.RLock()
+	auth, exist := b.db[id]
+	b.RUnlock()
+	if !exist {
+		return nil, errors.New("backchannel authentication does not exist")
+	}
+	return &auth, nil
+}
+
+// GetByApprovalID returns the request with the given approval id.
+func (b *BackchannelKV) GetByApprovalID(approvalID string) (*openid.BackchannelAuthentication, error) {
+	b.RLock()
+	id := b.approvals[approvalID]
+	b.RUnlock()
+	return b.Get(id)
+}
+
+// Put stores the request.
+func (b *BackchannelKV) Put(auth *openid.BackchannelAuthentication) error {
+	b.Lock()
+	b.db[auth.ID] = *auth
+	b.approvals[auth.ApprovalID] = auth.ID
+	b.Unlock()
+	return nil
+}
+
+// Delete removes the request.
+func (b *BackchannelKV) Delete(id string) error {
+	b.Lock()
+	if auth, exist := b.db[id]; exist {
+		delete(b.approvals, auth.ApprovalID)
+		delete(b.db, id)
+	}
+	b.Unlock()
+	return nil
+}
+
+// DeleteByClient removes the pending requests of the client.
+func (b *BackchannelKV) DeleteByClient(clientID string) error {
+	b.Lock()
+	for id, auth := range b.db {
+		if auth.ClientID == clientID {
+			delete(b.approvals, auth.ApprovalID)
+			delete(b.db, id)
+		}
+	}
+	b.Unlock()
+	return nil
+}
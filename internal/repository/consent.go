@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/alextanhongpin/go-openid"
+)
+
+// ConsentKV represents the in-memory store of the consents that the users
+// granted to the clients.
+type ConsentKV struct {
+	sync.RWMutex
+	// Maps the user id and client id to the consent.
+	db map[string]openid.Consent
+}
+
+// NewConsentKV returns a new consent key-value store.
+func NewConsentKV() *ConsentKV {
+	return &ConsentKV{
+		db: make(map[string]openid.Consent),
+	}
+}
+
+func (c *ConsentKV) key(userID, clientID string) string {
+	return userID + " " + clientID
+}
+
+// Get returns the consent of the user for the client.
+func (c *ConsentKV) Get(userID, clientID string) (*openid.Consent, error) {
+	c.RLock()
+	consent, exist := c.db[c.key(userID, clientID)]
+	c.RUnlock()
+	if !exist {
+		return nil, errors.New("consent does not exist")
+	}
+	return &consent, nil
+}
+
+// Put stores the consent, replacing the previous consent of the user for the
+// client.
+func (c *ConsentKV) Put(consent *openid.Consent) error {
+	c.Lock()
+	c.db[c.key(consent.UserID, consent.ClientID)] = *consent
+	c.Unlock()
+	return nil
+}
+
+// Delete removes the consent of the user for the client.
+func (c *ConsentKV) Delete(userID, clientID string) error {
+	c.Lock()
+	delete(c.db, c.key(userID, clientID))
+	c.Unlock()
+	return nil
+}
+
+// DeleteByClient removes the consents of all users for the client.
+func (c *ConsentKV) DeleteByClient(clientID string) error {
+	c.Lock()
+	for k, consent := range c.db {
+		if consent.ClientID == clientID {
+			delete(c.db, k)
+		}
+	}
+	c.Unlock()
+	return nil
+}
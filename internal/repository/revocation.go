@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationKV represents the in-memory store of the ids of the revoked
+// tokens. The refresh tokens and the signed access tokens are not stored, so
+// their ids are remembered instead until the tokens expire.
+type RevocationKV struct {
+	sync.RWMutex
+	// Maps the jti to the expiry of the token.
+	db map[string]time.Time
+}
+
+// NewRevocationKV returns a new revocation key-value store.
+func NewRevocationKV() *RevocationKV {
+	return &RevocationKV{
+		db: make(map[string]time.Time),
+	}
+}
+
+// Put revokes the token with the given jti until it expires.
+func (r *RevocationKV) Put(jti string, exp time.Time) error {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	// Expired tokens are rejected by the expiry check, so they no longer need
+	// to be remembered.
+	for k, v := range r.db {
+		if now.After(v) {
+			delete(r.db, k)
+		}
+	}
+	r.db[jti] = exp
+	return nil
+}
+
+// Has returns true if the token with the given jti has been revoked.
+func (r *RevocationKV) Has(jti string) bool {
+	r.RLock()
+	_, exist := r.db[jti]
+	r.RUnlock()
+	return exist
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+)
+
+// SubjectKV represents the in-memory store that maps pairwise subject
+// identifiers back to the local user id.
+type SubjectKV struct {
+	sync.RWMutex
+	// Maps the sector identifier and pairwise subject to the user id.
+	db map[string]string
+}
+
+// NewSubjectKV returns a new subject key-value store.
+func NewSubjectKV() *SubjectKV {
+	return &SubjectKV{
+		db: make(map[string]string),
+	}
+}
+
+func (s *SubjectKV) key(sector, sub string) string {
+	return sector + " " + sub
+}
+
+// Put stores the user id of the pairwise subject in the given sector.
+func (s *SubjectKV) Put(sector, sub, userID string) error {
+	s.Lock()
+	s.db[s.key(sector, sub)] = userID
+	s.Unlock()
+	return nil
+}
+
+// Get returns the user id of the pairwise subject in the given sector.
+func (s *SubjectKV) Get(sector, sub string) (string, error) {
+	s.RLock()
+	userID, exist := s.db[s.key(sector, sub)]
+	s.RUnlock()
+	if !exist {
+		return "", errors.New("subject does not exist")
+	}
+	return userID, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/alextanhongpin/go-openid"
+)
+
+// TokenKV represents the in-memory store of the reference tokens.
+type TokenKV struct {
+	sync.RWMutex
+	db map[string]openid.ReferenceToken
+}
+
+// NewTokenKV returns a new reference token key-value store.
+func NewTokenKV() *TokenKV {
+	return &TokenKV{
+		db: make(map[string]openid.ReferenceToken),
+	}
+}
+
+// Get returns the reference token with the given id.
+func (t *TokenKV) Get(id string) (*openid.ReferenceToken, error) {
+	t.RLock()
+	token, exist := t.db[id]
+	t.RUnlock()
+	if !exist {
+		return nil, errors.New("token does not exist")
+	}
+	return &token, nil
+}
+
+// Put stores the reference token.
+func (t *TokenKV) Put(token *openid.ReferenceToken) error {
+	t.Lock()
+	t.db[token.ID] = *token
+	t.Unlock()
+	return nil
+}
+
+// Delete removes the reference token.
+func (t *TokenKV) Delete(id string) error {
+	t.Lock()
+	delete(t.db, id)
+	t.Unlock()
+	return nil
+}
+
+// DeleteByClient removes the reference tokens issued to the client.
+func (t *TokenKV) DeleteByClient(clientID string) error {
+	t.Lock()
+	for id, token := range t.db {
+		if token.ClientID == clientID {
+			delete(t.db, id)
+		}
+	}
+	t.Unlock()
+	return nil
+}
@@ -1,9 +1,14 @@
 package service
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
+	"fmt"
+	"net/url"
 	"time"
 
+	openid "github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/domain/client"
 	"github.com/alextanhongpin/go-openid/pkg/gostrings"
 	"github.com/alextanhongpin/go-openid/pkg/randstr"
@@ -11,9 +16,27 @@ import (
 	"github.com/rs/xid"
 )
 
+// ClientRevoker revokes what was granted to a client when the client is
+// deleted.
+type ClientRevoker interface {
+	RevokeClient(ctx context.Context, clientID string) error
+}
+
 type Client struct {
+	accessTokenDuration  time.Duration
+	refreshTokenDuration time.Duration
+
 	clients client.Repository
 	signer  *signer.Signer
+	sectors SectorIdentifierFetcher
+
+	// revokers revoke the consents, tokens and sessions of the deleted
+	// clients.
+	revokers []ClientRevoker
+
+	// registrationEndpoint is the url of the client registration endpoint,
+	// which is also used to build the registration_client_uri.
+	registrationEndpoint string
 }
 
 func NewClient(clients client.Repository, accessTokenDuration, refreshTokenDuration time.Duration, signer *signer.Signer) *Client {
@@ -22,7 +45,167 @@ func NewClient(clients client.Repository, accessTokenDuration, refreshTokenDurat
 		refreshTokenDuration: refreshTokenDuration,
 		clients:              clients,
 		signer:               signer,
+		sectors:              NewHTTPSectorIdentifierFetcher(5 * time.Second),
+		registrationEndpoint: "http://localhost:8080/connect/register",
+	}
+}
+
+// SetRegistrationEndpoint sets the url of the client registration endpoint.
+func (c *Client) SetRegistrationEndpoint(uri string) {
+	c.registrationEndpoint = uri
+}
+
+// SetSectorIdentifierFetcher sets the fetcher for the sector identifier
+// documents.
+func (c *Client) SetSectorIdentifierFetcher(sectors SectorIdentifierFetcher) {
+	c.sectors = sectors
+}
+
+// AddClientRevoker adds a revoker that is called when a client is deleted.
+func (c *Client) AddClientRevoker(r ClientRevoker) {
+	c.revokers = append(c.revokers, r)
+}
+
+// Register validates the client metadata, provides the client credentials
+// and stores the client.
+func (c *Client) Register(ctx context.Context, client *openid.Client) (*openid.Client, error) {
+	if err := c.ValidateRedirectURIs(client); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateLogoutURIs(client); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateBackchannelTokenDelivery(client); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateSubjectType(ctx, client); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateTokenEndpointAuthMethod(client); err != nil {
+		return nil, err
+	}
+	if err := c.ProvideCredentials(client); err != nil {
+		return nil, err
+	}
+	if _, err := c.clients.Create(*client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// ValidateRedirectURIs validates that at least one redirect uri is
+// registered, and that each of them is an absolute uri without a fragment.
+func (c *Client) ValidateRedirectURIs(client *openid.Client) error {
+	if len(client.RedirectURIs) == 0 {
+		return openid.ErrInvalidRedirectURI.WithDescription("redirect_uris is required")
+	}
+	for _, uri := range client.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() {
+			return openid.ErrInvalidRedirectURI.WithDescription(fmt.Sprintf("redirect_uri %s must be an absolute uri", uri))
+		}
+		if u.Fragment != "" {
+			return openid.ErrInvalidRedirectURI.WithDescription(fmt.Sprintf("redirect_uri %s must not contain a fragment", uri))
+		}
+		// Web clients using the implicit grant must only register
+		// https redirect uris.
+		if client.ApplicationType == "web" && hasImplicitGrant(client) && u.Scheme != "https" {
+			return openid.ErrInvalidRedirectURI.WithDescription(fmt.Sprintf("redirect_uri %s must use the https scheme", uri))
+		}
+	}
+	return nil
+}
+
+// ValidateLogoutURIs validates that the post_logout_redirect_uris and the
+// logout uris are absolute uris without a fragment.
+func (c *Client) ValidateLogoutURIs(client *openid.Client) error {
+	for _, uri := range client.PostLogoutRedirectURIs {
+		if !isAbsoluteURI(uri) {
+			return openid.ErrInvalidClientMetadata.WithDescription(fmt.Sprintf("post_logout_redirect_uri %s must be an absolute uri without a fragment", uri))
+		}
+	}
+	if uri := client.FrontchannelLogoutURI; uri != "" {
+		if !isAbsoluteURI(uri) {
+			return openid.ErrInvalidClientMetadata.WithDescription("frontchannel_logout_uri must be an absolute uri without a fragment")
+		}
+	}
+	if uri := client.BackchannelLogoutURI; uri != "" {
+		if !isAbsoluteURI(uri) {
+			return openid.ErrInvalidClientMetadata.WithDescription("backchannel_logout_uri must be an absolute uri without a fragment")
+		}
+	}
+	return nil
+}
+
+// ValidateBackchannelTokenDelivery validates the token delivery mode of the
+// backchannel authentication, and that the ping and push modes register an
+// absolute client notification endpoint.
+func (c *Client) ValidateBackchannelTokenDelivery(client *openid.Client) error {
+	mode := client.BackchannelTokenDeliveryMode
+	if mode == "" {
+		return nil
+	}
+	if !openid.IsValidCIBADeliveryMode(mode) {
+		return openid.ErrInvalidClientMetadata.WithDescription("backchannel_token_delivery_mode is not supported")
+	}
+	if mode == openid.CIBAPoll {
+		return nil
+	}
+	if !isAbsoluteURI(client.BackchannelClientNotificationEndpoint) {
+		return openid.ErrInvalidClientMetadata.WithDescription(fmt.Sprintf("backchannel_client_notification_endpoint is required for the %s mode", mode))
+	}
+	return nil
+}
+
+// ValidateTokenEndpointAuthMethod validates that the authentication method is
+// supported, and that the keys for private_key_jwt are registered.
+func (c *Client) ValidateTokenEndpointAuthMethod(client *openid.Client) error {
+	if !openid.IsValidTokenEndpointAuthMethod(client.TokenEndpointAuthMethod) {
+		return openid.ErrInvalidClientMetadata.WithDescription("token_endpoint_auth_method is not supported")
+	}
+	if client.Jwks != "" && client.JwksURI != "" {
+		return openid.ErrInvalidClientMetadata.WithDescription("jwks and jwks_uri cannot both be registered")
+	}
+	switch client.TokenEndpointAuthMethod {
+	case openid.PrivateKeyJWT, openid.SelfSignedTLSClientAuth:
+		if client.Jwks == "" && client.JwksURI == "" {
+			return openid.ErrInvalidClientMetadata.WithDescription(fmt.Sprintf("jwks or jwks_uri is required for %s", client.TokenEndpointAuthMethod))
+		}
+	case openid.TLSClientAuth:
+		var n int
+		for _, v := range []string{
+			client.TLSClientAuthSubjectDN,
+			client.TLSClientAuthSANDNS,
+			client.TLSClientAuthSANURI,
+			client.TLSClientAuthSANIP,
+			client.TLSClientAuthSANEmail,
+		} {
+			if v != "" {
+				n++
+			}
+		}
+		if n != 1 {
+			return openid.ErrInvalidClientMetadata.WithDescription("exactly one tls_client_auth subject is required for tls_client_auth")
+		}
+	}
+	return nil
+}
+
+// ValidateSubjectType validates the subject_type, and for pairwise clients,
+// that a sector identifier can be derived and that the sector identifier
+// document lists all the redirect_uris.
+func (c *Client) ValidateSubjectType(ctx context.Context, client *openid.Client) error {
+	switch client.SubjectType {
+	case "", openid.SubjectTypePublic:
+		return nil
+	case openid.SubjectTypePairwise:
+	default:
+		return openid.ErrInvalidClientMetadata.WithDescription("subject_type must be public or pairwise")
+	}
+	if _, err := client.SectorIdentifier(); err != nil {
+		return openid.ErrInvalidClientMetadata.WithDescription(err.Error())
 	}
+	return validateSectorIdentifier(ctx, c.sectors, client)
 }
 
 func (c *Client) Validate(client openid.Client) error {
@@ -40,29 +223,154 @@ func (c *Client) Validate(client openid.Client) error {
 	return nil
 }
 
-func (c *Client) ProvideCredentials(client *openid.Client) {
+func (c *Client) ProvideCredentials(client *openid.Client) error {
 	now := time.Now().UTC()
 	client.ClientID = xid.New().String()
-	client.ClientSecret = randstr.RandomString(32)
-	client.RegistrationAccessToken = ""
+	secret, err := randstr.RandomString(32)
+	if err != nil {
+		return err
+	}
+	client.ClientSecret = secret
+	// Public clients do not authenticate, and are not issued a secret.
+	if client.TokenEndpointAuthMethod == openid.None {
+		client.ClientSecret = ""
+	}
 	client.ClientIDIssuedAt = now.Unix()
 	client.ClientSecretExpiresAt = 0
-	client.RegistrationClientURI = "https://server.example.com/c2id/clients"
-	{
-		var (
-			aud = "https://server.example.com/c2id/clients"
-			sub = clientID
-			iss = clientID
-
-			iat = now
-			day = time.Hour * 24
-			exp = iat.Add(7 * day)
-			key = []byte("client_token_secret")
-		)
-		claims := signer.NewStandardClaims(aud, sub, iss, iat.Unix(), exp.Unix())
-		accessToken, err := c.signer.Sign(claims)
-		client.RegistrationAccessToken = accessToken
+	client.RegistrationClientURI = c.registrationEndpoint + "/" + url.PathEscape(client.ClientID)
+	return c.provideRegistrationAccessToken(client)
+}
+
+// provideRegistrationAccessToken issues a new registration access token for
+// the client. The previous token is no longer accepted once the client is
+// stored.
+func (c *Client) provideRegistrationAccessToken(client *openid.Client) error {
+	var (
+		aud = c.registrationEndpoint
+		sub = client.ClientID
+		iss = client.ClientID
+
+		iat = time.Now().UTC()
+		day = time.Hour * 24
+		exp = iat.Add(7 * day)
+	)
+	claims := signer.NewStandardClaims(aud, sub, iss, iat.Unix(), exp.Unix())
+	// Ensures that the rotated token differs even when issued within the
+	// same second.
+	claims.Id = xid.New().String()
+	accessToken, err := c.signer.Sign(claims)
+	if err != nil {
 		return err
 	}
+	client.RegistrationAccessToken = accessToken
 	return nil
 }
+
+// Authorize returns the client if the registration access token is the one
+// most recently issued to the client.
+func (c *Client) Authorize(clientID, registrationAccessToken string) (*openid.Client, error) {
+	claims, err := c.signer.Parse(registrationAccessToken)
+	if err != nil || claims.Subject != clientID {
+		return nil, openid.ErrInvalidToken
+	}
+	client, err := c.clients.Get(clientID)
+	if err != nil {
+		return nil, openid.ErrInvalidToken
+	}
+	if subtle.ConstantTimeCompare([]byte(client.RegistrationAccessToken), []byte(registrationAccessToken)) != 1 {
+		return nil, openid.ErrInvalidToken
+	}
+	return client, nil
+}
+
+// Read returns the current configuration of the client.
+func (c *Client) Read(ctx context.Context, clientID, registrationAccessToken string) (*openid.Client, error) {
+	return c.Authorize(clientID, registrationAccessToken)
+}
+
+// Update replaces the metadata of the client with the given metadata. Fields
+// that are issued by the server cannot be changed, and the client secret is
+// only rotated on request. The registration access token is rotated on every
+// update.
+func (c *Client) Update(ctx context.Context, clientID, registrationAccessToken string, client *openid.Client, rotateSecret bool) (*openid.Client, error) {
+	curr, err := c.Authorize(clientID, registrationAccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if client.ClientID != clientID {
+		return nil, openid.ErrInvalidRequest.WithDescription("client_id does not match")
+	}
+	if client.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(client.ClientSecret), []byte(curr.ClientSecret)) != 1 {
+		return nil, openid.ErrInvalidRequest.WithDescription("client_secret does not match")
+	}
+	if err := c.ValidateRedirectURIs(client); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateLogoutURIs(client); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateBackchannelTokenDelivery(client); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateSubjectType(ctx, client); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateTokenEndpointAuthMethod(client); err != nil {
+		return nil, err
+	}
+
+	client.ClientSecret = curr.ClientSecret
+	client.ClientIDIssuedAt = curr.ClientIDIssuedAt
+	client.ClientSecretExpiresAt = curr.ClientSecretExpiresAt
+	client.RegistrationClientURI = curr.RegistrationClientURI
+	if rotateSecret || client.ClientSecret == "" {
+		secret, err := randstr.RandomString(32)
+		if err != nil {
+			return nil, err
+		}
+		client.ClientSecret = secret
+	}
+	if client.TokenEndpointAuthMethod == openid.None {
+		client.ClientSecret = ""
+	}
+	if err := c.provideRegistrationAccessToken(client); err != nil {
+		return nil, err
+	}
+	if err := c.clients.Update(*client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Delete removes the client, and revokes the consents, the pending requests,
+// the stored tokens and the sessions of the client. The revokers are called
+// even if one of them fails, so that as much as possible is revoked.
+func (c *Client) Delete(ctx context.Context, clientID, registrationAccessToken string) error {
+	if _, err := c.Authorize(clientID, registrationAccessToken); err != nil {
+		return err
+	}
+	if err := c.clients.Delete(clientID); err != nil {
+		return err
+	}
+	var result error
+	for _, r := range c.revokers {
+		if err := r.RevokeClient(ctx, clientID); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+func isAbsoluteURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.IsAbs() && u.Fragment == ""
+}
+
+func hasImplicitGrant(client *openid.Client) bool {
+	for _, grantType := range client.GrantTypes {
+		if grantType == "implicit" {
+			return true
+		}
+	}
+	return false
+}
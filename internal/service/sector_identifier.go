@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	openid "github.com/alextanhongpin/go-openid"
+)
+
+// maxSectorIdentifierSize is the maximum size of the sector identifier
+// document that is read.
+const maxSectorIdentifierSize = 1 << 20
+
+var errSectorIdentifierScheme = errors.New("sector_identifier_uri must use the https scheme")
+
+// SectorIdentifierFetcher fetches the redirect uris listed in the sector
+// identifier document of a client.
+type SectorIdentifierFetcher interface {
+	Fetch(ctx context.Context, uri string) ([]string, error)
+}
+
+// httpSectorIdentifierFetcher fetches the sector identifier document over
+// https.
+type httpSectorIdentifierFetcher struct {
+	client *http.Client
+}
+
+// NewHTTPSectorIdentifierFetcher returns a fetcher that retrieves the sector
+// identifier document with the given timeout. Redirects are only followed to
+// https uris.
+func NewHTTPSectorIdentifierFetcher(timeout time.Duration) *httpSectorIdentifierFetcher {
+	return &httpSectorIdentifierFetcher{
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Scheme != "https" {
+					return errSectorIdentifierScheme
+				}
+				if len(via) >= 10 {
+					return errors.New("sector_identifier_uri has too many redirects")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Fetch returns the json array of redirect uris in the sector identifier
+// document. The uri must use https, and only the first
+// maxSectorIdentifierSize bytes of the document are read.
+func (f *httpSectorIdentifierFetcher) Fetch(ctx context.Context, uri string) ([]string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "https" {
+		return nil, errSectorIdentifierScheme
+	}
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sector_identifier_uri returned status %d", resp.StatusCode)
+	}
+	var redirectURIs []string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSectorIdentifierSize)).Decode(&redirectURIs); err != nil {
+		return nil, errors.New("sector_identifier_uri must return a json array of redirect_uris")
+	}
+	return redirectURIs, nil
+}
+
+// validateSectorIdentifier checks that every redirect uri registered by the
+// client is listed in the sector identifier document.
+func validateSectorIdentifier(ctx context.Context, fetcher SectorIdentifierFetcher, client *openid.Client) error {
+	if client.SectorIdentifierURI == "" {
+		return nil
+	}
+	u, err := url.Parse(client.SectorIdentifierURI)
+	if err != nil || u.Scheme != "https" {
+		return openid.ErrInvalidClientMetadata.WithDescription(errSectorIdentifierScheme.Error())
+	}
+	redirectURIs, err := fetcher.Fetch(ctx, client.SectorIdentifierURI)
+	if err != nil {
+		return openid.ErrInvalidClientMetadata.WithDescription(err.Error())
+	}
+	registered := make(map[string]struct{})
+	for _, uri := range redirectURIs {
+		registered[uri] = struct{}{}
+	}
+	for _, uri := range client.RedirectURIs {
+		if _, ok := registered[uri]; !ok {
+			msg := fmt.Sprintf("redirect_uri %s is not listed in the sector_identifier_uri", uri)
+			return openid.ErrInvalidRedirectURI.WithDescription(msg)
+		}
+	}
+	return nil
+}
@@ -2,32 +2,115 @@ package core
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/internal/database"
 	"github.com/alextanhongpin/go-openid/pkg/authheader"
 	"github.com/alextanhongpin/go-openid/pkg/crypto"
+	"github.com/alextanhongpin/go-openid/pkg/jwks"
+	"github.com/alextanhongpin/go-openid/pkg/mtls"
+	"github.com/alextanhongpin/go-openid/pkg/randstr"
 	"github.com/alextanhongpin/go-openid/repository"
 
 	"github.com/asaskevich/govalidator"
 	jwt "github.com/dgrijalva/jwt-go"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+var (
+	idTokenKey     = []byte("id_token_key")
+	accessTokenKey = []byte("access_token_secret")
 )
 
 type modelImpl struct {
-	code   repository.Code
-	client repository.Client
-	user   repository.User
+	code      repository.Code
+	client    repository.Client
+	user      repository.User
+	subject   repository.Subject
+	assertion repository.Assertion
+	consent   repository.Consent
+	token     repository.Token
+
+	backchannel repository.Backchannel
+	revocation  repository.Revocation
+
+	// issuer is the issuer identifier of the provider.
+	issuer string
+
+	// scopes are the scopes that the clients can request.
+	scopes *openid.ScopeRegistry
+
+	// claims are the providers of the custom claims.
+	claims *openid.ClaimsProviders
+
+	// resources are the protected APIs that access tokens are issued for.
+	resources *openid.ResourceRegistry
+
+	// details are the types of authorization details that the clients can
+	// request.
+	details *openid.AuthorizationDetailTypes
+
+	// keys are the provider's keys that sign the access tokens.
+	keys *jwks.KeySet
+
+	// exchange are the policies of the token exchange grant.
+	exchange *openid.TokenExchangePolicies
+
+	// issuers are the issuers trusted by the JWT bearer grant.
+	issuers *openid.TrustedIssuers
+
+	// tokenEndpoint is the url of the token endpoint, which must be the
+	// audience of the client assertions.
+	tokenEndpoint string
+
+	// clientCAs are the certificate authorities that issue the client
+	// certificates for tls_client_auth.
+	clientCAs *x509.CertPool
+
+	// pairwiseSalt is the salt used to derive the pairwise subject
+	// identifiers. Changing it changes the subject of every user.
+	pairwiseSalt []byte
+
+	// decryptionKey is the provider's private key, used to decrypt
+	// id_token_hint that are encrypted with an asymmetric algorithm.
+	decryptionKey interface{}
 }
 
 // NewModel returns a new model.
 func NewModel(opts ...modelOption) modelImpl {
 	m := modelImpl{
-		code:   database.NewCodeKV(),
-		client: database.NewClientKV(),
-		user:   database.NewUserKV(),
+		code:          database.NewCodeKV(),
+		client:        database.NewClientKV(),
+		user:          database.NewUserKV(),
+		subject:       database.NewSubjectKV(),
+		assertion:     database.NewAssertionKV(),
+		consent:       database.NewConsentKV(),
+		token:         database.NewTokenKV(),
+		backchannel:   database.NewBackchannelKV(),
+		revocation:    database.NewRevocationKV(),
+		issuer:        "http://localhost:8080",
+		scopes:        openid.DefaultScopeRegistry,
+		claims:        openid.DefaultClaimsProviders,
+		resources:     openid.DefaultResourceRegistry,
+		details:       openid.DefaultAuthorizationDetailTypes,
+		keys:          jwks.DefaultKeySet,
+		exchange:      openid.DefaultTokenExchangePolicies,
+		issuers:       openid.DefaultTrustedIssuers,
+		tokenEndpoint: "http://localhost:8080/token",
 	}
 	for _, o := range opts {
 		o(&m)
@@ -54,6 +137,142 @@ func ModelCodeRepository(code repository.Code) modelOption {
 	}
 }
 
+// ModelSubjectRepository sets the repository of the pairwise subjects.
+func ModelSubjectRepository(subject repository.Subject) modelOption {
+	return func(m *modelImpl) {
+		m.subject = subject
+	}
+}
+
+// ModelAssertionRepository sets the repository of the used client
+// assertions.
+func ModelAssertionRepository(assertion repository.Assertion) modelOption {
+	return func(m *modelImpl) {
+		m.assertion = assertion
+	}
+}
+
+// ModelRevocationRepository sets the repository of the revoked tokens.
+func ModelRevocationRepository(revocation repository.Revocation) modelOption {
+	return func(m *modelImpl) {
+		m.revocation = revocation
+	}
+}
+
+// ModelConsentRepository sets the repository of the consents that the users
+// granted to the clients.
+func ModelConsentRepository(consent repository.Consent) modelOption {
+	return func(m *modelImpl) {
+		m.consent = consent
+	}
+}
+
+// ModelTokenRepository sets the repository of the reference tokens.
+func ModelTokenRepository(token repository.Token) modelOption {
+	return func(m *modelImpl) {
+		m.token = token
+	}
+}
+
+// ModelBackchannelRepository sets the repository of the backchannel
+// authentication requests.
+func ModelBackchannelRepository(backchannel repository.Backchannel) modelOption {
+	return func(m *modelImpl) {
+		m.backchannel = backchannel
+	}
+}
+
+// ModelKeySet sets the keys that sign the access tokens.
+func ModelKeySet(keys *jwks.KeySet) modelOption {
+	return func(m *modelImpl) {
+		m.keys = keys
+	}
+}
+
+// ModelTokenExchangePolicies sets the policies of the clients that can use
+// the token exchange grant.
+func ModelTokenExchangePolicies(exchange *openid.TokenExchangePolicies) modelOption {
+	return func(m *modelImpl) {
+		m.exchange = exchange
+	}
+}
+
+// ModelTrustedIssuers sets the issuers whose assertions are accepted by the
+// JWT bearer grant.
+func ModelTrustedIssuers(issuers *openid.TrustedIssuers) modelOption {
+	return func(m *modelImpl) {
+		m.issuers = issuers
+	}
+}
+
+// ModelScopeRegistry sets the registry of the scopes that the clients can
+// request.
+func ModelScopeRegistry(scopes *openid.ScopeRegistry) modelOption {
+	return func(m *modelImpl) {
+		m.scopes = scopes
+	}
+}
+
+// ModelClaimsProviders sets the providers of the custom claims.
+func ModelClaimsProviders(claims *openid.ClaimsProviders) modelOption {
+	return func(m *modelImpl) {
+		m.claims = claims
+	}
+}
+
+// ModelResourceRegistry sets the registry of the protected APIs that access
+// tokens can be issued for.
+func ModelResourceRegistry(resources *openid.ResourceRegistry) modelOption {
+	return func(m *modelImpl) {
+		m.resources = resources
+	}
+}
+
+// ModelAuthorizationDetailTypes sets the registry of the authorization
+// details types that the clients can request.
+func ModelAuthorizationDetailTypes(details *openid.AuthorizationDetailTypes) modelOption {
+	return func(m *modelImpl) {
+		m.details = details
+	}
+}
+
+// ModelIssuer sets the issuer identifier of the provider.
+func ModelIssuer(issuer string) modelOption {
+	return func(m *modelImpl) {
+		m.issuer = issuer
+	}
+}
+
+// ModelTokenEndpoint sets the url of the token endpoint.
+func ModelTokenEndpoint(uri string) modelOption {
+	return func(m *modelImpl) {
+		m.tokenEndpoint = uri
+	}
+}
+
+// ModelClientCAs sets the certificate authorities of the client
+// certificates.
+func ModelClientCAs(pool *x509.CertPool) modelOption {
+	return func(m *modelImpl) {
+		m.clientCAs = pool
+	}
+}
+
+// ModelPairwiseSalt sets the salt for the pairwise subject identifiers.
+func ModelPairwiseSalt(salt []byte) modelOption {
+	return func(m *modelImpl) {
+		m.pairwiseSalt = salt
+	}
+}
+
+// ModelDecryptionKey sets the private key used to decrypt encrypted
+// id_token_hint.
+func ModelDecryptionKey(key interface{}) modelOption {
+	return func(m *modelImpl) {
+		m.decryptionKey = key
+	}
+}
+
 // SetCode allows the user to set the code repository.
 func (m *modelImpl) SetCode(code repository.Code) {
 	// Would this be better in production to ensure the fields are set once
@@ -74,26 +293,36 @@ func (m *modelImpl) SetUser(user repository.User) {
 	m.user = user
 }
 
+// SetSubject sets the pairwise subject repository.
+func (m *modelImpl) SetSubject(subject repository.Subject) {
+	m.subject = subject
+}
+
 // ValidateAuthnRequest validates the required fields for the authentication
 // request.
 func (m *modelImpl) ValidateAuthnRequest(req *openid.AuthenticationRequest) error {
 	if req == nil {
-		return errors.New("arguments cannot be nil")
+		return openid.ErrInvalidRequest
 	}
 	var (
 		clientID     = req.ClientID
 		redirectURI  = req.RedirectURI
 		prompt       = req.GetPrompt()
 		responseType = req.GetResponseType()
-		scope        = req.GetScope()
 
 		err = openid.ErrInvalidRequest
 	)
+	scope, unknown := m.scopes.Parse(req.Scope)
 	// Scope cannot be none, and it should have at least an openid scope.
-	if scope.Is(openid.ScopeNone) || !scope.Has(openid.ScopeOpenID) {
+	if scope.IsEmpty() || !scope.Has(openid.ScopeOpenID) {
 		return err.WithDescription("scope is required")
 	}
 
+	if len(unknown) > 0 {
+		msg := fmt.Sprintf("scope %s is not supported", unknown[0])
+		return openid.ErrInvalidScope.WithDescription(msg)
+	}
+
 	// ResponseType cannot be none, and should have "code" for
 	// authorization code flow.
 	if responseType.Is(openid.ResponseTypeNone) {
@@ -117,6 +346,32 @@ func (m *modelImpl) ValidateAuthnRequest(req *openid.AuthenticationRequest) erro
 		return err.WithDescription(msg)
 	}
 
+	if !openid.IsValidResponseMode(req.ResponseMode) {
+		msg := fmt.Sprintf("%s is not a valid response_mode", req.ResponseMode)
+		return err.WithDescription(msg)
+	}
+
+	if !openid.IsValidDisplay(req.Display) {
+		msg := fmt.Sprintf("%s is not a valid display", req.Display)
+		return err.WithDescription(msg)
+	}
+
+	if _, cerr := openid.ParseClaimsRequest(req.Claims); cerr != nil {
+		return err.WithDescription("claims is not a valid claims request")
+	}
+
+	if rerr := m.resources.Validate(req.Resource); rerr != nil {
+		return rerr
+	}
+
+	details, derr := openid.ParseAuthorizationDetails(req.AuthorizationDetails)
+	if derr != nil {
+		return openid.ErrInvalidAuthorizationDetails.WithDescription(derr.Error())
+	}
+	if derr := m.details.Validate(details); derr != nil {
+		return derr
+	}
+
 	// If prompt is "none", it cannot have other values.
 	if prompt.Has(openid.PromptNone) && prompt.Has(openid.PromptLogin|openid.PromptConsent|openid.PromptSelectAccount) {
 		return err.WithDescription("prompt none may not contain other values")
@@ -129,7 +384,7 @@ func (m *modelImpl) ValidateAuthnRequest(req *openid.AuthenticationRequest) erro
 func (m *modelImpl) ValidateAuthnUser(ctx context.Context, req *openid.AuthenticationRequest) error {
 	userID, ok := openid.GetUserIDContextKey(ctx)
 	if !ok {
-		return errors.New("user_id missing")
+		return openid.ErrLoginRequired
 	}
 	user, err := m.user.Get(userID)
 	if err != nil {
@@ -139,39 +394,330 @@ func (m *modelImpl) ValidateAuthnUser(ctx context.Context, req *openid.Authentic
 	elapsed := time.Unix(user.Profile.UpdatedAt, 0)
 	if time.Since(elapsed) > time.Duration(req.MaxAge)*time.Second {
 		// TODO: Must re-authenticate.
-		return errors.New("re-authentication required")
+		return openid.ErrLoginRequired.WithDescription("re-authentication required")
 	}
-	// if req.LoginHint == user.Email.Email {
-	// user.Email.Email
-	// }
-	return nil
+	return validateLoginHint(user, req.LoginHint)
+}
+
+// ValidateLoginHint checks if the login_hint refers to the user in the
+// current session. If it names a different user, login_required is returned so
+// that the user can switch account.
+func (m *modelImpl) ValidateLoginHint(ctx context.Context, req *openid.AuthenticationRequest) error {
+	if req.LoginHint == "" {
+		return nil
+	}
+	userID, ok := openid.GetUserIDContextKey(ctx)
+	if !ok {
+		return openid.ErrLoginRequired
+	}
+	user, err := m.user.Get(userID)
+	if err != nil {
+		return err
+	}
+	return validateLoginHint(user, req.LoginHint)
 }
 
 // ValidateAuthnClient validates the provided client request with the client
 // data in the storage.
 func (m *modelImpl) ValidateAuthnClient(req *openid.AuthenticationRequest) error {
-	var (
-		clientID    = req.ClientID
-		redirectURI = req.RedirectURI
-	)
-	client, err := m.client.Get(clientID)
+	if err := m.ValidateRedirectURI(req); err != nil {
+		return err
+	}
+	scope, _ := m.scopes.Parse(req.Scope)
+	if denied := scope.Difference(m.scopes.Allowed(req.ClientID, scope)); !denied.IsEmpty() {
+		msg := fmt.Sprintf("scope %s is not allowed for the client", denied)
+		return openid.ErrInvalidScope.WithDescription(msg)
+	}
+	return nil
+}
+
+// ValidateRedirectURI validates that the client exists and registered the
+// redirect uri. Until it succeeds, errors cannot be sent to the redirect uri.
+func (m *modelImpl) ValidateRedirectURI(req *openid.AuthenticationRequest) error {
+	if req.ClientID == "" {
+		return openid.ErrInvalidRequest.WithDescription("client_id is required")
+	}
+	client, err := m.client.Get(req.ClientID)
+	if err != nil {
+		return openid.ErrInvalidRequest.WithDescription("client_id is invalid")
+	}
+	if req.RedirectURI == "" {
+		return openid.ErrInvalidRequest.WithDescription("redirect_uri is required")
+	}
+	if !client.GetRedirectURIs().Contains(req.RedirectURI) {
+		return openid.ErrInvalidRequest.WithDescription("redirect_uri is not registered for the client")
+	}
+	return nil
+}
+
+// ValidateConsent returns the scopes and claims of the request that the user
+// has not granted to the client yet. Nil is returned if the request is
+// covered by a previous consent, unless the prompt requires consent again.
+func (m *modelImpl) ValidateConsent(ctx context.Context, req *openid.AuthenticationRequest) (*openid.ConsentPrompt, error) {
+	userID, ok := openid.GetUserIDContextKey(ctx)
+	if !ok {
+		return nil, openid.ErrLoginRequired
+	}
+	client, err := m.client.Get(req.ClientID)
+	if err != nil {
+		return nil, openid.ErrInvalidRequest.WithDescription("client_id is invalid")
+	}
+	claims, err := openid.ParseClaimsRequest(req.Claims)
+	if err != nil {
+		return nil, openid.ErrInvalidRequest.WithDescription("claims is not a valid claims request")
+	}
+	// The authorization details describe a single transaction, such as a
+	// payment, so they are never covered by a previous consent.
+	details, err := openid.ParseAuthorizationDetails(req.AuthorizationDetails)
+	if err != nil {
+		return nil, openid.ErrInvalidAuthorizationDetails.WithDescription(err.Error())
+	}
+	consent, err := m.consent.Get(userID, req.ClientID)
+	if err != nil {
+		consent = openid.NewConsent(userID, req.ClientID)
+	}
+
+	scopes := strings.Fields(req.Scope)
+	missingScopes, missingClaims := consent.Missing(scopes, claims.Names())
+	prompt := req.GetPrompt()
+	if len(missingScopes) == 0 && len(missingClaims) == 0 && len(details) == 0 && !prompt.Has(openid.PromptConsent) {
+		return nil, nil
+	}
+	if prompt.Has(openid.PromptNone) {
+		return nil, openid.ErrConsentRequired
+	}
+	// When consent is required again, every scope is asked for.
+	if prompt.Has(openid.PromptConsent) {
+		missingScopes, missingClaims = scopes, claims.Names()
+	}
+	consentText := make(map[string]string)
+	for _, s := range scopes {
+		if def, ok := m.scopes.Lookup(s); ok && def.ConsentText != "" {
+			consentText[s] = def.ConsentText
+		}
+	}
+	detailText := make(map[string]string)
+	for _, d := range details {
+		if t, ok := m.details.Lookup(d.Type()); ok && t.Description != "" {
+			detailText[t.Type] = t.Description
+		}
+	}
+	return &openid.ConsentPrompt{
+		ClientName:           client.ClientName,
+		Scopes:               missingScopes,
+		Claims:               missingClaims,
+		GrantedScopes:        difference(scopes, missingScopes),
+		ConsentText:          consentText,
+		AuthorizationDetails: details,
+
+		AuthorizationDetailText: detailText,
+	}, nil
+}
+
+// GrantConsent records that the user granted the scopes and claims of the
+// request to the client.
+func (m *modelImpl) GrantConsent(ctx context.Context, req *openid.AuthenticationRequest) error {
+	userID, ok := openid.GetUserIDContextKey(ctx)
+	if !ok {
+		return openid.ErrLoginRequired
+	}
+	claims, err := openid.ParseClaimsRequest(req.Claims)
 	if err != nil {
+		return openid.ErrInvalidRequest.WithDescription("claims is not a valid claims request")
+	}
+	consent, err := m.consent.Get(userID, req.ClientID)
+	if err != nil {
+		consent = openid.NewConsent(userID, req.ClientID)
+	}
+	consent.Grant(strings.Fields(req.Scope), claims.Names())
+	return m.consent.Put(consent)
+}
+
+// RevokeClient removes the consents, the pending backchannel authentication
+// requests and the reference tokens of the deleted client. The refresh tokens
+// and the signed access tokens are not stored, and are rejected once the
+// client no longer exists.
+func (m *modelImpl) RevokeClient(ctx context.Context, clientID string) error {
+	if err := m.consent.DeleteByClient(clientID); err != nil {
+		return err
+	}
+	if err := m.backchannel.DeleteByClient(clientID); err != nil {
 		return err
 	}
-	if !client.GetRedirectURIs().Contains(redirectURI) {
-		return errors.New("redirect_uri incorrect")
+	return m.token.DeleteByClient(clientID)
+}
+
+// ValidateIDTokenHint checks the id_token_hint against the user in the
+// current session. The hint may be expired, but must be signed by us and
+// issued to the requesting client. If the hint does not belong to the current
+// user, login_required is returned and the user has to login again.
+func (m *modelImpl) ValidateIDTokenHint(ctx context.Context, req *openid.AuthenticationRequest) error {
+	if req.IDTokenHint == "" {
+		return nil
+	}
+	client, err := m.client.Get(req.ClientID)
+	if err != nil {
+		return openid.ErrInvalidRequest.WithDescription("client_id is invalid")
+	}
+	idToken, err := m.parseIDTokenHint(client, req.IDTokenHint)
+	if err != nil {
+		return openid.ErrInvalidRequest.WithDescription(err.Error())
+	}
+	if !idToken.StandardClaims.VerifyAudience(req.ClientID, true) {
+		return openid.ErrInvalidRequest.WithDescription("id_token_hint was not issued to the client")
+	}
+	userID, ok := openid.GetUserIDContextKey(ctx)
+	if !ok {
+		return openid.ErrLoginRequired
+	}
+	hintUserID, err := m.ResolveSubject(client, idToken.StandardClaims.Subject)
+	if err != nil || hintUserID != userID {
+		return openid.ErrLoginRequired.WithDescription("id_token_hint does not match the current user")
 	}
 	return nil
 }
 
-// NewCode returns a new code.
-func (m *modelImpl) NewCode() string {
+// parseIDTokenHint parses the id_token_hint, which may be encrypted for the
+// given client. Expired hints are accepted.
+func (m *modelImpl) parseIDTokenHint(client *openid.Client, hint string) (*openid.IDToken, error) {
+	if isEncrypted(hint) {
+		if client == nil {
+			return nil, errors.New("client_id is required for encrypted id_token_hint")
+		}
+		var err error
+		hint, err = m.decryptIDTokenHint(client, hint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	idToken := openid.NewIDToken()
+	if err := idToken.ParseHintHS256(hint, idTokenKey); err != nil {
+		return nil, err
+	}
+	return idToken, nil
+}
+
+// ValidateEndSession validates the logout request, and returns the client
+// that requested it, if it can be identified. The returned bool is true if
+// the hints identify the user of the current session, in which case the user
+// can be logged out without confirmation.
+func (m *modelImpl) ValidateEndSession(ctx context.Context, req *openid.EndSessionRequest) (*openid.Client, bool, error) {
+	var client *openid.Client
+	if req.ClientID != "" {
+		c, err := m.client.Get(req.ClientID)
+		if err != nil {
+			return nil, false, openid.ErrInvalidRequest.WithDescription("client_id is invalid")
+		}
+		client = c
+	}
+
+	var idToken *openid.IDToken
+	if req.IDTokenHint != "" {
+		var err error
+		idToken, err = m.parseIDTokenHint(client, req.IDTokenHint)
+		if err != nil {
+			return nil, false, openid.ErrInvalidRequest.WithDescription(err.Error())
+		}
+		if client == nil {
+			client, err = m.client.Get(idToken.StandardClaims.Audience)
+			if err != nil {
+				return nil, false, openid.ErrInvalidRequest.WithDescription("id_token_hint was not issued to a known client")
+			}
+		}
+		if !idToken.StandardClaims.VerifyAudience(client.ClientID, true) {
+			return nil, false, openid.ErrInvalidRequest.WithDescription("id_token_hint was not issued to the client")
+		}
+	}
+
+	if uri := req.PostLogoutRedirectURI; uri != "" {
+		if client == nil {
+			return nil, false, openid.ErrInvalidRequest.WithDescription("client_id or id_token_hint is required with post_logout_redirect_uri")
+		}
+		if !client.IsPostLogoutRedirectURI(uri) {
+			return nil, false, openid.ErrInvalidRequest.WithDescription("post_logout_redirect_uri is not registered")
+		}
+	}
+
+	userID, ok := openid.GetUserIDContextKey(ctx)
+	if !ok || idToken == nil {
+		return client, false, nil
+	}
+	hintUserID, err := m.ResolveSubject(client, idToken.StandardClaims.Subject)
+	if err != nil || hintUserID != userID {
+		return client, false, nil
+	}
+	if req.LogoutHint != "" {
+		user, err := m.user.Get(userID)
+		if err != nil || validateLoginHint(user, req.LogoutHint) != nil {
+			return client, false, nil
+		}
+	}
+	return client, true, nil
+}
+
+// decryptIDTokenHint decrypts an id_token_hint that the client has encrypted
+// with the algorithms it registered for id token encryption.
+func (m *modelImpl) decryptIDTokenHint(client *openid.Client, hint string) (string, error) {
+	alg := client.IDTokenEncryptedResponseAlg
+	if alg == "" {
+		return "", errors.New("client is not registered for id_token encryption")
+	}
+	obj, err := jose.ParseEncrypted(hint)
+	if err != nil {
+		return "", err
+	}
+	if obj.Header.Algorithm != alg {
+		return "", fmt.Errorf("id_token_hint must be encrypted with %s", alg)
+	}
+	key := m.decryptionKey
+	if size := symmetricKeySize(alg, client.IDTokenEncryptedResponseEnc); size > 0 {
+		key = clientSecretKey(client.ClientSecret, size)
+	}
+	if key == nil {
+		return "", errors.New("decryption key is not configured")
+	}
+	b, err := obj.Decrypt(key)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// NewCode returns a new code for the given authentication request, issued
+// in the end-user's current session.
+func (m *modelImpl) NewCode(ctx context.Context, req *openid.AuthenticationRequest) string {
 	c := crypto.NewXID()
 	code := openid.NewCode(c)
+	code.ClientID = req.ClientID
+	code.ClaimsLocales = req.ClaimsLocales
+	code.SessionID, _ = openid.GetSessionContextKey(ctx)
+	scope, _ := m.scopes.Parse(req.Scope)
+	code.Scope = scope.String()
+	code.Claims = req.Claims
+	code.Resource = req.Resource
+	// The authorization details were validated in the authentication
+	// request.
+	code.AuthorizationDetails, _ = openid.ParseAuthorizationDetails(req.AuthorizationDetails)
+	code.AuthTime, _ = openid.GetAuthTimeContextKey(ctx)
+	code.ACR = defaultACR
 	m.code.Put(c, code)
 	return c
 }
 
+// ValidateCode returns the authorization code if it exists and has not
+// expired. The code can only be used once.
+func (m *modelImpl) ValidateCode(c string) (*openid.Code, error) {
+	code, ok := m.code.Get(c)
+	if !ok {
+		return nil, openid.ErrInvalidGrant.WithDescription("code does not exist")
+	}
+	m.code.Delete(c)
+	if code.Expired() {
+		return nil, openid.ErrInvalidGrant.WithDescription("code expired")
+	}
+	return code, nil
+}
+
 func (m *modelImpl) ValidateClientAuthHeader(authorization string) (*openid.Client, error) {
 	token, err := authheader.Basic(authorization)
 	if err != nil {
@@ -184,44 +730,1479 @@ func (m *modelImpl) ValidateClientAuthHeader(authorization string) (*openid.Clie
 	return m.client.GetByCredentials(clientID, clientSecret)
 }
 
-func (m *modelImpl) ProvideToken(userID string, duration time.Duration) (string, error) {
-	var (
-		aud = "https://server.example.com/token"
-		sub = userID
-		iss = userID
-		iat = time.Now().UTC()
-		exp = iat.Add(duration)
+// AuthenticateClient authenticates the client at the token endpoint. The
+// client must use the token_endpoint_auth_method it registered, and only one
+// method may be used in a request. The authorization header and the client
+// certificate are read from the context.
+func (m *modelImpl) AuthenticateClient(ctx context.Context, creds openid.ClientCredentials) (*openid.Client, error) {
+	authorization, _ := openid.GetAuthContextKey(ctx)
+	method, err := clientAuthMethod(authorization, creds)
+	if err != nil {
+		return nil, err
+	}
+	var client *openid.Client
+	switch method {
+	case openid.ClientSecretBasic:
+		client, err = m.ValidateClientAuthHeader(authorization)
+	case openid.ClientSecretPost:
+		client, err = m.client.GetByCredentials(creds.ClientID, creds.ClientSecret)
+	case openid.None:
+		// Clients using mutual-TLS only send the client_id in the
+		// request, and authenticate with the certificate instead.
+		client, err = m.client.Get(creds.ClientID)
+		if err == nil {
+			method, err = m.validateClientCertificate(ctx, client)
+		}
+	default:
+		client, method, err = m.validateClientAssertion(creds)
+	}
+	if err != nil {
+		// The failures of the key lookup and the storage are not
+		// revealed to the client.
+		if verr, ok := err.(*openid.ErrorJSON); ok {
+			return nil, verr
+		}
+		return nil, openid.ErrInvalidClient
+	}
+	registered := client.TokenEndpointAuthMethod
+	if registered == "" {
+		registered = openid.ClientSecretBasic
+	}
+	if method != registered {
+		return nil, openid.ErrInvalidClient.WithDescription(fmt.Sprintf("client must authenticate with %s", registered))
+	}
+	return client, nil
+}
 
-		key = []byte("access_token_secret")
+// validateClientAssertion validates the client_secret_jwt or private_key_jwt
+// assertion, and returns the client with the method that was used.
+func (m *modelImpl) validateClientAssertion(creds openid.ClientCredentials) (*openid.Client, string, error) {
+	var (
+		claims jwt.StandardClaims
+		client *openid.Client
+		method string
 	)
-	claims := crypto.NewStandardClaims(aud, sub, iss, iat.Unix(), exp.Unix())
-	return crypto.NewJWT(key, claims)
+	_, err := jwt.ParseWithClaims(creds.ClientAssertion, &claims, func(token *jwt.Token) (interface{}, error) {
+		var err error
+		client, err = m.client.Get(claims.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		if alg := client.TokenEndpointAuthSigningAlg; alg != "" && alg != token.Method.Alg() {
+			return nil, fmt.Errorf("client_assertion must be signed with %s", alg)
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			method = openid.ClientSecretJWT
+			return []byte(client.ClientSecret), nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+			method = openid.PrivateKeyJWT
+			kid, _ := token.Header["kid"].(string)
+			return clientPublicKey(client, kid)
+		default:
+			return nil, errors.New("client_assertion signing method is not supported")
+		}
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if creds.ClientID != "" && creds.ClientID != client.ClientID {
+		return nil, "", openid.ErrInvalidClient.WithDescription("client_id does not match the client_assertion")
+	}
+	if claims.Subject != client.ClientID {
+		return nil, "", openid.ErrInvalidClient.WithDescription("client_assertion sub must be the client_id")
+	}
+	if !claims.VerifyAudience(m.tokenEndpoint, true) {
+		return nil, "", openid.ErrInvalidClient.WithDescription("client_assertion aud must be the token endpoint")
+	}
+	if claims.ExpiresAt == 0 || claims.Id == "" {
+		return nil, "", openid.ErrInvalidClient.WithDescription("client_assertion exp and jti are required")
+	}
+	if err := m.assertion.Put(client.ClientID, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		if err == database.ErrAssertionReplayed {
+			return nil, "", openid.ErrInvalidClient.WithDescription("client_assertion has already been used")
+		}
+		return nil, "", err
+	}
+	return client, method, nil
 }
 
-func (m *modelImpl) ProvideIDToken(userID string) (string, error) {
-	user, err := m.user.Get(userID)
+// validateClientCertificate validates the client certificate for the
+// mutual-TLS authentication methods, and returns the method that was used.
+func (m *modelImpl) validateClientCertificate(ctx context.Context, client *openid.Client) (string, error) {
+	method := client.TokenEndpointAuthMethod
+	if method != openid.TLSClientAuth && method != openid.SelfSignedTLSClientAuth {
+		return openid.None, nil
+	}
+	cert, ok := openid.GetCertContextKey(ctx)
+	if !ok {
+		return "", mtls.ErrMissingCertificate
+	}
+	if method == openid.TLSClientAuth {
+		if err := mtls.VerifyChain(cert, openid.GetCertChainContextKey(ctx), m.clientCAs); err != nil {
+			return "", err
+		}
+		return method, mtls.MatchSubject(cert, mtls.Subject{
+			DN:       client.TLSClientAuthSubjectDN,
+			SANDNS:   client.TLSClientAuthSANDNS,
+			SANURI:   client.TLSClientAuthSANURI,
+			SANIP:    client.TLSClientAuthSANIP,
+			SANEmail: client.TLSClientAuthSANEmail,
+		})
+	}
+	jwks, err := clientKeySet(client)
 	if err != nil {
 		return "", err
 	}
-	idToken := user.ToIDToken()
-	var (
-		now = time.Now().UTC()
-		aud = "https://server.example.com/token"
-		sub = userID
-		iss = userID // Should be client id?
-		iat = now
-		id  = crypto.NewXID()
-		nbf = now
-		exp = now.Add(2 * time.Hour)
-	)
-	idToken.StandardClaims = jwt.StandardClaims{
-		Audience:  aud,
-		ExpiresAt: exp.Unix(),
-		Id:        id,
-		IssuedAt:  iat.Unix(),
-		Issuer:    iss,
-		NotBefore: nbf.Unix(),
-		Subject:   sub,
+	var certs []*x509.Certificate
+	for _, key := range jwks.Keys {
+		certs = append(certs, key.Certificates...)
+	}
+	return method, mtls.MatchCertificates(cert, certs)
+}
+
+// ProvideSubject returns the subject identifier of the user for the client.
+// Pairwise clients receive a subject derived from their sector identifier,
+// which is stored so that it can be mapped back to the user. Without a salt
+// the subject could be computed by anyone that knows the user id, so none is
+// issued.
+func (m *modelImpl) ProvideSubject(client *openid.Client, userID string) (string, error) {
+	if !client.IsPairwise() {
+		return userID, nil
+	}
+	if len(m.pairwiseSalt) == 0 {
+		return "", errMissingPairwiseSalt
+	}
+	sector, err := client.SectorIdentifier()
+	if err != nil {
+		return "", err
+	}
+	sub := openid.PairwiseSubject(sector, userID, m.pairwiseSalt)
+	if err := m.subject.Put(sector, sub, userID); err != nil {
+		return "", err
+	}
+	return sub, nil
+}
+
+// errMissingPairwiseSalt is returned when a pairwise subject is requested
+// without a configured salt.
+var errMissingPairwiseSalt = errors.New("pairwise salt is not configured")
+
+// ResolveSubject returns the local user id of the subject identifier that was
+// issued to the client.
+func (m *modelImpl) ResolveSubject(client *openid.Client, sub string) (string, error) {
+	if !client.IsPairwise() {
+		return sub, nil
+	}
+	sector, err := client.SectorIdentifier()
+	if err != nil {
+		return "", err
+	}
+	return m.subject.Get(sector, sub)
+}
+
+// accessTokenClaims represents the claims of the access token.
+type accessTokenClaims struct {
+	jwt.StandardClaims
+	ClientID     string               `json:"client_id,omitempty"`
+	Scope        string               `json:"scope,omitempty"`
+	Confirmation *openid.Confirmation `json:"cnf,omitempty"`
+	AuthTime     int64                `json:"auth_time,omitempty"`
+	ACR          string               `json:"acr,omitempty"`
+	Actor        *openid.Actor        `json:"act,omitempty"`
+
+	AuthorizationDetails []openid.AuthorizationDetail `json:"authorization_details,omitempty"`
+
+	// ClaimsLocales are the claims_locales of the authentication request,
+	// which the userinfo endpoint returns the localized claims for.
+	ClaimsLocales string `json:"claims_locales,omitempty"`
+
+	// Resource are the resources that the refresh token can obtain access
+	// tokens for.
+	Resource []string `json:"resource,omitempty"`
+	Refresh  bool     `json:"refresh,omitempty"`
+
+	// reference issues the access token as an opaque reference token.
+	reference bool
+}
+
+// accessTokenType is the typ header of the access tokens in the JWT profile
+// of RFC 9068.
+const accessTokenType = "at+jwt"
+
+// defaultACR is the acr of the password login, which does not meet any of
+// the assurance levels of ISO/IEC 29115.
+const defaultACR = "0"
+
+type accessTokenOption func(claims *accessTokenClaims)
+
+// withAudience restricts the access token to the resource.
+func withAudience(aud string) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.Audience = aud
+	}
+}
+
+// withRefresh marks the token as a refresh token for the granted resources.
+func withRefresh(resources []string) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.Refresh = true
+		claims.Resource = resources
+	}
+}
+
+// withAuthentication sets the time and the context class of the end-user's
+// authentication.
+func withAuthentication(authTime int64, acr string) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.AuthTime = authTime
+		claims.ACR = acr
+	}
+}
+
+// withAuthorizationDetails sets the fine-grained permissions that the
+// end-user granted.
+func withAuthorizationDetails(details []openid.AuthorizationDetail) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.AuthorizationDetails = details
+	}
+}
+
+// withActor sets the party that acts on behalf of the subject.
+func withActor(act *openid.Actor) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.Actor = act
+	}
+}
+
+// withReference issues the access token as an opaque reference token.
+func withReference() accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.reference = true
+	}
+}
+
+// withScope sets the scope that was granted to the client.
+func withScope(scope string) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.Scope = scope
+	}
+}
+
+// withLocales sets the claims_locales of the authentication request.
+func withLocales(claimsLocales string) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.ClaimsLocales = claimsLocales
+	}
+}
+
+// withCertificate binds the access token to the client certificate.
+func withCertificate(cert *x509.Certificate) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		if claims.Confirmation == nil {
+			claims.Confirmation = new(openid.Confirmation)
+		}
+		claims.Confirmation.X5tS256 = mtls.Thumbprint(cert)
+	}
+}
+
+// withConfirmation binds the access token to the certificate and the DPoP key
+// of the confirmation.
+func withConfirmation(cnf *openid.Confirmation) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		claims.Confirmation = cnf
+	}
+}
+
+// withJWKThumbprint binds the token to the public key of the DPoP proof.
+func withJWKThumbprint(jkt string) accessTokenOption {
+	return func(claims *accessTokenClaims) {
+		if claims.Confirmation == nil {
+			claims.Confirmation = new(openid.Confirmation)
+		}
+		claims.Confirmation.JKT = jkt
+	}
+}
+
+// ProvideToken returns a signed token for the user, with the subject
+// identifier of the client. Access tokens are signed with the provider's
+// signing key in the JWT profile of RFC 9068, so that the resources can
+// validate them locally. Refresh tokens and the claims of reference tokens are
+// only read by the provider, and are signed with its secret.
+func (m *modelImpl) ProvideToken(client *openid.Client, userID string, duration time.Duration, opts ...accessTokenOption) (string, error) {
+	sub, err := m.ProvideSubject(client, userID)
+	if err != nil {
+		return "", err
+	}
+	var (
+		aud = m.issuer
+		iss = m.issuer
+		iat = time.Now().UTC()
+		exp = iat.Add(duration)
+	)
+	claims := accessTokenClaims{
+		StandardClaims: *crypto.NewStandardClaims(aud, sub, iss, iat.Unix(), exp.Unix()),
+		ClientID:       client.ClientID,
+	}
+	claims.Id = crypto.NewXID()
+	for _, o := range opts {
+		o(&claims)
+	}
+	if claims.Refresh || claims.reference {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(accessTokenKey)
+		if err != nil || !claims.reference {
+			return token, err
+		}
+		ref := openid.ReferenceToken{
+			ID:        crypto.NewXID(),
+			ClientID:  client.ClientID,
+			Token:     token,
+			ExpiresAt: exp,
+		}
+		if err := m.token.Put(&ref); err != nil {
+			return "", err
+		}
+		return ref.ID, nil
+	}
+
+	key, err := m.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["typ"] = accessTokenType
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// parseClaims verifies the access token, and returns its claims. Only
+// reference tokens are resolved to claims signed with the provider's secret,
+// and other access tokens must be signed with its published key.
+func (m *modelImpl) parseClaims(token string) (*accessTokenClaims, error) {
+	var (
+		claims *accessTokenClaims
+		err    error
+	)
+	if strings.Count(token, ".") != 2 {
+		ref, rerr := m.token.Get(token)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if ref.Expired() {
+			return nil, errors.New("token expired")
+		}
+		claims, err = parseSecretClaims(ref.Token)
+	} else {
+		claims, err = m.parseSignedClaims(token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if claims.Refresh {
+		return nil, errors.New("refresh token cannot be used as an access token")
+	}
+	if m.revocation.Has(claims.Id) {
+		return nil, errors.New("token was revoked")
+	}
+	if claims.Issuer != m.issuer {
+		return nil, errors.New("token was not issued by the provider")
+	}
+	return claims, nil
+}
+
+// parseSignedClaims verifies the access token in the JWT profile with the
+// provider's published keys.
+func (m *modelImpl) parseSignedClaims(token string) (*accessTokenClaims, error) {
+	var claims accessTokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, errors.New("invalid token signing method")
+		}
+		if typ, _ := token.Header["typ"].(string); typ != accessTokenType {
+			return nil, errors.New("invalid token type")
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.keys.PublicKey(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// parseSecretClaims verifies the claims that are signed with the provider's
+// secret, which are the refresh tokens and the claims of reference tokens.
+func parseSecretClaims(token string) (*accessTokenClaims, error) {
+	var claims accessTokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid token signing method")
+		}
+		return accessTokenKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// ParseToken parses the access token issued by ProvideToken, and returns the
+// client and the user the token was issued for.
+func (m *modelImpl) ParseToken(token string) (*accessTokenClaims, *openid.Client, *openid.User, error) {
+	claims, err := m.parseClaims(token)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	client, user, err := m.tokenOwner(claims)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return claims, client, user, nil
+}
+
+// tokenOwner returns the client and the user that the token was issued for.
+func (m *modelImpl) tokenOwner(claims *accessTokenClaims) (*openid.Client, *openid.User, error) {
+	client, err := m.client.Get(claims.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := m.ResolveSubject(client, claims.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := m.user.Get(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, user, nil
+}
+
+// ParseRefreshToken parses the refresh token issued by ProvideToken, and
+// returns the id of the user the token was issued for.
+func (m *modelImpl) ParseRefreshToken(token string) (*accessTokenClaims, string, error) {
+	claims, err := parseSecretClaims(token)
+	if err != nil {
+		return nil, "", openid.ErrInvalidGrant.WithDescription(err.Error())
+	}
+	if !claims.Refresh {
+		return nil, "", openid.ErrInvalidGrant.WithDescription("token is not a refresh token")
+	}
+	if m.revocation.Has(claims.Id) {
+		return nil, "", openid.ErrInvalidGrant.WithDescription("token was revoked")
+	}
+	_, user, err := m.tokenOwner(claims)
+	if err != nil {
+		return nil, "", openid.ErrInvalidGrant.WithDescription(err.Error())
+	}
+	return claims, user.ID, nil
+}
+
+// RevokeToken revokes the access or refresh token issued to the client.
+// Reference tokens are deleted, and the ids of the other tokens are remembered
+// until they expire. Invalid tokens and tokens issued to other clients are
+// ignored, as they cannot be used by the client anyway.
+func (m *modelImpl) RevokeToken(client *openid.Client, token string) error {
+	if strings.Count(token, ".") != 2 {
+		ref, err := m.token.Get(token)
+		if err != nil || ref.ClientID != client.ClientID {
+			return nil
+		}
+		return m.token.Delete(ref.ID)
+	}
+	claims, err := parseSecretClaims(token)
+	if err != nil {
+		claims, err = m.parseSignedClaims(token)
+	}
+	if err != nil || claims.ClientID != client.ClientID {
+		return nil
+	}
+	return m.revocation.Put(claims.Id, time.Unix(claims.ExpiresAt, 0))
+}
+
+// ResolveResource returns the resource that the access token is issued for,
+// out of the requested resource and the resources granted to the client. A
+// nil resource means the access token is issued for the provider itself.
+func (m *modelImpl) ResolveResource(granted, requested []string) (*openid.Resource, error) {
+	var target string
+	switch {
+	case len(requested) > 1:
+		return nil, openid.ErrInvalidTarget.WithDescription("access token can only be issued for one resource")
+	case len(requested) == 1:
+		target = requested[0]
+	case len(granted) == 1:
+		target = granted[0]
+	default:
+		return nil, nil
+	}
+	if len(granted) > 0 && len(difference([]string{target}, granted)) > 0 {
+		return nil, openid.ErrInvalidTarget.WithDescription(fmt.Sprintf("resource %q was not granted", target))
+	}
+	if err := m.resources.Validate([]string{target}); err != nil {
+		return nil, err
+	}
+	res, _ := m.resources.Lookup(target)
+	return &res, nil
+}
+
+// tokenExchange is the validated request of the token exchange grant.
+type tokenExchange struct {
+	UserID    string
+	Resource  *openid.Resource
+	Scope     string
+	Actor     *openid.Actor
+	ExpiresAt time.Time
+}
+
+// exchangeToken is the party identified by the subject or actor token of the
+// token exchange grant.
+type exchangeToken struct {
+	userID    string
+	client    *openid.Client
+	subject   string
+	actor     *openid.Actor
+	expiresAt int64
+
+	// scopes are the scopes of access tokens. Id tokens do not carry a
+	// scope, and are not scoped.
+	scopes []string
+	scoped bool
+}
+
+// parseExchangeToken parses the subject or actor token, which must be an
+// access token or an id token issued by the provider.
+func (m *modelImpl) parseExchangeToken(token, tokenType string) (*exchangeToken, error) {
+	switch tokenType {
+	case openid.TokenTypeAccessToken:
+		claims, client, user, err := m.ParseToken(token)
+		if err != nil {
+			return nil, err
+		}
+		if claims.Refresh {
+			return nil, errors.New("refresh token is not an access token")
+		}
+		return &exchangeToken{
+			userID:    user.ID,
+			client:    client,
+			subject:   claims.Subject,
+			actor:     claims.Actor,
+			expiresAt: claims.ExpiresAt,
+			scopes:    strings.Fields(claims.Scope),
+			scoped:    true,
+		}, nil
+	case openid.TokenTypeIDToken:
+		idToken := openid.NewIDToken()
+		if err := idToken.ParseHS256(token, idTokenKey); err != nil {
+			return nil, err
+		}
+		if idToken.Issuer != m.issuer {
+			return nil, errors.New("id token was not issued by the provider")
+		}
+		client, err := m.client.Get(idToken.Audience)
+		if err != nil {
+			return nil, err
+		}
+		userID, err := m.ResolveSubject(client, idToken.Subject)
+		if err != nil {
+			return nil, err
+		}
+		return &exchangeToken{
+			userID:    userID,
+			client:    client,
+			subject:   idToken.Subject,
+			expiresAt: idToken.ExpiresAt,
+		}, nil
+	default:
+		return nil, fmt.Errorf("token type %q is not supported", tokenType)
+	}
+}
+
+// ValidateTokenExchange validates the token exchange request of the client
+// against its policy. The exchanged token is issued for a single resource,
+// with at most the scope of the subject token. With an actor token, the
+// actor is recorded in the act claim, and the actors of the subject token
+// are nested within it. Without an actor token, the client impersonates the
+// subject, which the policy has to allow.
+func (m *modelImpl) ValidateTokenExchange(client *openid.Client, req *openid.AccessTokenRequest) (*tokenExchange, error) {
+	if !allowsGrant(client, openid.TokenExchange) {
+		return nil, openid.ErrUnauthorizedClient.WithDescription("client is not registered for the token exchange grant")
+	}
+	policy, ok := m.exchange.Lookup(client.ClientID)
+	if !ok {
+		return nil, openid.ErrUnauthorizedClient.WithDescription("client is not allowed to exchange tokens")
+	}
+	if req.SubjectToken == "" || req.SubjectTokenType == "" {
+		return nil, openid.ErrInvalidRequest.WithDescription("subject_token and subject_token_type are required")
+	}
+	if (req.ActorToken == "") != (req.ActorTokenType == "") {
+		return nil, openid.ErrInvalidRequest.WithDescription("actor_token and actor_token_type must be present together")
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != openid.TokenTypeAccessToken {
+		return nil, openid.ErrInvalidRequest.WithDescription("only access tokens can be requested")
+	}
+
+	subject, err := m.parseExchangeToken(req.SubjectToken, req.SubjectTokenType)
+	if err != nil {
+		return nil, openid.ErrInvalidRequest.WithDescription(fmt.Sprintf("subject_token is invalid: %v", err))
+	}
+
+	targets := append(append([]string(nil), req.Resource...), req.Audience...)
+	if len(targets) != 1 {
+		return nil, openid.ErrInvalidTarget.WithDescription("exactly one resource or audience is required")
+	}
+	if !policy.AllowsAudience(targets[0]) {
+		return nil, openid.ErrInvalidTarget.WithDescription(fmt.Sprintf("client is not allowed to exchange tokens for %q", targets[0]))
+	}
+	resource, err := m.ResolveResource(nil, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	// The scope is limited by both the subject token and the policy.
+	available := policy.Scopes
+	if subject.scoped {
+		available = subject.scopes
+		if len(policy.Scopes) > 0 {
+			available = intersect(available, policy.Scopes)
+		}
+	}
+	scope := strings.Join(available, " ")
+	if req.Scope != "" {
+		if exceeded := difference(strings.Fields(req.Scope), available); len(exceeded) > 0 {
+			return nil, openid.ErrInvalidScope.WithDescription(fmt.Sprintf("scope %q cannot be exchanged", strings.Join(exceeded, " ")))
+		}
+		scope = req.Scope
+	}
+
+	act := subject.actor
+	if req.ActorToken != "" {
+		actor, err := m.parseExchangeToken(req.ActorToken, req.ActorTokenType)
+		if err != nil {
+			return nil, openid.ErrInvalidRequest.WithDescription(fmt.Sprintf("actor_token is invalid: %v", err))
+		}
+		act = &openid.Actor{
+			Subject:  actor.subject,
+			ClientID: actor.client.ClientID,
+			Actor:    subject.actor,
+		}
+	} else if !policy.Impersonation {
+		return nil, openid.ErrInvalidRequest.WithDescription("actor_token is required, since the client cannot impersonate the subject")
+	}
+
+	return &tokenExchange{
+		UserID:    subject.userID,
+		Resource:  resource,
+		Scope:     scope,
+		Actor:     act,
+		ExpiresAt: time.Unix(subject.expiresAt, 0),
+	}, nil
+}
+
+// jwtBearerGrant is the validated assertion of the JWT bearer grant.
+type jwtBearerGrant struct {
+	UserID string
+	Scope  string
+}
+
+// ValidateJWTBearer validates the assertion of the JWT bearer grant, which
+// must be signed by a trusted issuer that allows the client, and maps its
+// subject onto a local user. The scope is limited by the trust configuration
+// of the issuer.
+func (m *modelImpl) ValidateJWTBearer(client *openid.Client, req *openid.AccessTokenRequest) (*jwtBearerGrant, error) {
+	if !allowsGrant(client, openid.JWTBearer) {
+		return nil, openid.ErrUnauthorizedClient.WithDescription("client is not registered for the jwt bearer grant")
+	}
+	if req.Assertion == "" {
+		return nil, openid.ErrInvalidRequest.WithDescription("assertion is required")
+	}
+	var (
+		claims = jwt.MapClaims{}
+		issuer openid.TrustedIssuer
+	)
+	_, err := jwt.ParseWithClaims(req.Assertion, claims, func(token *jwt.Token) (interface{}, error) {
+		iss, _ := claims["iss"].(string)
+		var ok bool
+		issuer, ok = m.issuers.Lookup(iss)
+		if !ok {
+			return nil, fmt.Errorf("issuer %q is not trusted", iss)
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			return nil, errors.New("assertion must be signed with an asymmetric key")
+		}
+		set, err := fetchKeySet(string(issuer.Jwks), issuer.JwksURI)
+		if err != nil {
+			return nil, err
+		}
+		kid, _ := token.Header["kid"].(string)
+		return signingKey(set, kid)
+	})
+	if err != nil {
+		return nil, openid.ErrInvalidGrant.WithDescription(err.Error())
+	}
+	if !issuer.AllowsClient(client.ClientID) {
+		return nil, openid.ErrUnauthorizedClient.WithDescription("client is not allowed to present assertions of the issuer")
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, openid.ErrInvalidGrant.WithDescription("assertion sub is required")
+	}
+	if !hasAudience(claims["aud"], m.tokenEndpoint, m.issuer) {
+		return nil, openid.ErrInvalidGrant.WithDescription("assertion aud must be the token endpoint or the issuer")
+	}
+	exp, _ := claims["exp"].(float64)
+	jti, _ := claims["jti"].(string)
+	if exp == 0 || jti == "" {
+		return nil, openid.ErrInvalidGrant.WithDescription("assertion exp and jti are required")
+	}
+	if err := m.assertion.Put(issuer.Issuer, jti, time.Unix(int64(exp), 0)); err != nil {
+		if err == database.ErrAssertionReplayed {
+			return nil, openid.ErrInvalidGrant.WithDescription("assertion has already been used")
+		}
+		return nil, err
+	}
+
+	userID, err := m.mapSubject(issuer, sub)
+	if err != nil {
+		return nil, openid.ErrInvalidGrant.WithDescription(err.Error())
+	}
+
+	scope := strings.Join(issuer.Scopes, " ")
+	if req.Scope != "" {
+		if exceeded := difference(strings.Fields(req.Scope), issuer.Scopes); len(exceeded) > 0 {
+			return nil, openid.ErrInvalidScope.WithDescription(fmt.Sprintf("scope %q is not allowed for the issuer", strings.Join(exceeded, " ")))
+		}
+		scope = req.Scope
+	}
+	return &jwtBearerGrant{
+		UserID: userID,
+		Scope:  scope,
+	}, nil
+}
+
+// mapSubject returns the id of the local user that the subject of the
+// trusted issuer's assertion identifies.
+func (m *modelImpl) mapSubject(issuer openid.TrustedIssuer, sub string) (string, error) {
+	var (
+		user *openid.User
+		err  error
+	)
+	if userID, ok := issuer.Subjects[sub]; ok {
+		user, err = m.user.Get(userID)
+	} else {
+		switch issuer.SubjectMapping {
+		case openid.SubjectMappingID:
+			user, err = m.user.Get(sub)
+		case openid.SubjectMappingEmail:
+			user, err = m.user.FindByEmail(sub)
+		default:
+			return "", fmt.Errorf("subject %q is not mapped to a user", sub)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
+
+const (
+	// maxBindingMessageLength keeps the binding_message short enough to be
+	// displayed on the authentication device.
+	maxBindingMessageLength = 64
+
+	defaultBackchannelExpiry = 5 * time.Minute
+	maxBackchannelExpiry     = 10 * time.Minute
+
+	// backchannelInterval is the minimum time between the token requests
+	// of the polling clients, which increases when they poll too often.
+	backchannelInterval = 5 * time.Second
+)
+
+// ValidateBackchannelAuthentication validates the backchannel authentication
+// request of the client, identifies the end-user with the hint and stores the
+// request until the end-user approves it.
+func (m *modelImpl) ValidateBackchannelAuthentication(ctx context.Context, client *openid.Client, req *openid.BackchannelAuthenticationRequest) (*openid.BackchannelAuthentication, error) {
+	mode := client.BackchannelTokenDeliveryMode
+	if mode == "" || !allowsGrant(client, openid.CIBA) {
+		return nil, openid.ErrUnauthorizedClient.WithDescription("client is not registered for backchannel authentication")
+	}
+	scope, unknown := m.scopes.Parse(req.Scope)
+	if !scope.Has(openid.ScopeOpenID) {
+		return nil, openid.ErrInvalidRequest.WithDescription("scope is required")
+	}
+	if len(unknown) > 0 {
+		return nil, openid.ErrInvalidScope.WithDescription(fmt.Sprintf("scope %s is not supported", unknown[0]))
+	}
+	var hints int
+	for _, hint := range []string{req.LoginHint, req.IDTokenHint, req.LoginHintToken} {
+		if hint != "" {
+			hints++
+		}
+	}
+	if hints != 1 {
+		return nil, openid.ErrInvalidRequest.WithDescription("exactly one of login_hint, id_token_hint or login_hint_token is required")
+	}
+	if mode != openid.CIBAPoll && req.ClientNotificationToken == "" {
+		return nil, openid.ErrInvalidRequest.WithDescription(fmt.Sprintf("client_notification_token is required for the %s mode", mode))
+	}
+	if utf8.RuneCountInString(req.BindingMessage) > maxBindingMessageLength {
+		return nil, openid.ErrInvalidBindingMessage.WithDescription(fmt.Sprintf("binding_message must not exceed %d characters", maxBindingMessageLength))
+	}
+	if req.RequestedExpiry < 0 {
+		return nil, openid.ErrInvalidRequest.WithDescription("requested_expiry must be a positive integer")
+	}
+	expiry := defaultBackchannelExpiry
+	if req.RequestedExpiry > 0 {
+		expiry = time.Duration(req.RequestedExpiry) * time.Second
+		if expiry > maxBackchannelExpiry {
+			expiry = maxBackchannelExpiry
+		}
+	}
+
+	userID, err := m.backchannelUser(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both ids are bearer secrets, the auth_req_id of the client and the
+	// approval id of the end-user, so they are not derived from each
+	// other.
+	id, err := randstr.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	approvalID, err := randstr.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	auth := &openid.BackchannelAuthentication{
+		ID:                      id,
+		ApprovalID:              approvalID,
+		ClientID:                client.ClientID,
+		UserID:                  userID,
+		Scope:                   req.Scope,
+		ACRValues:               req.ACRValues,
+		BindingMessage:          req.BindingMessage,
+		Status:                  openid.BackchannelPending,
+		DeliveryMode:            mode,
+		ClientNotificationToken: req.ClientNotificationToken,
+		CreatedAt:               now,
+		ExpiresAt:               now.Add(expiry),
+		Interval:                backchannelInterval,
+	}
+	if mode != openid.CIBAPoll {
+		auth.ClientNotificationEndpoint = client.BackchannelClientNotificationEndpoint
+	}
+	if mode == openid.CIBAPush {
+		auth.Confirmation, err = requestConfirmation(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := m.backchannel.Put(auth); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// backchannelUser returns the id of the end-user that the hint of the
+// backchannel authentication request identifies.
+func (m *modelImpl) backchannelUser(client *openid.Client, req *openid.BackchannelAuthenticationRequest) (string, error) {
+	switch {
+	case req.LoginHint != "":
+		return m.findUserByHint(req.LoginHint)
+	case req.IDTokenHint != "":
+		idToken, err := m.parseIDTokenHint(client, req.IDTokenHint)
+		if err != nil {
+			return "", openid.ErrInvalidRequest.WithDescription(err.Error())
+		}
+		if !idToken.StandardClaims.VerifyAudience(client.ClientID, true) {
+			return "", openid.ErrInvalidRequest.WithDescription("id_token_hint was not issued to the client")
+		}
+		userID, err := m.ResolveSubject(client, idToken.StandardClaims.Subject)
+		if err != nil {
+			return "", openid.ErrUnknownUserID.WithDescription(err.Error())
+		}
+		return userID, nil
+	default:
+		return m.parseLoginHintToken(client, req.LoginHintToken)
+	}
+}
+
+// loginHintTokenAlgs are the algorithms that the login_hint_token can be
+// signed with.
+var loginHintTokenAlgs = []string{
+	"HS256", "HS384", "HS512",
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+}
+
+// parseLoginHintToken validates the login_hint_token, which is a JWT signed
+// by the client whose sub is a login_hint, and returns the id of the
+// end-user. Clients without a secret, e.g. public or private_key_jwt
+// clients, can only sign with their public keys.
+func (m *modelImpl) parseLoginHintToken(client *openid.Client, token string) (string, error) {
+	var claims jwt.StandardClaims
+	parser := &jwt.Parser{ValidMethods: loginHintTokenAlgs}
+	_, err := parser.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if client.ClientSecret == "" {
+				return nil, errors.New("login_hint_token must be signed with the client's key")
+			}
+			return []byte(client.ClientSecret), nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+			kid, _ := token.Header["kid"].(string)
+			return clientPublicKey(client, kid)
+		default:
+			return nil, errors.New("login_hint_token signing method is not supported")
+		}
+	})
+	if verr, ok := err.(*jwt.ValidationError); ok && verr.Errors&jwt.ValidationErrorExpired != 0 {
+		return "", openid.ErrExpiredLoginHintToken
+	}
+	if err != nil {
+		return "", openid.ErrInvalidRequest.WithDescription(err.Error())
+	}
+	if claims.Issuer != client.ClientID {
+		return "", openid.ErrInvalidRequest.WithDescription("login_hint_token must be issued by the client")
+	}
+	if claims.ExpiresAt == 0 || claims.Subject == "" {
+		return "", openid.ErrInvalidRequest.WithDescription("login_hint_token exp and sub are required")
+	}
+	return m.findUserByHint(claims.Subject)
+}
+
+// findUserByHint returns the id of the user that the login_hint identifies.
+func (m *modelImpl) findUserByHint(hint string) (string, error) {
+	var (
+		h    = openid.LoginHint(hint)
+		user *openid.User
+		err  error
+	)
+	if email, ok := h.Email(); ok {
+		user, err = m.user.FindByEmail(email)
+	} else if sub, ok := h.Subject(); ok {
+		user, err = m.user.Get(sub)
+	} else {
+		err = errors.New("login_hint must be an email address or a subject identifier")
+	}
+	if err != nil {
+		return "", openid.ErrUnknownUserID.WithDescription(err.Error())
+	}
+	return user.ID, nil
+}
+
+// GetBackchannelApproval returns the pending backchannel authentication
+// request that the end-user in the context is asked to approve.
+func (m *modelImpl) GetBackchannelApproval(ctx context.Context, approvalID string) (*openid.BackchannelAuthentication, error) {
+	userID, ok := openid.GetUserIDContextKey(ctx)
+	if !ok {
+		return nil, openid.ErrLoginRequired
+	}
+	auth, err := m.backchannel.GetByApprovalID(approvalID)
+	if err != nil {
+		return nil, openid.ErrInvalidRequest.WithDescription(err.Error())
+	}
+	if auth.UserID != userID {
+		return nil, openid.ErrAccessDenied.WithDescription("backchannel authentication request was made for a different user")
+	}
+	if auth.Status != openid.BackchannelPending {
+		return nil, openid.ErrInvalidRequest.WithDescription("backchannel authentication request has already been decided")
+	}
+	if auth.Expired() {
+		return nil, openid.ErrExpiredToken
+	}
+	return auth, nil
+}
+
+// ApproveBackchannelAuthentication records the decision of the end-user in
+// the context, and returns the request with the client that made it. Pushed
+// requests are removed, since the tokens are not requested by the client.
+func (m *modelImpl) ApproveBackchannelAuthentication(ctx context.Context, approvalID string, approved bool) (*openid.BackchannelAuthentication, *openid.Client, error) {
+	auth, err := m.GetBackchannelApproval(ctx, approvalID)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := m.client.Get(auth.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth.Status = openid.BackchannelDenied
+	if approved {
+		auth.Status = openid.BackchannelApproved
+		auth.ApprovedAt = time.Now()
+	}
+	if auth.DeliveryMode == openid.CIBAPush {
+		err = m.backchannel.Delete(auth.ID)
+	} else {
+		err = m.backchannel.Put(auth)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return auth, client, nil
+}
+
+// PollBackchannelAuthentication returns the approved backchannel
+// authentication request of the client. Until the end-user decides,
+// authorization_pending is returned, or slow_down if the client polls more
+// often than the interval. The auth_req_id can only be exchanged once.
+func (m *modelImpl) PollBackchannelAuthentication(client *openid.Client, authReqID string) (*openid.BackchannelAuthentication, error) {
+	if authReqID == "" {
+		return nil, openid.ErrInvalidRequest.WithDescription("auth_req_id is required")
+	}
+	auth, err := m.backchannel.Get(authReqID)
+	if err != nil || auth.ClientID != client.ClientID {
+		return nil, openid.ErrInvalidGrant.WithDescription("auth_req_id is invalid")
+	}
+	if auth.DeliveryMode == openid.CIBAPush {
+		return nil, openid.ErrInvalidGrant.WithDescription("tokens are pushed to the client notification endpoint")
+	}
+	if auth.Expired() {
+		if err := m.backchannel.Delete(auth.ID); err != nil {
+			return nil, err
+		}
+		return nil, openid.ErrExpiredToken
+	}
+
+	switch auth.Status {
+	case openid.BackchannelPending:
+		// Only the polling clients are throttled, the ping clients
+		// are expected to wait for the notification.
+		now := time.Now()
+		slowDown := auth.DeliveryMode == openid.CIBAPoll && now.Sub(auth.PolledAt) < auth.Interval
+		if slowDown {
+			auth.Interval += backchannelInterval
+		}
+		auth.PolledAt = now
+		if err := m.backchannel.Put(auth); err != nil {
+			return nil, err
+		}
+		if slowDown {
+			return nil, openid.ErrSlowDown
+		}
+		return nil, openid.ErrAuthorizationPending
+	case openid.BackchannelDenied:
+		if err := m.backchannel.Delete(auth.ID); err != nil {
+			return nil, err
+		}
+		return nil, openid.ErrAccessDenied
+	}
+	if err := m.backchannel.Delete(auth.ID); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// idTokenOption modifies the id token with the user data before it is signed.
+type idTokenOption func(user *openid.User, idToken *openid.IDToken)
+
+// withClaimsLocales adds the localized user claims that match the space
+// separated claims_locales.
+func withClaimsLocales(claimsLocales string) idTokenOption {
+	return func(user *openid.User, idToken *openid.IDToken) {
+		locales := strings.Fields(claimsLocales)
+		if len(locales) == 0 {
+			return
+		}
+		if idToken.Claims == nil {
+			idToken.Claims = make(map[string]interface{})
+		}
+		for k, v := range user.ClaimsForLocales(locales) {
+			idToken.Claims[k] = v
+		}
+	}
+}
+
+// withSessionID adds the sid of the end-user's session, which identifies the
+// session in logout requests.
+func withSessionID(sid string) idTokenOption {
+	return func(user *openid.User, idToken *openid.IDToken) {
+		idToken.SessionID = sid
+	}
+}
+
+// withProvidedClaims adds the claims of the claims providers. The claims
+// cannot override the claims of the user.
+func withProvidedClaims(claims map[string]interface{}) idTokenOption {
+	return func(user *openid.User, idToken *openid.IDToken) {
+		if len(claims) == 0 {
+			return
+		}
+		if idToken.Claims == nil {
+			idToken.Claims = make(map[string]interface{})
+		}
+		for k, v := range claims {
+			if _, exist := idToken.Claims[k]; !exist {
+				idToken.Claims[k] = v
+			}
+		}
+	}
+}
+
+// ProvideClaims returns the custom claims of the user for the client, given
+// the granted scope and the individually requested claims. Only the claims
+// that the granted scopes release, or that were requested individually, are
+// returned.
+func (m *modelImpl) ProvideClaims(ctx context.Context, clientID, userID, scope string, claims []string) (map[string]interface{}, error) {
+	user, err := m.user.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	granted, _ := m.scopes.Parse(scope)
+	released := make(map[string]bool)
+	for _, name := range m.scopes.Claims(granted) {
+		released[name] = true
+	}
+	for _, name := range claims {
+		released[name] = true
+	}
+
+	provided := m.claims.Provide(ctx, openid.ClaimsProviderRequest{
+		User:     user,
+		ClientID: clientID,
+		Scopes:   strings.Fields(scope),
+		Claims:   claims,
+	})
+	for name := range provided {
+		if !released[name] {
+			delete(provided, name)
+		}
+	}
+	return provided, nil
+}
+
+func (m *modelImpl) ProvideIDToken(client *openid.Client, userID string, opts ...idTokenOption) (string, error) {
+	user, err := m.user.Get(userID)
+	if err != nil {
+		return "", err
+	}
+	sub, err := m.ProvideSubject(client, userID)
+	if err != nil {
+		return "", err
+	}
+	idToken := user.ToIDToken()
+	var (
+		now = time.Now().UTC()
+		aud = client.ClientID
+		iss = m.issuer
+		iat = now
+		id  = crypto.NewXID()
+		nbf = now
+		exp = now.Add(2 * time.Hour)
+	)
+	idToken.StandardClaims = jwt.StandardClaims{
+		Audience:  aud,
+		ExpiresAt: exp.Unix(),
+		Id:        id,
+		IssuedAt:  iat.Unix(),
+		Issuer:    iss,
+		NotBefore: nbf.Unix(),
+		Subject:   sub,
+	}
+	for _, o := range opts {
+		o(user, idToken)
+	}
+	return idToken.SignHS256(idTokenKey)
+}
+
+// FrontChannelLogoutURI returns the frontchannel_logout_uri of the client
+// with the iss and sid of the session that ended. The iss and sid are always
+// included, so that they are present when frontchannel_logout_session_required
+// is registered. The returned bool is false if the client did not register a
+// frontchannel_logout_uri.
+func (m *modelImpl) FrontChannelLogoutURI(clientID, sid string) (string, bool) {
+	client, err := m.client.Get(clientID)
+	if err != nil || client.FrontchannelLogoutURI == "" {
+		return "", false
+	}
+	u, err := url.Parse(client.FrontchannelLogoutURI)
+	if err != nil {
+		return "", false
+	}
+	q := u.Query()
+	q.Set("iss", m.issuer)
+	q.Set("sid", sid)
+	u.RawQuery = q.Encode()
+	return u.String(), true
+}
+
+// ProvideLogoutToken returns the backchannel_logout_uri of the client with
+// the logout token for the user's session that ended. The token contains both
+// the sub and the sid, so that it satisfies
+// backchannel_logout_session_required. The returned bool is false if the
+// client did not register a backchannel_logout_uri.
+func (m *modelImpl) ProvideLogoutToken(clientID, userID, sid string) (string, string, bool, error) {
+	client, err := m.client.Get(clientID)
+	if err != nil || client.BackchannelLogoutURI == "" {
+		return "", "", false, nil
+	}
+	sub, err := m.ProvideSubject(client, userID)
+	if err != nil {
+		return "", "", false, err
+	}
+	now := time.Now().UTC()
+	token := openid.NewLogoutToken()
+	token.StandardClaims = jwt.StandardClaims{
+		Audience:  client.ClientID,
+		ExpiresAt: now.Add(2 * time.Minute).Unix(),
+		Id:        crypto.NewXID(),
+		IssuedAt:  now.Unix(),
+		Issuer:    m.issuer,
+		Subject:   sub,
+	}
+	token.SessionID = sid
+	key, err := m.keys.SigningKey()
+	if err != nil {
+		return "", "", false, err
+	}
+	signed, err := token.SignRS256(key.ID, key.PrivateKey)
+	if err != nil {
+		return "", "", false, err
+	}
+	return client.BackchannelLogoutURI, signed, true, nil
+}
+
+// -- helpers
+
+// allowsGrant returns true if the client registered the grant type in its
+// grant_types.
+func allowsGrant(client *openid.Client, grantType openid.GrantType) bool {
+	for _, g := range client.GrantTypes {
+		if grantType.Equal(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// difference returns the values of a that are not in b.
+func difference(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, v := range b {
+		seen[v] = true
+	}
+	var res []string
+	for _, v := range a {
+		if !seen[v] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// intersect returns the values of a that are also in b.
+func intersect(a, b []string) []string {
+	return difference(a, difference(a, b))
+}
+
+func validateLoginHint(user *openid.User, hint string) error {
+	if !openid.LoginHint(hint).Matches(user) {
+		return openid.ErrLoginRequired.WithDescription("login_hint does not match the current user")
+	}
+	return nil
+}
+
+// isEncrypted returns true if the token is a JWE in compact serialization,
+// which has five parts instead of three.
+func isEncrypted(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// symmetricKeySize returns the key size in bytes for symmetric encryption
+// algorithms, or zero if the algorithm is asymmetric.
+func symmetricKeySize(alg, enc string) int {
+	switch alg {
+	case "A128KW", "A128GCMKW":
+		return 16
+	case "A192KW", "A192GCMKW":
+		return 24
+	case "A256KW", "A256GCMKW":
+		return 32
+	case "dir":
+		switch enc {
+		case "A128GCM":
+			return 16
+		case "A192GCM":
+			return 24
+		case "A256GCM", "A128CBC-HS256":
+			return 32
+		case "A192CBC-HS384":
+			return 48
+		case "A256CBC-HS512":
+			return 64
+		}
+	}
+	return 0
+}
+
+// clientSecretKey derives the symmetric encryption key from the client
+// secret as described in section 10.2 of the OpenID Connect Core spec.
+func clientSecretKey(secret string, size int) []byte {
+	var h hash.Hash
+	switch {
+	case size <= sha256.Size:
+		h = sha256.New()
+	case size <= sha512.Size384:
+		h = sha512.New384()
+	default:
+		h = sha512.New()
+	}
+	h.Write([]byte(secret))
+	return h.Sum(nil)[:size]
+}
+
+// clientAuthMethod returns the client authentication method used in the
+// request. The assertion methods are only distinguished once the assertion is
+// parsed.
+func clientAuthMethod(authorization string, creds openid.ClientCredentials) (string, error) {
+	var methods []string
+	if authorization != "" {
+		methods = append(methods, openid.ClientSecretBasic)
+	}
+	if creds.ClientSecret != "" {
+		methods = append(methods, openid.ClientSecretPost)
+	}
+	if creds.ClientAssertion != "" {
+		if creds.ClientAssertionType != openid.ClientAssertionTypeJWTBearer {
+			return "", openid.ErrInvalidClient.WithDescription("client_assertion_type is not supported")
+		}
+		methods = append(methods, openid.PrivateKeyJWT)
+	}
+	switch len(methods) {
+	case 0:
+		if creds.ClientID == "" {
+			return "", openid.ErrInvalidClient.WithDescription("client authentication is required")
+		}
+		return openid.None, nil
+	case 1:
+		return methods[0], nil
+	default:
+		return "", openid.ErrInvalidRequest.WithDescription("only one client authentication method may be used")
+	}
+}
+
+// clientKeySet returns the keys the client registered with jwks or jwks_uri.
+func clientKeySet(client *openid.Client) (*jose.JSONWebKeySet, error) {
+	if client.Jwks == "" && client.JwksURI == "" {
+		return nil, errors.New("client has no registered keys")
+	}
+	return fetchKeySet(client.Jwks, client.JwksURI)
+}
+
+// keySetTTL is how long a key set fetched from a jwks_uri is reused before
+// it is fetched again.
+const keySetTTL = 5 * time.Minute
+
+// maxKeySetSize limits the size of a key set fetched from a jwks_uri.
+const maxKeySetSize = 1 << 20
+
+type cachedKeySet struct {
+	keys      *jose.JSONWebKeySet
+	expiresAt time.Time
+}
+
+var keySets = struct {
+	sync.Mutex
+	byURI map[string]cachedKeySet
+}{byURI: make(map[string]cachedKeySet)}
+
+// fetchKeySet returns the key set given inline, or fetched from the uri.
+func fetchKeySet(inline, uri string) (*jose.JSONWebKeySet, error) {
+	switch {
+	case inline != "":
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal([]byte(inline), &jwks); err != nil {
+			return nil, err
+		}
+		return &jwks, nil
+	case uri != "":
+		return fetchRemoteKeySet(uri)
+	default:
+		return nil, errors.New("no keys are registered")
+	}
+}
+
+// fetchRemoteKeySet fetches the key set from the uri, reusing a previously
+// fetched key set until it expires.
+func fetchRemoteKeySet(uri string) (*jose.JSONWebKeySet, error) {
+	now := time.Now()
+	keySets.Lock()
+	cached, ok := keySets.byURI[uri]
+	keySets.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.keys, nil
+	}
+
+	c := &http.Client{Timeout: 5 * time.Second}
+	resp, err := c.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks_uri returned status %d", resp.StatusCode)
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxKeySetSize)).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	keySets.Lock()
+	keySets.byURI[uri] = cachedKeySet{keys: &jwks, expiresAt: now.Add(keySetTTL)}
+	keySets.Unlock()
+	return &jwks, nil
+}
+
+// clientPublicKey returns the public key of the client with the given key id.
+func clientPublicKey(client *openid.Client, kid string) (interface{}, error) {
+	jwks, err := clientKeySet(client)
+	if err != nil {
+		return nil, err
+	}
+	key, err := signingKey(jwks, kid)
+	if err != nil {
+		return nil, errors.New("client key not found")
+	}
+	return key, nil
+}
+
+// signingKey returns the public signing key with the given key id. Any
+// signing key matches if the key id is empty.
+func signingKey(jwks *jose.JSONWebKeySet, kid string) (interface{}, error) {
+	for _, key := range jwks.Keys {
+		if kid != "" && key.KeyID != kid {
+			continue
+		}
+		if key.Use == "enc" {
+			continue
+		}
+		return key.Public().Key, nil
+	}
+	return nil, errors.New("key not found")
+}
+
+// hasAudience returns true if the aud claim, which is either a string or an
+// array, contains one of the audiences.
+func hasAudience(aud interface{}, audiences ...string) bool {
+	var values []string
+	switch v := aud.(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				values = append(values, s)
+			}
+		}
 	}
-	return idToken.SignHS256([]byte("id_token_key"))
+	return len(intersect(values, audiences)) > 0
 }
@@ -2,13 +2,20 @@ package core_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/internal/core"
 	"github.com/alextanhongpin/go-openid/internal/database"
+	"github.com/alextanhongpin/go-openid/pkg/crypto"
+	"github.com/alextanhongpin/go-openid/pkg/jwks"
 
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -86,6 +93,30 @@ func TestValidateAuthnRequest(t *testing.T) {
 		}
 	})
 
+	t.Run("validate display", func(t *testing.T) {
+		tests := []struct {
+			display string
+			valid   bool
+		}{
+			{"", true},
+			{"page", true},
+			{"popup", true},
+			{"touch", true},
+			{"wap", true},
+			{"modal", false},
+		}
+		for _, tt := range tests {
+			copy := *req
+			copy.Display = tt.display
+			err := model.ValidateAuthnRequest(&copy)
+			if tt.valid {
+				assert.Nil(err)
+			} else {
+				assert.NotNil(err)
+			}
+		}
+	})
+
 	t.Run("validate scope", func(t *testing.T) {
 		copy := *req
 		copy.Scope = ""
@@ -134,7 +165,7 @@ func TestClientValidation(t *testing.T) {
 		copy.ClientID = "null"
 		err := model.ValidateAuthnClient(&copy)
 		assert.NotNil(err)
-		assert.Equal("client does not exist", err.Error())
+		assert.Equal("invalid_request: client_id is invalid", err.Error())
 	})
 
 	t.Run("validate invalid client redirect_uri", func(t *testing.T) {
@@ -142,7 +173,7 @@ func TestClientValidation(t *testing.T) {
 		copy.RedirectURI = "http://unknown"
 		err := model.ValidateAuthnClient(&copy)
 		assert.NotNil(err)
-		assert.Equal("redirect_uri incorrect", err.Error())
+		assert.Equal("invalid_request: redirect_uri is not registered for the client", err.Error())
 	})
 }
 
@@ -187,3 +218,980 @@ func TestUserValidation(t *testing.T) {
 		assert.NotNil("user_id missing", err.Error())
 	})
 }
+
+func TestIDTokenHintValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	// Setup repository.
+	client := database.NewClientKV()
+	client.Put("app", &openid.Client{
+		ClientID:     "app",
+		RedirectURIs: []string{"http://client.example.com/cb"},
+	})
+
+	// Setup model.
+	model := core.NewModel()
+	model.SetClient(client)
+
+	// An expired id token previously issued to the client.
+	idToken := openid.NewIDToken()
+	idToken.StandardClaims.Audience = "app"
+	idToken.StandardClaims.Subject = "1"
+	idToken.StandardClaims.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	hint, err := idToken.SignHS256([]byte("id_token_key"))
+	assert.Nil(err)
+
+	req := &openid.AuthenticationRequest{
+		ClientID:     "app",
+		RedirectURI:  "http://client.example.com/cb",
+		ResponseType: "code",
+		Scope:        "openid",
+		IDTokenHint:  hint,
+	}
+
+	t.Run("validate matching user", func(t *testing.T) {
+		ctx := openid.SetUserIDContextKey(context.Background(), "1")
+		err := model.ValidateIDTokenHint(ctx, req)
+		assert.Nil(err)
+	})
+
+	t.Run("validate different user", func(t *testing.T) {
+		ctx := openid.SetUserIDContextKey(context.Background(), "2")
+		err := model.ValidateIDTokenHint(ctx, req)
+		verr, ok := err.(*openid.ErrorJSON)
+		assert.True(ok, "should return custom error")
+		assert.Equal("login_required", verr.Code)
+	})
+}
+
+func TestPairwiseSubject(t *testing.T) {
+	assert := assert.New(t)
+
+	model := core.NewModel(core.ModelPairwiseSalt([]byte("salt")))
+
+	public := &openid.Client{
+		ClientID:     "public",
+		RedirectURIs: []string{"https://a.example.com/cb"},
+	}
+	pairwise := &openid.Client{
+		ClientID:     "pairwise",
+		SubjectType:  "pairwise",
+		RedirectURIs: []string{"https://a.example.com/cb"},
+	}
+	other := &openid.Client{
+		ClientID:     "other",
+		SubjectType:  "pairwise",
+		RedirectURIs: []string{"https://b.example.com/cb"},
+	}
+
+	t.Run("public client receives the user id", func(t *testing.T) {
+		sub, err := model.ProvideSubject(public, "1")
+		assert.Nil(err)
+		assert.Equal("1", sub)
+	})
+
+	t.Run("pairwise subject differs across sectors", func(t *testing.T) {
+		sub, err := model.ProvideSubject(pairwise, "1")
+		assert.Nil(err)
+		assert.NotEqual("1", sub)
+
+		otherSub, err := model.ProvideSubject(other, "1")
+		assert.Nil(err)
+		assert.NotEqual(sub, otherSub)
+	})
+
+	t.Run("pairwise subject resolves to the user id", func(t *testing.T) {
+		sub, err := model.ProvideSubject(pairwise, "1")
+		assert.Nil(err)
+
+		userID, err := model.ResolveSubject(pairwise, sub)
+		assert.Nil(err)
+		assert.Equal("1", userID)
+
+		_, err = model.ResolveSubject(other, sub)
+		assert.NotNil(err)
+	})
+
+	t.Run("pairwise subject requires a salt", func(t *testing.T) {
+		model := core.NewModel()
+		_, err := model.ProvideSubject(pairwise, "1")
+		assert.NotNil(err)
+	})
+}
+
+func TestAuthenticateClient(t *testing.T) {
+	assert := assert.New(t)
+
+	// Setup repository.
+	client := database.NewClientKV()
+	client.Put("basic", &openid.Client{
+		ClientID:     "basic",
+		ClientSecret: "secret",
+	})
+	client.Put("post", &openid.Client{
+		ClientID:                "post",
+		ClientSecret:            "secret",
+		TokenEndpointAuthMethod: "client_secret_post",
+	})
+	client.Put("jwt", &openid.Client{
+		ClientID:                "jwt",
+		ClientSecret:            "secret",
+		TokenEndpointAuthMethod: "client_secret_jwt",
+	})
+	client.Put("public", &openid.Client{
+		ClientID:                "public",
+		TokenEndpointAuthMethod: "none",
+	})
+	client.Put("mtls", &openid.Client{
+		ClientID:                "mtls",
+		TokenEndpointAuthMethod: "tls_client_auth",
+		TLSClientAuthSANDNS:     "client.example.com",
+	})
+
+	// Setup model.
+	model := core.NewModel(core.ModelTokenEndpoint("https://server.example.com/token"))
+	model.SetClient(client)
+
+	t.Run("authenticate with registered method", func(t *testing.T) {
+		c, err := model.AuthenticateClient(context.Background(), openid.ClientCredentials{
+			ClientID:     "post",
+			ClientSecret: "secret",
+		})
+		assert.Nil(err)
+		assert.Equal("post", c.ClientID)
+
+		c, err = model.AuthenticateClient(context.Background(), openid.ClientCredentials{ClientID: "public"})
+		assert.Nil(err)
+		assert.Equal("public", c.ClientID)
+	})
+
+	t.Run("authenticate with other method", func(t *testing.T) {
+		_, err := model.AuthenticateClient(context.Background(), openid.ClientCredentials{
+			ClientID:     "basic",
+			ClientSecret: "secret",
+		})
+		verr, ok := err.(*openid.ErrorJSON)
+		assert.True(ok, "should return custom error")
+		assert.Equal("invalid_client", verr.Code)
+	})
+
+	t.Run("authenticate with client assertion", func(t *testing.T) {
+		claims := jwt.StandardClaims{
+			Audience:  "https://server.example.com/token",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			Id:        "1",
+			Issuer:    "jwt",
+			Subject:   "jwt",
+		}
+		assertion, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		assert.Nil(err)
+
+		creds := openid.ClientCredentials{
+			ClientAssertionType: openid.ClientAssertionTypeJWTBearer,
+			ClientAssertion:     assertion,
+		}
+		c, err := model.AuthenticateClient(context.Background(), creds)
+		assert.Nil(err)
+		assert.Equal("jwt", c.ClientID)
+
+		_, err = model.AuthenticateClient(context.Background(), creds)
+		assert.NotNil(err, "should not accept replayed assertion")
+	})
+
+	t.Run("authenticate without client certificate", func(t *testing.T) {
+		_, err := model.AuthenticateClient(context.Background(), openid.ClientCredentials{ClientID: "mtls"})
+		verr, ok := err.(*openid.ErrorJSON)
+		assert.True(ok, "should return custom error")
+		assert.Equal("invalid_client", verr.Code)
+	})
+}
+
+func TestFrontChannelLogoutURI(t *testing.T) {
+	assert := assert.New(t)
+
+	client := database.NewClientKV()
+	client.Put("app", &openid.Client{
+		ClientID:              "app",
+		FrontchannelLogoutURI: "https://client.example.com/logout?v=1",
+	})
+	client.Put("none", &openid.Client{
+		ClientID: "none",
+	})
+
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelIssuer("https://server.example.com"),
+	)
+
+	t.Run("client with frontchannel_logout_uri", func(t *testing.T) {
+		uri, ok := model.FrontChannelLogoutURI("app", "sid1")
+		assert.True(ok)
+		assert.Equal("https://client.example.com/logout?iss=https%3A%2F%2Fserver.example.com&sid=sid1&v=1", uri)
+	})
+
+	t.Run("client without frontchannel_logout_uri", func(t *testing.T) {
+		_, ok := model.FrontChannelLogoutURI("none", "sid1")
+		assert.False(ok)
+	})
+
+	t.Run("unknown client", func(t *testing.T) {
+		_, ok := model.FrontChannelLogoutURI("null", "sid1")
+		assert.False(ok)
+	})
+}
+
+func TestProvideLogoutToken(t *testing.T) {
+	assert := assert.New(t)
+
+	client := database.NewClientKV()
+	client.Put("app", &openid.Client{
+		ClientID:             "app",
+		BackchannelLogoutURI: "https://client.example.com/backchannel",
+	})
+	client.Put("none", &openid.Client{
+		ClientID: "none",
+	})
+	user := database.NewUserKV()
+	user.Put("1", &openid.User{ID: "1"})
+
+	keys := jwks.New()
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelUserRepository(user),
+		core.ModelIssuer("https://server.example.com"),
+		core.ModelKeySet(keys),
+	)
+
+	t.Run("client with backchannel_logout_uri", func(t *testing.T) {
+		uri, token, ok, err := model.ProvideLogoutToken("app", "1", "sid1")
+		assert.Nil(err)
+		assert.True(ok)
+		assert.Equal("https://client.example.com/backchannel", uri)
+
+		parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+			key, _ := keys.PublicKey(token.Header["kid"].(string))
+			return key, nil
+		})
+		assert.Nil(err, "should be verified with the published key")
+		assert.Equal("RS256", parsed.Method.Alg())
+		assert.Equal("logout+jwt", parsed.Header["typ"])
+		claims := parsed.Claims.(jwt.MapClaims)
+		assert.Equal("https://server.example.com", claims["iss"])
+		assert.Equal("app", claims["aud"])
+		assert.Equal("1", claims["sub"])
+		assert.Equal("sid1", claims["sid"])
+		assert.NotEmpty(claims["jti"])
+		assert.Contains(claims["events"], openid.BackChannelLogoutEvent)
+		assert.NotContains(claims, "nonce", "should never contain a nonce")
+	})
+
+	t.Run("client without backchannel_logout_uri", func(t *testing.T) {
+		_, _, ok, err := model.ProvideLogoutToken("none", "1", "sid1")
+		assert.Nil(err)
+		assert.False(ok)
+	})
+}
+
+func TestConsentValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	client := database.NewClientKV()
+	client.Put("app", &openid.Client{
+		ClientID:   "app",
+		ClientName: "App",
+	})
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelConsentRepository(database.NewConsentKV()),
+	)
+
+	ctx := openid.SetUserIDContextKey(context.Background(), "1")
+	req := &openid.AuthenticationRequest{
+		ClientID: "app",
+		Scope:    "openid email",
+	}
+
+	t.Run("first request requires consent", func(t *testing.T) {
+		prompt, err := model.ValidateConsent(ctx, req)
+		assert.Nil(err)
+		assert.Equal("App", prompt.ClientName)
+		assert.Equal([]string{"openid", "email"}, prompt.Scopes)
+	})
+
+	t.Run("granted request skips consent", func(t *testing.T) {
+		assert.Nil(model.GrantConsent(ctx, req))
+
+		copy := *req
+		copy.Scope = "openid"
+		prompt, err := model.ValidateConsent(ctx, &copy)
+		assert.Nil(err)
+		assert.Nil(prompt)
+	})
+
+	t.Run("new scope requires incremental consent", func(t *testing.T) {
+		copy := *req
+		copy.Scope = "openid email profile"
+		prompt, err := model.ValidateConsent(ctx, &copy)
+		assert.Nil(err)
+		assert.Equal([]string{"profile"}, prompt.Scopes)
+		assert.Equal([]string{"openid", "email"}, prompt.GrantedScopes)
+	})
+
+	t.Run("new claim requires consent", func(t *testing.T) {
+		copy := *req
+		copy.Claims = `{"userinfo":{"phone_number":null}}`
+		prompt, err := model.ValidateConsent(ctx, &copy)
+		assert.Nil(err)
+		assert.Empty(prompt.Scopes)
+		assert.Equal([]string{"phone_number"}, prompt.Claims)
+	})
+
+	t.Run("authorization details always require consent", func(t *testing.T) {
+		copy := *req
+		copy.Scope = "openid"
+		copy.AuthorizationDetails = `[{"type":"payment_initiation","creditorName":"Merchant A"}]`
+		prompt, err := model.ValidateConsent(ctx, &copy)
+		assert.Nil(err)
+		assert.Empty(prompt.Scopes)
+		assert.Equal("payment_initiation", prompt.AuthorizationDetails[0].Type())
+	})
+}
+
+func TestAuthorizationDetailsValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	details := openid.NewAuthorizationDetailTypes()
+	assert.Nil(details.Register(openid.AuthorizationDetailType{
+		Type:   "payment_initiation",
+		Schema: json.RawMessage(`{"type":"object","required":["creditorName"]}`),
+	}))
+	model := core.NewModel(core.ModelAuthorizationDetailTypes(details))
+
+	req := &openid.AuthenticationRequest{
+		ClientID:     "hello",
+		RedirectURI:  "http://client.example.com/cb",
+		ResponseType: "code",
+		Scope:        "openid",
+	}
+
+	req.AuthorizationDetails = `[{"type":"payment_initiation","creditorName":"Merchant A"}]`
+	assert.Nil(model.ValidateAuthnRequest(req))
+
+	req.AuthorizationDetails = `[{"type":"payment_initiation"}]`
+	err := model.ValidateAuthnRequest(req)
+	assert.Equal("invalid_authorization_details", err.(*openid.ErrorJSON).Code)
+
+	req.AuthorizationDetails = `payment`
+	err = model.ValidateAuthnRequest(req)
+	assert.Equal("invalid_authorization_details", err.(*openid.ErrorJSON).Code)
+}
+
+func TestScopeValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	scopes := openid.NewScopeRegistry()
+	scopes.Register(openid.ScopeDefinition{
+		Name:    "orders:read",
+		Clients: []string{"shop"},
+	})
+	client := database.NewClientKV()
+	for _, id := range []string{"shop", "app"} {
+		client.Put(id, &openid.Client{
+			ClientID:     id,
+			RedirectURIs: []string{"http://client.example.com/cb"},
+		})
+	}
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelScopeRegistry(scopes),
+	)
+
+	req := &openid.AuthenticationRequest{
+		ClientID:     "shop",
+		RedirectURI:  "http://client.example.com/cb",
+		ResponseType: "code",
+		Scope:        "openid orders:read",
+	}
+
+	t.Run("custom scope", func(t *testing.T) {
+		assert.Nil(model.ValidateAuthnRequest(req))
+		assert.Nil(model.ValidateAuthnClient(req))
+	})
+
+	t.Run("unknown scope", func(t *testing.T) {
+		copy := *req
+		copy.Scope = "openid orders:write"
+		err := model.ValidateAuthnRequest(&copy)
+		assert.Equal("invalid_scope", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("scope not allowed for the client", func(t *testing.T) {
+		copy := *req
+		copy.ClientID = "app"
+		err := model.ValidateAuthnClient(&copy)
+		assert.Equal("invalid_scope", err.(*openid.ErrorJSON).Code)
+	})
+}
+
+type groupsProvider struct{}
+
+func (groupsProvider) Claims() []string {
+	return []string{"groups"}
+}
+
+func (groupsProvider) Provide(ctx context.Context, req openid.ClaimsProviderRequest) (map[string]interface{}, error) {
+	return map[string]interface{}{"groups": []string{req.User.ID + "-admin"}}, nil
+}
+
+func TestProvideClaims(t *testing.T) {
+	assert := assert.New(t)
+
+	user := database.NewUserKV()
+	user.Put("1", &openid.User{ID: "1"})
+
+	claims := openid.NewClaimsProviders()
+	assert.Nil(claims.Register(groupsProvider{}, time.Second))
+
+	scopes := openid.NewScopeRegistry()
+	assert.Nil(scopes.Register(openid.ScopeDefinition{Name: "groups", Claims: []string{"groups"}}))
+
+	model := core.NewModel(
+		core.ModelUserRepository(user),
+		core.ModelClaimsProviders(claims),
+		core.ModelScopeRegistry(scopes),
+	)
+
+	t.Run("granted scope", func(t *testing.T) {
+		res, err := model.ProvideClaims(context.Background(), "app", "1", "openid groups", nil)
+		assert.Nil(err)
+		assert.Equal(map[string]interface{}{"groups": []string{"1-admin"}}, res)
+	})
+
+	t.Run("scope not granted", func(t *testing.T) {
+		res, err := model.ProvideClaims(context.Background(), "app", "1", "openid profile", nil)
+		assert.Nil(err)
+		assert.Empty(res, "should only release the claims of the granted scopes")
+	})
+
+	t.Run("requested claim", func(t *testing.T) {
+		res, err := model.ProvideClaims(context.Background(), "app", "1", "openid", []string{"groups"})
+		assert.Nil(err)
+		assert.Equal(map[string]interface{}{"groups": []string{"1-admin"}}, res)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := model.ProvideClaims(context.Background(), "app", "2", "openid profile", nil)
+		assert.NotNil(err)
+	})
+}
+
+func TestResolveResource(t *testing.T) {
+	assert := assert.New(t)
+
+	resources := openid.NewResourceRegistry()
+	assert.Nil(resources.Register(openid.Resource{URI: "https://a.example.com"}))
+	assert.Nil(resources.Register(openid.Resource{URI: "https://b.example.com"}))
+
+	model := core.NewModel(core.ModelResourceRegistry(resources))
+	granted := []string{"https://a.example.com", "https://b.example.com"}
+
+	t.Run("requested resource", func(t *testing.T) {
+		res, err := model.ResolveResource(granted, []string{"https://b.example.com"})
+		assert.Nil(err)
+		assert.Equal("https://b.example.com", res.URI)
+	})
+
+	t.Run("single granted resource", func(t *testing.T) {
+		res, err := model.ResolveResource(granted[:1], nil)
+		assert.Nil(err)
+		assert.Equal("https://a.example.com", res.URI)
+	})
+
+	t.Run("no resource", func(t *testing.T) {
+		res, err := model.ResolveResource(nil, nil)
+		assert.Nil(err)
+		assert.Nil(res)
+	})
+
+	t.Run("resource not granted", func(t *testing.T) {
+		_, err := model.ResolveResource(granted[:1], []string{"https://b.example.com"})
+		assert.Equal("invalid_target", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("multiple resources", func(t *testing.T) {
+		_, err := model.ResolveResource(granted, granted)
+		assert.Equal("invalid_target", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("unregistered resource", func(t *testing.T) {
+		_, err := model.ResolveResource(nil, []string{"https://c.example.com"})
+		assert.Equal("invalid_target", err.(*openid.ErrorJSON).Code)
+	})
+}
+
+func TestProvideToken(t *testing.T) {
+	assert := assert.New(t)
+
+	client := database.NewClientKV()
+	client.Put("app", &openid.Client{ClientID: "app"})
+	user := database.NewUserKV()
+	user.Put("1", &openid.User{ID: "1"})
+
+	keys := jwks.New()
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelUserRepository(user),
+		core.ModelIssuer("https://server.example.com"),
+		core.ModelKeySet(keys),
+	)
+
+	c, _ := client.Get("app")
+	token, err := model.ProvideToken(c, "1", time.Hour)
+	assert.Nil(err)
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		key, _ := keys.PublicKey(token.Header["kid"].(string))
+		return key, nil
+	})
+	assert.Nil(err, "should be verified with the published key")
+	assert.Equal("RS256", parsed.Method.Alg())
+	assert.Equal("at+jwt", parsed.Header["typ"])
+
+	claims := parsed.Claims.(jwt.MapClaims)
+	assert.Equal("https://server.example.com", claims["iss"])
+	assert.Equal("app", claims["client_id"])
+	assert.Equal("1", claims["sub"])
+	assert.NotEmpty(claims["jti"])
+
+	_, _, u, err := model.ParseToken(token)
+	assert.Nil(err)
+	assert.Equal("1", u.ID)
+
+	_, _, _, err = core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelUserRepository(user),
+		core.ModelKeySet(jwks.New()),
+	).ParseToken(token)
+	assert.NotNil(err, "should reject tokens signed by unknown keys")
+
+	_, _, _, err = core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelUserRepository(user),
+		core.ModelIssuer("https://other.example.com"),
+		core.ModelKeySet(keys),
+	).ParseToken(token)
+	assert.NotNil(err, "should reject tokens of another issuer")
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       "1",
+		"client_id": "app",
+	}).SignedString([]byte("access_token_secret"))
+	assert.Nil(err)
+	_, _, _, err = model.ParseToken(forged)
+	assert.NotNil(err, "should only accept the secret for reference tokens")
+}
+
+func TestRevokeToken(t *testing.T) {
+	assert := assert.New(t)
+
+	client := database.NewClientKV()
+	client.Put("app", &openid.Client{ClientID: "app"})
+	client.Put("other", &openid.Client{ClientID: "other"})
+	user := database.NewUserKV()
+	user.Put("1", &openid.User{ID: "1"})
+
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelUserRepository(user),
+		core.ModelKeySet(jwks.New()),
+	)
+
+	app, _ := client.Get("app")
+	other, _ := client.Get("other")
+	token, err := model.ProvideToken(app, "1", time.Hour)
+	assert.Nil(err)
+
+	assert.Nil(model.RevokeToken(other, token))
+	_, _, _, err = model.ParseToken(token)
+	assert.Nil(err, "should not revoke the tokens of another client")
+
+	assert.Nil(model.RevokeToken(app, token))
+	_, _, _, err = model.ParseToken(token)
+	assert.NotNil(err, "should reject the revoked token")
+
+	assert.Nil(model.RevokeToken(app, "invalid"), "should ignore invalid tokens")
+}
+
+func TestValidateTokenExchange(t *testing.T) {
+	assert := assert.New(t)
+
+	client := database.NewClientKV()
+	client.Put("app", &openid.Client{ClientID: "app"})
+	client.Put("svc", &openid.Client{ClientID: "svc", GrantTypes: []string{openid.TokenExchange.String()}})
+	client.Put("admin", &openid.Client{ClientID: "admin", GrantTypes: []string{openid.TokenExchange.String()}})
+	client.Put("other", &openid.Client{ClientID: "other"})
+	user := database.NewUserKV()
+	user.Put("1", &openid.User{ID: "1"})
+	user.Put("2", &openid.User{ID: "2"})
+
+	resources := openid.NewResourceRegistry()
+	assert.Nil(resources.Register(openid.Resource{URI: "https://orders.example.com"}))
+	assert.Nil(resources.Register(openid.Resource{URI: "https://billing.example.com"}))
+
+	policies := openid.NewTokenExchangePolicies()
+	assert.Nil(policies.Register(openid.TokenExchangePolicy{
+		ClientID:  "svc",
+		Audiences: []string{"https://orders.example.com"},
+		Scopes:    []string{"orders:read"},
+	}))
+	assert.Nil(policies.Register(openid.TokenExchangePolicy{
+		ClientID:      "admin",
+		Audiences:     []string{"https://orders.example.com"},
+		Scopes:        []string{"orders:read"},
+		Impersonation: true,
+	}))
+	assert.Nil(policies.Register(openid.TokenExchangePolicy{
+		ClientID:  "other",
+		Audiences: []string{"https://orders.example.com"},
+	}))
+
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelUserRepository(user),
+		core.ModelResourceRegistry(resources),
+		core.ModelTokenExchangePolicies(policies),
+		core.ModelKeySet(jwks.New()),
+	)
+
+	app, _ := client.Get("app")
+	svc, _ := client.Get("svc")
+	admin, _ := client.Get("admin")
+	idToken, err := model.ProvideIDToken(app, "1")
+	assert.Nil(err)
+	actorToken, err := model.ProvideToken(svc, "2", time.Hour)
+	assert.Nil(err)
+
+	req := func() *openid.AccessTokenRequest {
+		return &openid.AccessTokenRequest{
+			GrantType:        openid.TokenExchange.String(),
+			SubjectToken:     idToken,
+			SubjectTokenType: openid.TokenTypeIDToken,
+			ActorToken:       actorToken,
+			ActorTokenType:   openid.TokenTypeAccessToken,
+			Audience:         []string{"https://orders.example.com"},
+		}
+	}
+
+	t.Run("delegation", func(t *testing.T) {
+		ex, err := model.ValidateTokenExchange(svc, req())
+		assert.Nil(err)
+		assert.Equal("1", ex.UserID)
+		assert.Equal("https://orders.example.com", ex.Resource.URI)
+		assert.Equal("orders:read", ex.Scope, "should be limited by the policy")
+		assert.Equal(&openid.Actor{Subject: "2", ClientID: "svc"}, ex.Actor)
+	})
+
+	t.Run("impersonation", func(t *testing.T) {
+		r := req()
+		r.ActorToken, r.ActorTokenType = "", ""
+		_, err := model.ValidateTokenExchange(svc, r)
+		assert.Equal("invalid_request", err.(*openid.ErrorJSON).Code, "should require an actor token")
+
+		ex, err := model.ValidateTokenExchange(admin, r)
+		assert.Nil(err)
+		assert.Nil(ex.Actor)
+	})
+
+	t.Run("client without policy", func(t *testing.T) {
+		_, err := model.ValidateTokenExchange(app, req())
+		assert.Equal("unauthorized_client", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("client without grant type", func(t *testing.T) {
+		other, _ := client.Get("other")
+		_, err := model.ValidateTokenExchange(other, req())
+		assert.Equal("unauthorized_client", err.(*openid.ErrorJSON).Code, "should require the registered grant type")
+	})
+
+	t.Run("audience not allowed", func(t *testing.T) {
+		r := req()
+		r.Audience = []string{"https://billing.example.com"}
+		_, err := model.ValidateTokenExchange(svc, r)
+		assert.Equal("invalid_target", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("scope not allowed", func(t *testing.T) {
+		r := req()
+		r.Scope = "orders:write"
+		_, err := model.ValidateTokenExchange(svc, r)
+		assert.Equal("invalid_scope", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("invalid subject token", func(t *testing.T) {
+		r := req()
+		r.SubjectTokenType = openid.TokenTypeAccessToken
+		_, err := model.ValidateTokenExchange(svc, r)
+		assert.Equal("invalid_request", err.(*openid.ErrorJSON).Code)
+	})
+}
+
+func TestValidateJWTBearer(t *testing.T) {
+	assert := assert.New(t)
+
+	client := database.NewClientKV()
+	client.Put("partner", &openid.Client{ClientID: "partner", GrantTypes: []string{openid.JWTBearer.String()}})
+	client.Put("legacy", &openid.Client{ClientID: "legacy"})
+	client.Put("app", &openid.Client{ClientID: "app", GrantTypes: []string{openid.JWTBearer.String()}})
+	user := database.NewUserKV()
+	user.Put("1", &openid.User{ID: "1"})
+
+	key, err := jwks.GenerateKey()
+	assert.Nil(err)
+	set, err := json.Marshal(jwks.New(key).JSONWebKeySet())
+	assert.Nil(err)
+
+	issuers := openid.NewTrustedIssuers()
+	assert.Nil(issuers.Register(openid.TrustedIssuer{
+		Issuer:   "https://idp.partner.com",
+		Jwks:     set,
+		Clients:  []string{"partner", "legacy"},
+		Scopes:   []string{"orders:read"},
+		Subjects: map[string]string{"alice": "1"},
+	}))
+
+	var fetched int
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		w.Write(set)
+	}))
+	defer remote.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, string(set), http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+	for iss, uri := range map[string]string{
+		"https://idp.remote.com": remote.URL,
+		"https://idp.broken.com": broken.URL,
+	} {
+		assert.Nil(issuers.Register(openid.TrustedIssuer{
+			Issuer:   iss,
+			JwksURI:  uri,
+			Clients:  []string{"partner"},
+			Subjects: map[string]string{"alice": "1"},
+		}))
+	}
+
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelUserRepository(user),
+		core.ModelTrustedIssuers(issuers),
+		core.ModelIssuer("https://server.example.com"),
+	)
+
+	assertion := func(claims jwt.MapClaims) string {
+		c := jwt.MapClaims{
+			"iss": "https://idp.partner.com",
+			"sub": "alice",
+			"aud": []string{"https://server.example.com"},
+			"exp": time.Now().Add(time.Minute).Unix(),
+			"jti": crypto.NewXID(),
+		}
+		for k, v := range claims {
+			c[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+		token.Header["kid"] = key.ID
+		s, err := token.SignedString(key.PrivateKey)
+		assert.Nil(err)
+		return s
+	}
+	partner, _ := client.Get("partner")
+	app, _ := client.Get("app")
+
+	t.Run("valid assertion", func(t *testing.T) {
+		grant, err := model.ValidateJWTBearer(partner, &openid.AccessTokenRequest{Assertion: assertion(nil)})
+		assert.Nil(err)
+		assert.Equal("1", grant.UserID)
+		assert.Equal("orders:read", grant.Scope)
+	})
+
+	t.Run("replayed assertion", func(t *testing.T) {
+		req := &openid.AccessTokenRequest{Assertion: assertion(nil)}
+		_, err := model.ValidateJWTBearer(partner, req)
+		assert.Nil(err)
+		_, err = model.ValidateJWTBearer(partner, req)
+		assert.Equal("invalid_grant", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("client not allowed", func(t *testing.T) {
+		_, err := model.ValidateJWTBearer(app, &openid.AccessTokenRequest{Assertion: assertion(nil)})
+		assert.Equal("unauthorized_client", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("client without grant type", func(t *testing.T) {
+		legacy, _ := client.Get("legacy")
+		_, err := model.ValidateJWTBearer(legacy, &openid.AccessTokenRequest{Assertion: assertion(nil)})
+		assert.Equal("unauthorized_client", err.(*openid.ErrorJSON).Code, "should require the registered grant type")
+	})
+
+	t.Run("remote key set", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := model.ValidateJWTBearer(partner, &openid.AccessTokenRequest{Assertion: assertion(jwt.MapClaims{"iss": "https://idp.remote.com"})})
+			assert.Nil(err)
+		}
+		assert.Equal(1, fetched, "should reuse the fetched key set")
+
+		_, err := model.ValidateJWTBearer(partner, &openid.AccessTokenRequest{Assertion: assertion(jwt.MapClaims{"iss": "https://idp.broken.com"})})
+		assert.Equal("invalid_grant", err.(*openid.ErrorJSON).Code, "should reject a key set returned with an error status")
+	})
+
+	t.Run("invalid claims", func(t *testing.T) {
+		for name, claims := range map[string]jwt.MapClaims{
+			"untrusted issuer": {"iss": "https://idp.other.com"},
+			"wrong audience":   {"aud": "https://other.example.com"},
+			"expired":          {"exp": time.Now().Add(-time.Minute).Unix()},
+			"missing jti":      {"jti": ""},
+			"unmapped subject": {"sub": "bob"},
+		} {
+			_, err := model.ValidateJWTBearer(partner, &openid.AccessTokenRequest{Assertion: assertion(claims)})
+			assert.Equal("invalid_grant", err.(*openid.ErrorJSON).Code, name)
+		}
+	})
+
+	t.Run("scope not allowed", func(t *testing.T) {
+		_, err := model.ValidateJWTBearer(partner, &openid.AccessTokenRequest{Assertion: assertion(nil), Scope: "orders:write"})
+		assert.Equal("invalid_scope", err.(*openid.ErrorJSON).Code)
+	})
+}
+
+func TestBackchannelAuthentication(t *testing.T) {
+	assert := assert.New(t)
+
+	client := database.NewClientKV()
+	client.Put("poll", &openid.Client{ClientID: "poll", ClientSecret: "secret", GrantTypes: []string{openid.CIBA.String()}, BackchannelTokenDeliveryMode: openid.CIBAPoll})
+	client.Put("ping", &openid.Client{ClientID: "ping", GrantTypes: []string{openid.CIBA.String()}, BackchannelTokenDeliveryMode: openid.CIBAPing})
+	client.Put("push", &openid.Client{ClientID: "push", GrantTypes: []string{openid.CIBA.String()}, BackchannelTokenDeliveryMode: openid.CIBAPush, DPoPBoundAccessTokens: true})
+	client.Put("app", &openid.Client{ClientID: "app"})
+	client.Put("legacy", &openid.Client{ClientID: "legacy", BackchannelTokenDeliveryMode: openid.CIBAPoll})
+	user := database.NewUserKV()
+	user.Put("1", &openid.User{ID: "1"})
+
+	model := core.NewModel(
+		core.ModelClientRepository(client),
+		core.ModelUserRepository(user),
+	)
+	ctx := context.Background()
+	poll, _ := client.Get("poll")
+	ping, _ := client.Get("ping")
+	push, _ := client.Get("push")
+	app, _ := client.Get("app")
+
+	t.Run("invalid request", func(t *testing.T) {
+		_, err := model.ValidateBackchannelAuthentication(ctx, app, &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHint: "sub:1"})
+		assert.Equal("unauthorized_client", err.(*openid.ErrorJSON).Code)
+
+		legacy, _ := client.Get("legacy")
+		_, err = model.ValidateBackchannelAuthentication(ctx, legacy, &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHint: "sub:1"})
+		assert.Equal("unauthorized_client", err.(*openid.ErrorJSON).Code, "should require the registered grant type")
+
+		_, err = model.ValidateBackchannelAuthentication(ctx, poll, &openid.BackchannelAuthenticationRequest{Scope: "openid"})
+		assert.Equal("invalid_request", err.(*openid.ErrorJSON).Code, "should require a hint")
+
+		_, err = model.ValidateBackchannelAuthentication(ctx, poll, &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHint: "sub:2"})
+		assert.Equal("unknown_user_id", err.(*openid.ErrorJSON).Code)
+
+		_, err = model.ValidateBackchannelAuthentication(ctx, ping, &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHint: "sub:1"})
+		assert.Equal("invalid_request", err.(*openid.ErrorJSON).Code, "should require the client notification token")
+
+		_, err = model.ValidateBackchannelAuthentication(ctx, poll, &openid.BackchannelAuthenticationRequest{
+			Scope:          "openid",
+			LoginHint:      "sub:1",
+			BindingMessage: strings.Repeat("x", 65),
+		})
+		assert.Equal("invalid_binding_message", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("expired login_hint_token", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+			Issuer:    "poll",
+			Subject:   "sub:1",
+			ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+		}).SignedString([]byte("secret"))
+		assert.Nil(err)
+		_, err = model.ValidateBackchannelAuthentication(ctx, poll, &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHintToken: token})
+		assert.Equal("expired_login_hint_token", err.(*openid.ErrorJSON).Code)
+	})
+
+	t.Run("invalid login_hint_token", func(t *testing.T) {
+		claims := jwt.StandardClaims{
+			Issuer:    "ping",
+			Subject:   "sub:1",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(""))
+		assert.Nil(err)
+		req := &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHintToken: token, ClientNotificationToken: "xyz"}
+		_, err = model.ValidateBackchannelAuthentication(ctx, ping, req)
+		assert.Equal("invalid_request", err.(*openid.ErrorJSON).Code, "should not accept an empty secret")
+
+		claims.Issuer = "poll"
+		token, err = jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		assert.Nil(err)
+		_, err = model.ValidateBackchannelAuthentication(ctx, poll, &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHintToken: token})
+		assert.Equal("invalid_request", err.(*openid.ErrorJSON).Code, "should not accept the none algorithm")
+	})
+
+	t.Run("approve and poll", func(t *testing.T) {
+		auth, err := model.ValidateBackchannelAuthentication(ctx, poll, &openid.BackchannelAuthenticationRequest{
+			Scope:          "openid",
+			LoginHint:      "sub:1",
+			BindingMessage: "W4SCT",
+		})
+		assert.Nil(err)
+		assert.Equal("1", auth.UserID)
+
+		_, err = model.PollBackchannelAuthentication(poll, auth.ID)
+		assert.Equal("authorization_pending", err.(*openid.ErrorJSON).Code)
+		_, err = model.PollBackchannelAuthentication(poll, auth.ID)
+		assert.Equal("slow_down", err.(*openid.ErrorJSON).Code)
+		_, err = model.PollBackchannelAuthentication(app, auth.ID)
+		assert.Equal("invalid_grant", err.(*openid.ErrorJSON).Code, "should only be polled by the client")
+
+		ctx := openid.SetUserIDContextKey(context.Background(), "2")
+		_, _, err = model.ApproveBackchannelAuthentication(ctx, auth.ApprovalID, true)
+		assert.Equal("access_denied", err.(*openid.ErrorJSON).Code, "should only be approved by the end-user")
+
+		ctx = openid.SetUserIDContextKey(context.Background(), "1")
+		_, _, err = model.ApproveBackchannelAuthentication(ctx, auth.ApprovalID, true)
+		assert.Nil(err)
+
+		approved, err := model.PollBackchannelAuthentication(poll, auth.ID)
+		assert.Nil(err)
+		assert.Equal(openid.BackchannelApproved, approved.Status)
+
+		_, err = model.PollBackchannelAuthentication(poll, auth.ID)
+		assert.Equal("invalid_grant", err.(*openid.ErrorJSON).Code, "should only be exchanged once")
+	})
+
+	t.Run("push binding", func(t *testing.T) {
+		req := &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHint: "sub:1", ClientNotificationToken: "8d67dc78"}
+		_, err := model.ValidateBackchannelAuthentication(ctx, push, req)
+		assert.Equal("invalid_dpop_proof", err.(*openid.ErrorJSON).Code, "should require the dpop proof of the client")
+
+		auth, err := model.ValidateBackchannelAuthentication(openid.SetDPoPContextKey(ctx, "jkt"), push, req)
+		assert.Nil(err)
+		assert.Equal("jkt", auth.Confirmation.JKT, "should bind the pushed tokens to the key of the request")
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		auth, err := model.ValidateBackchannelAuthentication(ctx, poll, &openid.BackchannelAuthenticationRequest{Scope: "openid", LoginHint: "sub:1"})
+		assert.Nil(err)
+
+		ctx := openid.SetUserIDContextKey(context.Background(), "1")
+		_, _, err = model.ApproveBackchannelAuthentication(ctx, auth.ApprovalID, false)
+		assert.Nil(err)
+
+		_, err = model.PollBackchannelAuthentication(poll, auth.ID)
+		assert.Equal("access_denied", err.(*openid.ErrorJSON).Code)
+	})
+}
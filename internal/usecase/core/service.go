@@ -3,23 +3,69 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/alextanhongpin/go-openid"
-	"github.com/alextanhongpin/go-openid/model"
+	"github.com/alextanhongpin/go-openid/pkg/mtls"
 )
 
+// Model is the model that the service is built on. It is declared with the
+// service, since the tokens and grants pass the unexported types of this
+// package.
+type Model interface {
+	// -- authentication request
+	ValidateAuthnRequest(req *openid.AuthenticationRequest) error
+	ValidateAuthnClient(req *openid.AuthenticationRequest) error
+	ValidateAuthnUser(ctx context.Context, req *openid.AuthenticationRequest) error
+	ValidateRedirectURI(req *openid.AuthenticationRequest) error
+	ValidateIDTokenHint(ctx context.Context, req *openid.AuthenticationRequest) error
+	ValidateLoginHint(ctx context.Context, req *openid.AuthenticationRequest) error
+	ValidateConsent(ctx context.Context, req *openid.AuthenticationRequest) (*openid.ConsentPrompt, error)
+	GrantConsent(ctx context.Context, req *openid.AuthenticationRequest) error
+	NewCode(ctx context.Context, req *openid.AuthenticationRequest) string
+
+	// -- token endpoint
+	AuthenticateClient(ctx context.Context, creds openid.ClientCredentials) (*openid.Client, error)
+	ValidateCode(c string) (*openid.Code, error)
+	ParseToken(token string) (*accessTokenClaims, *openid.Client, *openid.User, error)
+	ParseRefreshToken(token string) (*accessTokenClaims, string, error)
+	RevokeToken(client *openid.Client, token string) error
+	ResolveResource(granted, requested []string) (*openid.Resource, error)
+	ValidateTokenExchange(client *openid.Client, req *openid.AccessTokenRequest) (*tokenExchange, error)
+	ValidateJWTBearer(client *openid.Client, req *openid.AccessTokenRequest) (*jwtBearerGrant, error)
+	ProvideToken(client *openid.Client, userID string, duration time.Duration, opts ...accessTokenOption) (string, error)
+	ProvideIDToken(client *openid.Client, userID string, opts ...idTokenOption) (string, error)
+	ProvideClaims(ctx context.Context, clientID, userID, scope string, claims []string) (map[string]interface{}, error)
+
+	// -- backchannel authentication
+	ValidateBackchannelAuthentication(ctx context.Context, client *openid.Client, req *openid.BackchannelAuthenticationRequest) (*openid.BackchannelAuthentication, error)
+	GetBackchannelApproval(ctx context.Context, approvalID string) (*openid.BackchannelAuthentication, error)
+	ApproveBackchannelAuthentication(ctx context.Context, approvalID string, approved bool) (*openid.BackchannelAuthentication, *openid.Client, error)
+	PollBackchannelAuthentication(client *openid.Client, authReqID string) (*openid.BackchannelAuthentication, error)
+
+	// -- logout
+	ValidateEndSession(ctx context.Context, req *openid.EndSessionRequest) (*openid.Client, bool, error)
+	FrontChannelLogoutURI(clientID, sid string) (string, bool)
+	ProvideLogoutToken(clientID, userID, sid string) (string, string, bool, error)
+}
+
+var _ Model = (*modelImpl)(nil)
+
 type serviceImpl struct {
-	model model.Core
+	model Model
 }
 
 // NewService returns a new service.
-func NewService(model model.Core) serviceImpl {
+func NewService(model Model) serviceImpl {
 	return serviceImpl{model}
 }
 
 // SetModel sets the existing model to the given model.
-func (s *serviceImpl) SetModel(model model.Core) {
+func (s *serviceImpl) SetModel(model Model) {
 	s.model = model
 }
 
@@ -27,7 +73,7 @@ func (s *serviceImpl) SetModel(model model.Core) {
 // the type of flow it is using.
 func (s *serviceImpl) PreAuthenticate(req *openid.AuthenticationRequest) error {
 	if req == nil {
-		return errors.New("arguments cannot be nil")
+		return openid.ErrInvalidRequest
 	}
 	if err := s.model.ValidateAuthnRequest(req); err != nil {
 		return err
@@ -36,6 +82,39 @@ func (s *serviceImpl) PreAuthenticate(req *openid.AuthenticationRequest) error {
 	return s.model.ValidateAuthnClient(req)
 }
 
+// ValidateRedirectURI validates the client and the redirect uri of the
+// authentication request. Errors returned by it must be shown to the end-user
+// instead of being sent to the redirect uri.
+func (s *serviceImpl) ValidateRedirectURI(req *openid.AuthenticationRequest) error {
+	if req == nil {
+		return openid.ErrInvalidRequest
+	}
+	return s.model.ValidateRedirectURI(req)
+}
+
+// CheckSession validates the hints in the authentication request against the
+// user in the current session. A login_required error indicates that the
+// session belongs to a different user.
+func (s *serviceImpl) CheckSession(ctx context.Context, req *openid.AuthenticationRequest) error {
+	if req == nil {
+		return openid.ErrInvalidRequest
+	}
+	if err := s.model.ValidateIDTokenHint(ctx, req); err != nil {
+		return err
+	}
+	return s.model.ValidateLoginHint(ctx, req)
+}
+
+// Consent returns the scopes and claims that the end-user has to consent to
+// before the client is authorized. Nil is returned if the end-user has
+// granted them previously.
+func (s *serviceImpl) Consent(ctx context.Context, req *openid.AuthenticationRequest) (*openid.ConsentPrompt, error) {
+	if req == nil {
+		return nil, openid.ErrInvalidRequest
+	}
+	return s.model.ValidateConsent(ctx, req)
+}
+
 // Authenticate performs the full authentication and validation of all fields.
 func (s *serviceImpl) Authenticate(ctx context.Context, req *openid.AuthenticationRequest) (*openid.AuthenticationResponse, error) {
 	if err := s.model.ValidateAuthnRequest(req); err != nil {
@@ -47,51 +126,622 @@ func (s *serviceImpl) Authenticate(ctx context.Context, req *openid.Authenticati
 	if err := s.model.ValidateAuthnClient(req); err != nil {
 		return nil, err
 	}
-	return &openid.AuthenticationResponse{
-		Code:  s.model.NewCode(),
+	if err := s.model.ValidateIDTokenHint(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := s.model.GrantConsent(ctx, req); err != nil {
+		return nil, err
+	}
+	res := openid.AuthenticationResponse{
+		Code:  s.model.NewCode(ctx, req),
 		State: req.State,
-	}, nil
+	}
+	// The session_state lets the client detect changes to the session
+	// through the check_session_iframe.
+	if browserState, ok := openid.GetBrowserStateContextKey(ctx); ok {
+		state, err := openid.NewSessionState(req.ClientID, req.RedirectURI, browserState)
+		if err != nil {
+			return nil, err
+		}
+		res.SessionState = state
+	}
+	return &res, nil
 }
 
 func (s *serviceImpl) Token(ctx context.Context, req *openid.AccessTokenRequest) (*openid.AccessTokenResponse, error) {
-	auth, ok := openid.GetAuthContextKey(ctx)
-	if !ok {
-		return nil, errors.New("missing authorization header")
-	}
-	client, err := s.model.ValidateClientAuthHeader(auth)
+	client, err := s.model.AuthenticateClient(ctx, req.ClientCredentials)
 	if err != nil {
 		return nil, err
 	}
+	switch {
+	case openid.RefreshToken.Equal(req.GrantType):
+		return s.refresh(ctx, client, req)
+	case openid.TokenExchange.Equal(req.GrantType):
+		return s.exchange(ctx, client, req)
+	case openid.JWTBearer.Equal(req.GrantType):
+		return s.jwtBearer(ctx, client, req)
+	case openid.CIBA.Equal(req.GrantType):
+		return s.ciba(ctx, client, req)
+	case !openid.AuthorizationCode.Equal(req.GrantType):
+		return nil, openid.ErrUnsupportedGrantType.WithDescription(fmt.Sprintf("grant_type %q is not supported", req.GrantType))
+	}
 	if ok := client.GetRedirectURIs().Contains(req.RedirectURI); !ok {
-		return nil, errors.New("redirect_uri does not match")
+		return nil, openid.ErrInvalidGrant.WithDescription("redirect_uri does not match")
+	}
+
+	code, err := s.model.ValidateCode(req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if code.ClientID != client.ClientID {
+		return nil, openid.ErrInvalidGrant.WithDescription("code was not issued to the client")
 	}
 
 	userID, ok := openid.GetUserIDContextKey(ctx)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, openid.ErrInvalidRequest.WithDescription("end-user session is required")
+	}
+
+	resource, err := s.model.ResolveResource(code.Resource, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+	// The refresh token keeps the resources of the authorization, so that
+	// it can obtain access tokens for each of them.
+	granted := code.Resource
+	if len(granted) == 0 && resource != nil {
+		granted = []string{resource.URI}
+	}
+
+	opts, tokenType, err := tokenBinding(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	var authTime int64
+	if !code.AuthTime.IsZero() {
+		authTime = code.AuthTime.Unix()
+	}
+	resourceOpts, scope := resourceOptions(resource, code.Scope)
+	opts = append(opts, resourceOpts...)
+	opts = append(opts,
+		withScope(scope),
+		withLocales(code.ClaimsLocales),
+		withAuthentication(authTime, code.ACR),
+		withAuthorizationDetails(code.AuthorizationDetails),
+	)
+
+	accessToken, err := s.model.ProvideToken(client, userID, 2*time.Hour, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	accessToken, err := s.model.ProvideToken(userID, 2*time.Hour)
+	// Only the DPoP binding applies to the refresh token, since the
+	// client may present a different certificate when refreshing.
+	refreshOpts := []accessTokenOption{
+		withScope(code.Scope),
+		withLocales(code.ClaimsLocales),
+		withRefresh(granted),
+		withAuthentication(authTime, code.ACR),
+		withAuthorizationDetails(code.AuthorizationDetails),
+	}
+	if jkt, ok := openid.GetDPoPContextKey(ctx); ok {
+		refreshOpts = append(refreshOpts, withJWKThumbprint(jkt))
+	}
+	refreshToken, err := s.model.ProvideToken(client, userID, 24*7*time.Hour, refreshOpts...)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.model.ProvideToken(userID, 24*7*time.Hour)
+	// The claims request was validated in the authentication request.
+	var requested []string
+	if cr, err := openid.ParseClaimsRequest(code.Claims); err == nil {
+		requested = cr.IDTokenNames()
+	}
+	claims, err := s.model.ProvideClaims(ctx, client.ClientID, userID, code.Scope, requested)
 	if err != nil {
 		return nil, err
 	}
 
-	idToken, err := s.model.ProvideIDToken(userID)
+	idToken, err := s.model.ProvideIDToken(client, userID,
+		withClaimsLocales(code.ClaimsLocales),
+		withSessionID(code.SessionID),
+		withProvidedClaims(claims),
+	)
 	if err != nil {
 		return nil, err
 	}
 
 	res := openid.AccessTokenResponse{
 		AccessToken:  accessToken,
-		TokenType:    "Bearer",
+		TokenType:    tokenType,
+		ExpiresIn:    int64((2 * time.Hour).Seconds()),
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+		Scope:        scope,
+
+		AuthorizationDetails: code.AuthorizationDetails,
+	}
+	return &res, nil
+}
+
+// refresh issues a new access token with the refresh token. The access token
+// can be down-scoped to part of the granted scope, and issued for any of the
+// granted resources.
+func (s *serviceImpl) refresh(ctx context.Context, client *openid.Client, req *openid.AccessTokenRequest) (*openid.AccessTokenResponse, error) {
+	claims, userID, err := s.model.ParseRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ClientID != client.ClientID {
+		return nil, openid.ErrInvalidGrant.WithDescription("refresh token was not issued to the client")
+	}
+	if err := verifyConfirmation(ctx, claims.Confirmation); err != nil {
+		return nil, openid.ErrInvalidGrant.WithDescription(err.Error())
+	}
+
+	scope := claims.Scope
+	if req.Scope != "" {
+		if exceeded := difference(strings.Fields(req.Scope), strings.Fields(claims.Scope)); len(exceeded) > 0 {
+			return nil, openid.ErrInvalidScope.WithDescription(fmt.Sprintf("scope %q was not granted", strings.Join(exceeded, " ")))
+		}
+		scope = req.Scope
+	}
+	resource, err := s.model.ResolveResource(claims.Resource, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, tokenType, err := tokenBinding(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	resourceOpts, scope := resourceOptions(resource, scope)
+	opts = append(opts, resourceOpts...)
+	opts = append(opts,
+		withScope(scope),
+		withLocales(claims.ClaimsLocales),
+		withAuthentication(claims.AuthTime, claims.ACR),
+		withAuthorizationDetails(claims.AuthorizationDetails),
+	)
+
+	accessToken, err := s.model.ProvideToken(client, userID, 2*time.Hour, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &openid.AccessTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   tokenType,
+		ExpiresIn:   int64((2 * time.Hour).Seconds()),
+		Scope:       scope,
+
+		AuthorizationDetails: claims.AuthorizationDetails,
+	}, nil
+}
+
+// exchange issues an access token for the subject of the subject token, which
+// the client uses to call the resource on behalf of the subject. The access
+// token does not outlive the subject token.
+func (s *serviceImpl) exchange(ctx context.Context, client *openid.Client, req *openid.AccessTokenRequest) (*openid.AccessTokenResponse, error) {
+	ex, err := s.model.ValidateTokenExchange(client, req)
+	if err != nil {
+		return nil, err
+	}
+	duration := 2 * time.Hour
+	if remaining := time.Until(ex.ExpiresAt); remaining < duration {
+		duration = remaining
+	}
+
+	opts, tokenType, err := tokenBinding(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	resourceOpts, scope := resourceOptions(ex.Resource, ex.Scope)
+	opts = append(opts, resourceOpts...)
+	opts = append(opts, withScope(scope), withActor(ex.Actor))
+
+	accessToken, err := s.model.ProvideToken(client, ex.UserID, duration, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &openid.AccessTokenResponse{
+		AccessToken:     accessToken,
+		IssuedTokenType: openid.TokenTypeAccessToken,
+		TokenType:       tokenType,
+		ExpiresIn:       int64(duration.Seconds()),
+		Scope:           scope,
+	}, nil
+}
+
+// jwtBearer issues an access token for the local user identified by the
+// assertion of a trusted issuer.
+func (s *serviceImpl) jwtBearer(ctx context.Context, client *openid.Client, req *openid.AccessTokenRequest) (*openid.AccessTokenResponse, error) {
+	grant, err := s.model.ValidateJWTBearer(client, req)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := s.model.ResolveResource(nil, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, tokenType, err := tokenBinding(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	resourceOpts, scope := resourceOptions(resource, grant.Scope)
+	opts = append(opts, resourceOpts...)
+	opts = append(opts, withScope(scope))
+
+	accessToken, err := s.model.ProvideToken(client, grant.UserID, 2*time.Hour, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &openid.AccessTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   tokenType,
+		ExpiresIn:   int64((2 * time.Hour).Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// ciba issues the tokens of the backchannel authentication request, once the
+// end-user has approved it on their authentication device.
+func (s *serviceImpl) ciba(ctx context.Context, client *openid.Client, req *openid.AccessTokenRequest) (*openid.AccessTokenResponse, error) {
+	auth, err := s.model.PollBackchannelAuthentication(client, req.AuthReqID)
+	if err != nil {
+		return nil, err
+	}
+	return s.backchannelTokens(ctx, client, auth, nil)
+}
+
+// BackchannelAuthenticate authenticates the client and stores the backchannel
+// authentication request for the end-user identified by the hint. The caller
+// asks the end-user to approve it on their authentication device.
+func (s *serviceImpl) BackchannelAuthenticate(ctx context.Context, req *openid.BackchannelAuthenticationRequest, creds openid.ClientCredentials) (*openid.BackchannelAuthentication, error) {
+	if req == nil {
+		return nil, openid.ErrInvalidRequest
+	}
+	client, err := s.model.AuthenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	return s.model.ValidateBackchannelAuthentication(ctx, client, req)
+}
+
+// BackchannelApproval returns the backchannel authentication request that
+// the end-user in the context is asked to approve.
+func (s *serviceImpl) BackchannelApproval(ctx context.Context, approvalID string) (*openid.BackchannelAuthentication, error) {
+	return s.model.GetBackchannelApproval(ctx, approvalID)
+}
+
+// CompleteBackchannelAuthentication records the decision of the end-user.
+// The notification returned for the ping and push modes has to be sent to
+// the client notification endpoint of the request, and is nil for the poll
+// mode. Pushed notifications carry the tokens.
+func (s *serviceImpl) CompleteBackchannelAuthentication(ctx context.Context, approvalID string, approved bool) (*openid.BackchannelAuthentication, *openid.BackchannelNotification, error) {
+	auth, client, err := s.model.ApproveBackchannelAuthentication(ctx, approvalID, approved)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg := &openid.BackchannelNotification{AuthReqID: auth.ID}
+	switch auth.DeliveryMode {
+	case openid.CIBAPoll:
+		return auth, nil, nil
+	case openid.CIBAPing:
+		return auth, msg, nil
+	}
+	if !approved {
+		msg.Error = openid.ErrAccessDenied.Code
+		return auth, msg, nil
+	}
+	// The tokens belong to the client, not to the end-user that approved
+	// the request, so the context of the approval is not used. The id token
+	// tells the client which request the pushed tokens belong to.
+	res, err := s.backchannelTokens(context.Background(), client, auth, map[string]interface{}{
+		"urn:openid:params:jwt:claim:auth_req_id": auth.ID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	msg.AccessTokenResponse = res
+	return auth, msg, nil
+}
+
+// backchannelTokens issues the tokens of the approved backchannel
+// authentication request. The end-user authenticated when approving it.
+// Polled tokens are bound to the token request, while pushed tokens are bound
+// to the backchannel authentication request.
+func (s *serviceImpl) backchannelTokens(ctx context.Context, client *openid.Client, auth *openid.BackchannelAuthentication, extra map[string]interface{}) (*openid.AccessTokenResponse, error) {
+	var (
+		opts      []accessTokenOption
+		tokenType = tokenType(auth.Confirmation)
+		err       error
+	)
+	if auth.DeliveryMode == openid.CIBAPush {
+		if auth.Confirmation != nil {
+			opts = append(opts, withConfirmation(auth.Confirmation))
+		}
+	} else {
+		opts, tokenType, err = tokenBinding(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+	authTime := auth.ApprovedAt.Unix()
+	opts = append(opts, withScope(auth.Scope), withAuthentication(authTime, defaultACR))
+
+	accessToken, err := s.model.ProvideToken(client, auth.UserID, 2*time.Hour, opts...)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := s.model.ProvideToken(client, auth.UserID, 24*7*time.Hour,
+		withScope(auth.Scope),
+		withRefresh(nil),
+		withAuthentication(authTime, defaultACR),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.model.ProvideClaims(ctx, client.ClientID, auth.UserID, auth.Scope, nil)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := s.model.ProvideIDToken(client, auth.UserID,
+		withProvidedClaims(claims),
+		withProvidedClaims(extra),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &openid.AccessTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    tokenType,
 		ExpiresIn:    int64((2 * time.Hour).Seconds()),
 		RefreshToken: refreshToken,
 		IDToken:      idToken,
+		Scope:        auth.Scope,
+	}, nil
+}
+
+// resourceOptions restricts the access token to the resource, and narrows the
+// scope to the scopes that the resource accepts.
+func resourceOptions(resource *openid.Resource, scope string) ([]accessTokenOption, string) {
+	if resource == nil {
+		return nil, scope
+	}
+	opts := []accessTokenOption{withAudience(resource.URI)}
+	if resource.ReferenceTokens {
+		opts = append(opts, withReference())
+	}
+	return opts, resource.Scope(scope)
+}
+
+// EndSession validates the logout request of the client. The end-user has to
+// confirm the logout if the request does not identify the user of the current
+// session.
+func (s *serviceImpl) EndSession(ctx context.Context, req *openid.EndSessionRequest) (*openid.EndSessionResponse, error) {
+	if req == nil {
+		return nil, openid.ErrInvalidRequest
+	}
+	client, confirmed, err := s.model.ValidateEndSession(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	res := openid.EndSessionResponse{
+		Confirm: !confirmed,
+	}
+	if client != nil {
+		res.ClientID = client.ClientID
+	}
+	if req.PostLogoutRedirectURI != "" {
+		u, err := url.Parse(req.PostLogoutRedirectURI)
+		if err != nil {
+			return nil, openid.ErrInvalidRequest.WithDescription(err.Error())
+		}
+		if req.State != "" {
+			q := u.Query()
+			q.Set("state", req.State)
+			u.RawQuery = q.Encode()
+		}
+		res.RedirectURI = u.String()
 	}
 	return &res, nil
 }
+
+// FrontChannelLogout returns the frontchannel_logout_uri of each client that
+// was issued tokens in the session that ended. Clients without a registered
+// uri are skipped.
+func (s *serviceImpl) FrontChannelLogout(ctx context.Context, sid string, clientIDs []string) []string {
+	var uris []string
+	for _, id := range clientIDs {
+		if uri, ok := s.model.FrontChannelLogoutURI(id, sid); ok {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// BackChannelLogout returns the logout token for each client that was issued
+// tokens in the user's session that ended. A client that cannot be notified,
+// e.g. because it was deleted, does not prevent the others from being
+// notified.
+func (s *serviceImpl) BackChannelLogout(ctx context.Context, userID, sid string, clientIDs []string) []openid.BackChannelLogoutRequest {
+	var reqs []openid.BackChannelLogoutRequest
+	for _, id := range clientIDs {
+		uri, token, ok, err := s.model.ProvideLogoutToken(id, userID, sid)
+		if err != nil {
+			log.Printf("backchannel logout: client %s: %v\n", id, err)
+			continue
+		}
+		if ok {
+			reqs = append(reqs, openid.BackChannelLogoutRequest{
+				ClientID:    id,
+				URI:         uri,
+				LogoutToken: token,
+			})
+		}
+	}
+	return reqs
+}
+
+// UserInfo returns the claims of the user the access token was issued for.
+// The subject is the same subject identifier that the client received in the
+// id token.
+func (s *serviceImpl) UserInfo(ctx context.Context, accessToken string) (*openid.UserInfo, error) {
+	claims, client, user, err := s.model.ParseToken(accessToken)
+	if err != nil {
+		return nil, openid.ErrInvalidToken.WithDescription(err.Error())
+	}
+	if claims.Refresh {
+		return nil, openid.ErrInvalidToken.WithDescription("refresh token cannot be used as an access token")
+	}
+	// Tokens restricted to a protected api cannot be used at the userinfo
+	// endpoint, which is only the audience of the tokens of the provider.
+	if claims.Audience != claims.Issuer {
+		return nil, openid.ErrInvalidToken.WithDescription("access token was not issued for the userinfo endpoint")
+	}
+	if err := verifyConfirmation(ctx, claims.Confirmation); err != nil {
+		return nil, openid.ErrInvalidToken.WithDescription(err.Error())
+	}
+	info := openid.NewUserInfo(claims.Subject, user)
+	info.Claims, err = s.model.ProvideClaims(ctx, client.ClientID, user.ID, claims.Scope, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The localized claims are chosen like in the id token.
+	if locales := strings.Fields(claims.ClaimsLocales); len(locales) > 0 {
+		if info.Claims == nil {
+			info.Claims = make(map[string]interface{})
+		}
+		for k, v := range user.ClaimsForLocales(locales) {
+			info.Claims[k] = v
+		}
+	}
+	return info, nil
+}
+
+// Introspect returns the state of the token. The requesting client must
+// authenticate with its registered method, and inactive tokens reveal no other
+// information. Only the client the token was issued to and the audience of the
+// token can introspect it.
+func (s *serviceImpl) Introspect(ctx context.Context, token string, creds openid.ClientCredentials) (*openid.IntrospectionResponse, error) {
+	requester, err := s.model.AuthenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	claims, client, user, err := s.model.ParseToken(token)
+	if err != nil || claims.Refresh {
+		return &openid.IntrospectionResponse{Active: false}, nil
+	}
+	if requester.ClientID != client.ClientID && requester.ClientID != claims.Audience {
+		return &openid.IntrospectionResponse{Active: false}, nil
+	}
+	res := openid.IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  client.ClientID,
+		Subject:   claims.Subject,
+		TokenType: tokenType(claims.Confirmation),
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		Cnf:       claims.Confirmation,
+		Actor:     claims.Actor,
+
+		AuthorizationDetails: claims.AuthorizationDetails,
+	}
+	res.Claims, err = s.model.ProvideClaims(ctx, client.ClientID, user.ID, claims.Scope, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Revoke revokes the access or refresh token, as described in RFC 7009. The
+// requesting client must authenticate with its registered method, and can only
+// revoke the tokens issued to itself. Invalid tokens are not an error, since
+// the client cannot use them anyway.
+func (s *serviceImpl) Revoke(ctx context.Context, token string, creds openid.ClientCredentials) error {
+	client, err := s.model.AuthenticateClient(ctx, creds)
+	if err != nil {
+		return err
+	}
+	return s.model.RevokeToken(client, token)
+}
+
+// tokenType returns the type of the access token with the confirmation.
+// Certificate-bound tokens remain bearer tokens.
+func tokenType(cnf *openid.Confirmation) string {
+	if cnf != nil && cnf.JKT != "" {
+		return "DPoP"
+	}
+	return openid.Bearer
+}
+
+// tokenBinding returns the options that bind the access token to the client
+// certificate or the DPoP key of the request, and the resulting token type.
+// Clients that registered for bound access tokens must present the
+// certificate or the DPoP proof.
+func tokenBinding(ctx context.Context, client *openid.Client) ([]accessTokenOption, string, error) {
+	var opts []accessTokenOption
+	cert, hasCert := openid.GetCertContextKey(ctx)
+	if client.TLSClientCertBoundTokens {
+		if !hasCert {
+			return nil, "", openid.ErrInvalidRequest.WithDescription("client certificate is required for certificate-bound access tokens")
+		}
+		opts = append(opts, withCertificate(cert))
+	}
+	jkt, hasDPoP := openid.GetDPoPContextKey(ctx)
+	if client.DPoPBoundAccessTokens && !hasDPoP {
+		return nil, "", openid.ErrInvalidDPoPProof.WithDescription("dpop proof is required")
+	}
+	if hasDPoP {
+		return append(opts, withJWKThumbprint(jkt)), "DPoP", nil
+	}
+	return opts, openid.Bearer, nil
+}
+
+// requestConfirmation returns the client certificate and the DPoP key of the
+// request that the tokens of the client are bound to, or nil if there are
+// none.
+func requestConfirmation(ctx context.Context, client *openid.Client) (*openid.Confirmation, error) {
+	opts, _, err := tokenBinding(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	var claims accessTokenClaims
+	for _, opt := range opts {
+		opt(&claims)
+	}
+	return claims.Confirmation, nil
+}
+
+// errDPoPMismatch is returned when the DPoP proof of the request was not made
+// with the key that the token is bound to.
+var errDPoPMismatch = errors.New("dpop proof does not match the token")
+
+// verifyConfirmation checks that the certificate and the DPoP key of the
+// request match the ones the token is bound to.
+func verifyConfirmation(ctx context.Context, cnf *openid.Confirmation) error {
+	if cnf == nil {
+		return nil
+	}
+	if cnf.X5tS256 != "" {
+		cert, ok := openid.GetCertContextKey(ctx)
+		if !ok {
+			return mtls.ErrMissingCertificate
+		}
+		if mtls.Thumbprint(cert) != cnf.X5tS256 {
+			return mtls.ErrCertificateMismatch
+		}
+	}
+	if cnf.JKT != "" {
+		jkt, ok := openid.GetDPoPContextKey(ctx)
+		if !ok || jkt != cnf.JKT {
+			return errDPoPMismatch
+		}
+	}
+	return nil
+}
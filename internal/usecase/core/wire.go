@@ -4,7 +4,6 @@ package core
 
 import (
 	"github.com/alextanhongpin/go-openid/internal/database"
-	"github.com/alextanhongpin/go-openid/model"
 	"github.com/alextanhongpin/go-openid/repository"
 
 	"github.com/google/go-cloud/wire"
@@ -18,7 +17,7 @@ var serviceSet = wire.NewSet(
 	provideUserRepository,
 	wire.Bind(new(repository.User), new(database.UserKV)),
 	provideModel,
-	wire.Bind(new(model.Core), new(modelImpl)),
+	wire.Bind(new(Model), new(modelImpl)),
 	provideService,
 )
 
@@ -40,9 +39,14 @@ func provideUserRepository() *database.UserKV {
 }
 
 func provideModel(code repository.Code, client repository.Client, user repository.User) *modelImpl {
-	return &modelImpl{code, client, user}
+	m := NewModel(
+		ModelCodeRepository(code),
+		ModelClientRepository(client),
+		ModelUserRepository(user),
+	)
+	return &m
 }
 
-func provideService(model model.Core) *serviceImpl {
+func provideService(model Model) *serviceImpl {
 	return &serviceImpl{model}
 }
@@ -7,7 +7,6 @@ package core
 
 import (
 	database "github.com/alextanhongpin/go-openid/internal/database"
-	model "github.com/alextanhongpin/go-openid/model"
 	repository "github.com/alextanhongpin/go-openid/repository"
 	wire "github.com/google/go-cloud/wire"
 )
@@ -26,7 +25,7 @@ func New() *serviceImpl {
 // wire.go:
 
 var serviceSet = wire.NewSet(
-	provideClientRepository, wire.Bind(new(repository.Client), new(database.ClientKV)), provideCodeRepository, wire.Bind(new(repository.Code), new(database.CodeKV)), provideUserRepository, wire.Bind(new(repository.User), new(database.UserKV)), provideModel, wire.Bind(new(model.Core), new(modelImpl)), provideService,
+	provideClientRepository, wire.Bind(new(repository.Client), new(database.ClientKV)), provideCodeRepository, wire.Bind(new(repository.Code), new(database.CodeKV)), provideUserRepository, wire.Bind(new(repository.User), new(database.UserKV)), provideModel, wire.Bind(new(Model), new(modelImpl)), provideService,
 )
 
 func provideClientRepository() *database.ClientKV {
@@ -42,9 +41,14 @@ func provideUserRepository() *database.UserKV {
 }
 
 func provideModel(code repository.Code, client repository.Client, user repository.User) *modelImpl {
-	return &modelImpl{code, client, user}
+	m := NewModel(
+		ModelCodeRepository(code),
+		ModelClientRepository(client),
+		ModelUserRepository(user),
+	)
+	return &m
 }
 
-func provideService(model2 model.Core) *serviceImpl {
+func provideService(model2 Model) *serviceImpl {
 	return &serviceImpl{model2}
 }
@@ -0,0 +1,80 @@
+package openid
+
+import "strings"
+
+// LoginHint represents the login_hint parameter, which hints the
+// authorization server about the login identifier the end-user might use. It
+// can be an email address, a phone number or a subject identifier, e.g.
+// "john.doe@mail.com", "mailto:john.doe@mail.com", "tel:+60123456789" or
+// "sub:bd9n1ls3n9g26rp1jv4g".
+type LoginHint string
+
+const (
+	mailtoPrefix  = "mailto:"
+	telPrefix     = "tel:"
+	subjectPrefix = "sub:"
+)
+
+// Email returns the email address if the hint is an email.
+func (h LoginHint) Email() (string, bool) {
+	s := strings.TrimPrefix(string(h), mailtoPrefix)
+	if !strings.Contains(s, "@") {
+		return "", false
+	}
+	return s, true
+}
+
+// PhoneNumber returns the phone number if the hint is a phone number.
+func (h LoginHint) PhoneNumber() (string, bool) {
+	s := string(h)
+	if strings.HasPrefix(s, telPrefix) {
+		return strings.TrimPrefix(s, telPrefix), true
+	}
+	if len(s) > 1 && s[0] == '+' && strings.Trim(s[1:], "0123456789 -") == "" {
+		return s, true
+	}
+	return "", false
+}
+
+// Subject returns the subject identifier if the hint is neither an email nor
+// a phone number.
+func (h LoginHint) Subject() (string, bool) {
+	s := string(h)
+	if strings.HasPrefix(s, subjectPrefix) {
+		return strings.TrimPrefix(s, subjectPrefix), true
+	}
+	if _, ok := h.Email(); ok {
+		return "", false
+	}
+	if _, ok := h.PhoneNumber(); ok {
+		return "", false
+	}
+	return s, s != ""
+}
+
+// Matches returns true if the hint refers to the given user. An empty hint
+// matches any user.
+func (h LoginHint) Matches(u *User) bool {
+	if h == "" {
+		return true
+	}
+	if email, ok := h.Email(); ok {
+		return strings.EqualFold(email, u.Email.Email)
+	}
+	if phone, ok := h.PhoneNumber(); ok {
+		return normalizePhoneNumber(phone) == normalizePhoneNumber(u.Phone.PhoneNumber)
+	}
+	sub, _ := h.Subject()
+	return sub == u.ID
+}
+
+// -- helpers
+
+func normalizePhoneNumber(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,39 @@
+package openid_test
+
+import (
+	"testing"
+
+	openid "github.com/alextanhongpin/go-openid"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginHint(t *testing.T) {
+	assert := assert.New(t)
+
+	user := &openid.User{
+		ID:    "bd9n1ls3n9g26rp1jv4g",
+		Email: openid.Email{Email: "john.doe@mail.com"},
+		Phone: openid.Phone{PhoneNumber: "+60 12-345 6789"},
+	}
+
+	tests := []struct {
+		hint    openid.LoginHint
+		matches bool
+	}{
+		{"", true},
+		{"john.doe@mail.com", true},
+		{"mailto:John.Doe@mail.com", true},
+		{"jane.doe@mail.com", false},
+		{"tel:+60123456789", true},
+		{"+60123456789", true},
+		{"+60111111111", false},
+		{"sub:bd9n1ls3n9g26rp1jv4g", true},
+		{"bd9n1ls3n9g26rp1jv4g", true},
+		{"unknown", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(tt.matches, tt.hint.Matches(user), "should match the login_hint %q", tt.hint)
+	}
+}
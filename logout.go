@@ -0,0 +1,73 @@
+package openid
+
+import (
+	"crypto/rsa"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// BackChannelLogoutEvent is the member of the events claim that identifies
+// a logout token.
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// EndSessionRequest represents the RP-initiated logout request.
+type EndSessionRequest struct {
+	ClientID              string `json:"client_id,omitempty"`
+	IDTokenHint           string `json:"id_token_hint,omitempty"`
+	LogoutHint            string `json:"logout_hint,omitempty"`
+	PostLogoutRedirectURI string `json:"post_logout_redirect_uri,omitempty"`
+	State                 string `json:"state,omitempty"`
+	UILocales             string `json:"ui_locales,omitempty"`
+}
+
+// EndSessionResponse represents the result of the logout request.
+type EndSessionResponse struct {
+	// ClientID is the client that requested the logout, if it could be
+	// identified.
+	ClientID string
+
+	// RedirectURI is the post_logout_redirect_uri, including the state.
+	// It is empty if the client did not request a redirect.
+	RedirectURI string
+
+	// Confirm is true if the end-user has to confirm the logout, because
+	// the request does not identify the user of the current session.
+	Confirm bool
+}
+
+// BackChannelLogoutRequest is the logout token that is sent to the
+// backchannel_logout_uri of a client. Clients may share the same uri, so each
+// client has its own request.
+type BackChannelLogoutRequest struct {
+	ClientID    string
+	URI         string
+	LogoutToken string
+}
+
+// LogoutToken is the token that is sent to the back-channel logout uri of the
+// clients when the end-user's session ends. Unlike the id token, it never
+// contains a nonce.
+type LogoutToken struct {
+	jwt.StandardClaims
+	SessionID string                            `json:"sid,omitempty"`
+	Events    map[string]map[string]interface{} `json:"events"`
+}
+
+// NewLogoutToken returns a new logout token with the logout event.
+func NewLogoutToken() *LogoutToken {
+	return &LogoutToken{
+		Events: map[string]map[string]interface{}{
+			BackChannelLogoutEvent: {},
+		},
+	}
+}
+
+// SignRS256 signs the logout token with the explicit logout+jwt type, so
+// that it cannot be confused with other tokens. The kid identifies the
+// published key that the clients verify the token with.
+func (l *LogoutToken) SignRS256(kid string, key *rsa.PrivateKey) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, l)
+	token.Header["typ"] = "logout+jwt"
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
@@ -8,6 +8,7 @@ import (
 const (
 	basic  = "basic"
 	bearer = "bearer"
+	dpop   = "dpop"
 
 	sep = ":"
 )
@@ -35,3 +36,8 @@ func Basic(header string) (string, error) {
 func Bearer(header string) (string, error) {
 	return valid(header, bearer)
 }
+
+// DPoP returns the DPoP-bound access token from the header.
+func DPoP(header string) (string, error) {
+	return valid(header, dpop)
+}
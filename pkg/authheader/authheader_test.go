@@ -54,6 +54,27 @@ func TestBearer(t *testing.T) {
 	}
 }
 
+func TestDPoP(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		header string
+		err    error
+		token  string
+	}{
+		{"DPoP abc", nil, "abc"},
+		{"dpop abc", nil, "abc"},
+		{"DPoP ", authheader.ErrInvalidAuthHeader, ""},
+		{"Bearer abc", authheader.ErrInvalidAuthHeader, ""},
+		{"", authheader.ErrInvalidAuthHeader, ""},
+	}
+
+	for _, tt := range tests {
+		token, err := authheader.DPoP(tt.header)
+		assert.Equal(tt.err, err, "should match the error")
+		assert.Equal(tt.token, token, "should match the token")
+	}
+}
+
 func TestPanic(t *testing.T) {
 	f := func(s string) bool {
 		token, err := authheader.Bearer(s)
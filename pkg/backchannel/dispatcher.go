@@ -0,0 +1,225 @@
+// Package backchannel delivers the logout tokens of OpenID Connect
+// Back-Channel Logout to the clients.
+package backchannel
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// Dispatcher delivers the messages in the outbox to the clients. Failed
+// deliveries are retried with exponential backoff until they succeed or the
+// maximum number of attempts is reached.
+type Dispatcher struct {
+	client      *http.Client
+	outbox      Outbox
+	concurrency int
+	maxAttempts int
+	backoff     time.Duration
+	interval    time.Duration
+
+	mu     sync.Mutex // Serializes the flushes.
+	notify chan struct{}
+	quit   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewDispatcher returns a new dispatcher. It does not deliver any messages
+// until it is started.
+func NewDispatcher(opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		outbox:      NewMemoryOutbox(),
+		concurrency: 4,
+		maxAttempts: 5,
+		backoff:     time.Second,
+		interval:    time.Second,
+		notify:      make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(d)
+	}
+	return d
+}
+
+// Option configures the dispatcher.
+type Option func(d *Dispatcher)
+
+// HTTPClient sets the client used to deliver the messages.
+func HTTPClient(c *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = c
+	}
+}
+
+// WithOutbox sets the outbox of the messages.
+func WithOutbox(o Outbox) Option {
+	return func(d *Dispatcher) {
+		d.outbox = o
+	}
+}
+
+// Concurrency sets the maximum number of messages delivered at the same
+// time.
+func Concurrency(n int) Option {
+	return func(d *Dispatcher) {
+		d.concurrency = n
+	}
+}
+
+// MaxAttempts sets the number of attempts after which a message is dropped.
+func MaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = n
+	}
+}
+
+// Backoff sets the delay before the first retry. The delay doubles with
+// each attempt.
+func Backoff(t time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.backoff = t
+	}
+}
+
+// Enqueue stores the logout token in the outbox and schedules its delivery
+// to the uri.
+func (d *Dispatcher) Enqueue(uri, logoutToken string) error {
+	msg := Message{
+		ID:          xid.New().String(),
+		URI:         uri,
+		LogoutToken: logoutToken,
+		NextAttempt: time.Now().UTC(),
+	}
+	if err := d.outbox.Put(msg); err != nil {
+		return err
+	}
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start runs a goroutine that delivers the messages, including those left in
+// the outbox by a previous run.
+func (d *Dispatcher) Start() {
+	go func() {
+		defer close(d.done)
+		t := time.NewTicker(d.interval)
+		defer t.Stop()
+		for {
+			d.Flush()
+			select {
+			case <-d.quit:
+				return
+			case <-t.C:
+			case <-d.notify:
+			}
+		}
+	}()
+}
+
+// Stop terminates the running goroutine, and waits for the deliveries in
+// progress to complete. Undelivered messages stay in the outbox.
+func (d *Dispatcher) Stop() {
+	d.once.Do(func() {
+		close(d.quit)
+		<-d.done
+	})
+}
+
+// Flush delivers the messages in the outbox that are due, and returns when
+// all of them are attempted.
+func (d *Dispatcher) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	msgs, err := d.outbox.List()
+	if err != nil {
+		log.Printf("backchannel: list outbox: %v\n", err)
+		return
+	}
+
+	var (
+		now = time.Now().UTC()
+		sem = make(chan struct{}, d.concurrency)
+		wg  sync.WaitGroup
+	)
+	for _, msg := range msgs {
+		if msg.NextAttempt.After(now) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(msg Message) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d.deliver(msg)
+		}(msg)
+	}
+	wg.Wait()
+}
+
+// deliver sends the message, and either removes it from the outbox or
+// schedules the next attempt.
+func (d *Dispatcher) deliver(msg Message) {
+	retry, err := d.send(msg)
+	if err == nil {
+		d.delete(msg)
+		return
+	}
+	msg.Attempts++
+	if !retry || msg.Attempts >= d.maxAttempts {
+		log.Printf("backchannel: drop message %s to %s after %d attempts: %v\n", msg.ID, msg.URI, msg.Attempts, err)
+		d.delete(msg)
+		return
+	}
+	msg.NextAttempt = time.Now().UTC().Add(d.backoff << uint(msg.Attempts-1))
+	if err := d.outbox.Put(msg); err != nil {
+		log.Printf("backchannel: put message %s: %v\n", msg.ID, err)
+	}
+}
+
+func (d *Dispatcher) delete(msg Message) {
+	if err := d.outbox.Delete(msg.ID); err != nil {
+		log.Printf("backchannel: delete message %s: %v\n", msg.ID, err)
+	}
+}
+
+// send posts the logout token to the client. The returned bool is false if
+// the client rejected the logout token, in which case it is not retried.
+func (d *Dispatcher) send(msg Message) (bool, error) {
+	form := url.Values{}
+	form.Set("logout_token", msg.LogoutToken)
+	req, err := http.NewRequest("POST", msg.URI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusTooManyRequests, res.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %d", res.StatusCode)
+	default:
+		return false, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+}
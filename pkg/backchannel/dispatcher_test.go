@@ -0,0 +1,157 @@
+package backchannel_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-openid/pkg/backchannel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("deliver logout token", func(t *testing.T) {
+		var token atomic.Value
+		rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token.Store(r.PostFormValue("logout_token"))
+		}))
+		defer rp.Close()
+
+		outbox := backchannel.NewMemoryOutbox()
+		d := backchannel.NewDispatcher(backchannel.WithOutbox(outbox))
+		assert.Nil(d.Enqueue(rp.URL, "xyz"))
+		d.Flush()
+
+		assert.Equal("xyz", token.Load())
+		msgs, _ := outbox.List()
+		assert.Equal(0, len(msgs), "should remove delivered messages")
+	})
+
+	t.Run("retry with backoff", func(t *testing.T) {
+		var n int32
+		rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&n, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer rp.Close()
+
+		outbox := backchannel.NewMemoryOutbox()
+		d := backchannel.NewDispatcher(
+			backchannel.WithOutbox(outbox),
+			backchannel.Backoff(time.Millisecond),
+		)
+		assert.Nil(d.Enqueue(rp.URL, "xyz"))
+
+		d.Flush()
+		msgs, _ := outbox.List()
+		assert.Equal(1, len(msgs))
+		assert.Equal(1, msgs[0].Attempts)
+
+		for i := 0; i < 10 && atomic.LoadInt32(&n) < 3; i++ {
+			time.Sleep(5 * time.Millisecond)
+			d.Flush()
+		}
+		assert.Equal(int32(3), atomic.LoadInt32(&n))
+		msgs, _ = outbox.List()
+		assert.Equal(0, len(msgs))
+	})
+
+	t.Run("drop rejected logout token", func(t *testing.T) {
+		var n int32
+		rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&n, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer rp.Close()
+
+		outbox := backchannel.NewMemoryOutbox()
+		d := backchannel.NewDispatcher(backchannel.WithOutbox(outbox))
+		assert.Nil(d.Enqueue(rp.URL, "xyz"))
+		d.Flush()
+
+		assert.Equal(int32(1), atomic.LoadInt32(&n))
+		msgs, _ := outbox.List()
+		assert.Equal(0, len(msgs), "should not retry client errors")
+	})
+
+	t.Run("bounded concurrency", func(t *testing.T) {
+		var curr, max int32
+		rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := atomic.AddInt32(&curr, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if c <= m || atomic.CompareAndSwapInt32(&max, m, c) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&curr, -1)
+		}))
+		defer rp.Close()
+
+		d := backchannel.NewDispatcher(backchannel.Concurrency(2))
+		for i := 0; i < 6; i++ {
+			assert.Nil(d.Enqueue(rp.URL, "xyz"))
+		}
+		d.Flush()
+		assert.True(atomic.LoadInt32(&max) <= 2)
+	})
+
+	t.Run("deliver messages of previous run", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "outbox")
+		assert.Nil(err)
+		defer os.RemoveAll(dir)
+
+		var n int32
+		rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&n, 1)
+		}))
+		defer rp.Close()
+
+		outbox, err := backchannel.NewFileOutbox(dir)
+		assert.Nil(err)
+		assert.Nil(backchannel.NewDispatcher(backchannel.WithOutbox(outbox)).Enqueue(rp.URL, "xyz"))
+
+		// A new dispatcher picks up the message from the directory.
+		outbox, err = backchannel.NewFileOutbox(dir)
+		assert.Nil(err)
+		d := backchannel.NewDispatcher(backchannel.WithOutbox(outbox))
+		d.Start()
+		for i := 0; i < 100 && atomic.LoadInt32(&n) == 0; i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+		d.Stop()
+
+		assert.Equal(int32(1), atomic.LoadInt32(&n))
+		msgs, err := outbox.List()
+		assert.Nil(err)
+		assert.Equal(0, len(msgs))
+	})
+
+	t.Run("skip invalid messages", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "outbox")
+		assert.Nil(err)
+		defer os.RemoveAll(dir)
+
+		outbox, err := backchannel.NewFileOutbox(dir)
+		assert.Nil(err)
+		assert.Nil(outbox.Put(backchannel.Message{ID: "1", URI: "http://client.example/logout", LogoutToken: "xyz"}))
+		assert.Nil(ioutil.WriteFile(filepath.Join(dir, "2.json"), []byte("{"), 0600))
+
+		msgs, err := outbox.List()
+		assert.Nil(err)
+		assert.Equal(1, len(msgs), "should skip the invalid message")
+		assert.Equal("1", msgs[0].ID)
+
+		_, err = os.Stat(filepath.Join(dir, "2.json.invalid"))
+		assert.Nil(err, "should move the invalid message aside")
+	})
+}
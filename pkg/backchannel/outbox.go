@@ -0,0 +1,151 @@
+package backchannel
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message represents a logout token that is waiting to be delivered to the
+// back-channel logout uri of a client.
+type Message struct {
+	ID          string    `json:"id"`
+	URI         string    `json:"uri"`
+	LogoutToken string    `json:"logout_token"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+// Outbox stores the messages until they are delivered.
+type Outbox interface {
+	Put(msg Message) error
+	Delete(id string) error
+	List() ([]Message, error)
+}
+
+type memoryOutbox struct {
+	sync.RWMutex
+	data map[string]Message
+}
+
+// NewMemoryOutbox returns an outbox that keeps the messages in memory. The
+// messages are lost when the process exits.
+func NewMemoryOutbox() *memoryOutbox {
+	return &memoryOutbox{
+		data: make(map[string]Message),
+	}
+}
+
+// Put stores the message, replacing the message with the same id.
+func (o *memoryOutbox) Put(msg Message) error {
+	o.Lock()
+	o.data[msg.ID] = msg
+	o.Unlock()
+	return nil
+}
+
+// Delete removes the message.
+func (o *memoryOutbox) Delete(id string) error {
+	o.Lock()
+	delete(o.data, id)
+	o.Unlock()
+	return nil
+}
+
+// List returns all the messages in the outbox.
+func (o *memoryOutbox) List() ([]Message, error) {
+	o.RLock()
+	defer o.RUnlock()
+	msgs := make([]Message, 0, len(o.data))
+	for _, msg := range o.data {
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+type fileOutbox struct {
+	dir string
+}
+
+// NewFileOutbox returns an outbox that stores each message as a json file in
+// the directory, so that the messages survive restarts.
+func NewFileOutbox(dir string) (*fileOutbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &fileOutbox{dir}, nil
+}
+
+func (o *fileOutbox) path(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
+
+// Put stores the message. The file is written to a temporary file first, so
+// that a crash never leaves a partially written message.
+func (o *fileOutbox) Put(msg Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(o.dir, msg.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), o.path(msg.ID))
+}
+
+// Delete removes the message. Deleting a message that does not exist is not
+// an error.
+func (o *fileOutbox) Delete(id string) error {
+	err := os.Remove(o.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List returns all the messages in the outbox. A file that cannot be read is
+// skipped, and a file that is not a message is moved aside with the .invalid
+// suffix, so that one bad file does not stop the delivery of the others.
+func (o *fileOutbox) List() ([]Message, error) {
+	files, err := ioutil.ReadDir(o.dir)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []Message
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		name := filepath.Join(o.dir, f.Name())
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Printf("backchannel: read %s: %v\n", name, err)
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal(b, &msg); err != nil {
+			log.Printf("backchannel: move aside invalid message %s: %v\n", name, err)
+			if err := os.Rename(name, name+".invalid"); err != nil {
+				log.Printf("backchannel: move aside %s: %v\n", name, err)
+			}
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
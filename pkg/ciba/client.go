@@ -0,0 +1,51 @@
+package ciba
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ClientNotifier sends the ping and push notifications to the client
+// notification endpoints.
+type ClientNotifier struct {
+	client *http.Client
+}
+
+// NewClientNotifier returns a new client notifier. The default client is
+// used when nil.
+func NewClientNotifier(client *http.Client) *ClientNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &ClientNotifier{client: client}
+}
+
+// Notify posts the message as json to the endpoint, authenticated with the
+// client notification token as the bearer token.
+func (c *ClientNotifier) Notify(ctx context.Context, endpoint, token string, msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("ciba: client notification endpoint responded with %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package ciba_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alextanhongpin/go-openid/pkg/ciba"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientNotifier(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("post the message with the bearer token", func(t *testing.T) {
+		var (
+			authorization string
+			msg           map[string]string
+		)
+		rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			json.NewDecoder(r.Body).Decode(&msg)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer rp.Close()
+
+		n := ciba.NewClientNotifier(nil)
+		err := n.Notify(context.Background(), rp.URL, "xyz", map[string]string{"auth_req_id": "abc"})
+		assert.Nil(err)
+		assert.Equal("Bearer xyz", authorization)
+		assert.Equal("abc", msg["auth_req_id"])
+	})
+
+	t.Run("fail on error status", func(t *testing.T) {
+		rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer rp.Close()
+
+		n := ciba.NewClientNotifier(nil)
+		err := n.Notify(context.Background(), rp.URL, "xyz", map[string]string{"auth_req_id": "abc"})
+		assert.NotNil(err)
+	})
+}
+
+func TestLogNotifier(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	n := ciba.NewLogNotifier(log.New(&buf, "", 0))
+	err := n.Notify(context.Background(), ciba.Notification{
+		UserID:         "john",
+		ClientID:       "client",
+		BindingMessage: "W4SCT",
+		ApprovalURI:    "http://localhost:8080/bc-approve?id=123",
+	})
+	assert.Nil(err)
+	assert.Contains(buf.String(), "W4SCT")
+	assert.Contains(buf.String(), "http://localhost:8080/bc-approve?id=123")
+}
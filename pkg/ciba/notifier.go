@@ -0,0 +1,50 @@
+// Package ciba notifies the authentication devices of the end-users and the
+// clients in OpenID Connect Client-Initiated Backchannel Authentication.
+package ciba
+
+import (
+	"context"
+	"log"
+)
+
+// Notification asks the end-user to approve the authentication request of a
+// client on their authentication device.
+type Notification struct {
+	UserID         string
+	ClientID       string
+	Scope          string
+	BindingMessage string
+
+	// ApprovalURI is the page where the end-user approves or denies the
+	// request.
+	ApprovalURI string
+}
+
+// Notifier delivers the notifications to the authentication devices, e.g.
+// through a push notification service or a text message.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier is a local stand-in for an authentication device, which logs
+// the approval uri so that the request can be approved in the browser.
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+// NewLogNotifier returns a notifier that writes to the logger, or to the
+// standard logger if nil.
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+// Notify logs the notification.
+func (l *LogNotifier) Notify(ctx context.Context, n Notification) error {
+	msg := "ciba: user %s is asked to approve client %s (scope %q, binding message %q) at %s\n"
+	if l.logger == nil {
+		log.Printf(msg, n.UserID, n.ClientID, n.Scope, n.BindingMessage, n.ApprovalURI)
+		return nil
+	}
+	l.logger.Printf(msg, n.UserID, n.ClientID, n.Scope, n.BindingMessage, n.ApprovalURI)
+	return nil
+}
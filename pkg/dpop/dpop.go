@@ -0,0 +1,259 @@
+// Package dpop implements the validation of DPoP proofs as described in
+// RFC 9449, for both the token endpoint and the resource servers.
+package dpop
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alextanhongpin/go-openid/pkg/randstr"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// Header is the name of the header that carries the proof.
+const Header = "DPoP"
+
+// NonceHeader is the name of the header that carries the server nonce.
+const NonceHeader = "DPoP-Nonce"
+
+// SigningAlgs are the asymmetric algorithms accepted for the proofs.
+var SigningAlgs = []string{"ES256", "ES384", "ES512", "RS256", "PS256"}
+
+var (
+	ErrInvalidProof = errors.New("invalid dpop proof")
+	ErrUseNonce     = errors.New("dpop nonce is required")
+)
+
+// Claims represents the claims of the DPoP proof.
+type Claims struct {
+	ID              string `json:"jti"`
+	HTTPMethod      string `json:"htm"`
+	HTTPURI         string `json:"htu"`
+	IssuedAt        int64  `json:"iat"`
+	AccessTokenHash string `json:"ath,omitempty"`
+	Nonce           string `json:"nonce,omitempty"`
+}
+
+// Proof represents a validated DPoP proof.
+type Proof struct {
+	Claims
+	// Thumbprint is the JWK SHA-256 thumbprint of the public key, which is
+	// the value of the jkt confirmation method.
+	Thumbprint string
+}
+
+// Verifier validates the DPoP proofs and keeps track of the proofs that were
+// used, so that they cannot be replayed.
+type Verifier struct {
+	sync.Mutex
+	// Maps the jti of the proofs to the time they expire from the cache.
+	jtis map[string]time.Time
+	// Maps the issued nonces to the time they expire.
+	nonces map[string]time.Time
+
+	maxAge       time.Duration
+	requireNonce bool
+}
+
+// NewVerifier returns a new verifier with the given options.
+func NewVerifier(opts ...Option) *Verifier {
+	v := &Verifier{
+		jtis:   make(map[string]time.Time),
+		nonces: make(map[string]time.Time),
+		maxAge: 5 * time.Minute,
+	}
+	for _, o := range opts {
+		o(v)
+	}
+	return v
+}
+
+// Option configures the verifier.
+type Option func(v *Verifier)
+
+// MaxAge sets how far the iat of a proof may differ from the current time.
+func MaxAge(d time.Duration) Option {
+	return func(v *Verifier) {
+		v.maxAge = d
+	}
+}
+
+// RequireNonce requires every proof to contain a nonce issued by the
+// verifier.
+func RequireNonce() Option {
+	return func(v *Verifier) {
+		v.requireNonce = true
+	}
+}
+
+// Nonce issues a new nonce that the client must include in the next proof.
+// Nonces are only issued when they are required.
+func (v *Verifier) Nonce() (string, error) {
+	if !v.requireNonce {
+		return "", nil
+	}
+	nonce, err := randstr.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	v.Lock()
+	v.nonces[nonce] = time.Now().Add(v.maxAge)
+	v.Unlock()
+	return nonce, nil
+}
+
+// Verify validates the proof of the request. The accessToken is the token the
+// proof is presented with at a resource server, and is empty at the token
+// endpoint.
+func (v *Verifier) Verify(r *http.Request, accessToken string) (*Proof, error) {
+	values := r.Header[http.CanonicalHeaderKey(Header)]
+	if len(values) != 1 {
+		return nil, ErrInvalidProof
+	}
+	proof, err := Parse(values[0])
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(proof.HTTPMethod, r.Method) {
+		return nil, errors.New("dpop proof htm does not match")
+	}
+	if !matchURI(proof.HTTPURI, requestURI(r)) {
+		return nil, errors.New("dpop proof htu does not match")
+	}
+	if accessToken != "" && proof.AccessTokenHash != AccessTokenHash(accessToken) {
+		return nil, errors.New("dpop proof ath does not match")
+	}
+	if proof.AccessTokenHash != "" && accessToken == "" {
+		return nil, errors.New("dpop proof ath is not expected")
+	}
+
+	now := time.Now()
+	iat := time.Unix(proof.IssuedAt, 0)
+	if iat.Before(now.Add(-v.maxAge)) || iat.After(now.Add(v.maxAge)) {
+		return nil, errors.New("dpop proof iat is not recent")
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	v.expire(now)
+	if v.requireNonce {
+		if _, ok := v.nonces[proof.Nonce]; !ok {
+			return nil, ErrUseNonce
+		}
+	}
+	if _, ok := v.jtis[proof.ID]; ok {
+		return nil, errors.New("dpop proof has already been used")
+	}
+	// The proof can no longer pass the iat check after this.
+	v.jtis[proof.ID] = iat.Add(v.maxAge)
+	return proof, nil
+}
+
+// expire removes the proofs and nonces that are no longer valid.
+func (v *Verifier) expire(now time.Time) {
+	for k, exp := range v.jtis {
+		if now.After(exp) {
+			delete(v.jtis, k)
+		}
+	}
+	for k, exp := range v.nonces {
+		if now.After(exp) {
+			delete(v.nonces, k)
+		}
+	}
+}
+
+// Parse verifies the signature of the proof with the public key in its
+// header, without validating the claims against a request.
+func Parse(proof string) (*Proof, error) {
+	jws, err := jose.ParseSigned(proof)
+	if err != nil || len(jws.Signatures) != 1 {
+		return nil, ErrInvalidProof
+	}
+	header := jws.Signatures[0].Header
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != "dpop+jwt" {
+		return nil, errors.New("dpop proof typ must be dpop+jwt")
+	}
+	if !isSupportedAlg(header.Algorithm) {
+		return nil, errors.New("dpop proof alg is not supported")
+	}
+	jwk := header.JSONWebKey
+	if jwk == nil || !jwk.IsPublic() || !jwk.Valid() {
+		return nil, errors.New("dpop proof jwk must be a public key")
+	}
+	payload, err := jws.Verify(jwk)
+	if err != nil {
+		return nil, ErrInvalidProof
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidProof
+	}
+	if claims.ID == "" || claims.HTTPMethod == "" || claims.HTTPURI == "" || claims.IssuedAt == 0 {
+		return nil, errors.New("dpop proof jti, htm, htu and iat are required")
+	}
+	thumbprint, err := Thumbprint(jwk)
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{Claims: claims, Thumbprint: thumbprint}, nil
+}
+
+// Thumbprint returns the base64url encoded JWK SHA-256 thumbprint of the key.
+func Thumbprint(jwk *jose.JSONWebKey) (string, error) {
+	b, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AccessTokenHash returns the value of the ath claim for the access token.
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// -- helpers
+
+func isSupportedAlg(alg string) bool {
+	for _, a := range SigningAlgs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// requestURI returns the uri of the request without the query and fragment.
+func requestURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// matchURI compares the htu with the request uri, ignoring the query and
+// fragment.
+func matchURI(htu, uri string) bool {
+	a, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Host, b.Host) &&
+		a.Path == b.Path
+}
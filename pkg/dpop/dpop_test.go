@@ -0,0 +1,122 @@
+package dpop_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-openid/pkg/dpop"
+
+	"github.com/stretchr/testify/assert"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func newProof(t *testing.T, key *ecdsa.PrivateKey, claims dpop.Claims) string {
+	opts := (&jose.SignerOptions{EmbedJWK: true}).WithType("dpop+jwt")
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(err)
+	thumbprint, err := dpop.Thumbprint(&jose.JSONWebKey{Key: key.Public()})
+	assert.Nil(err)
+
+	claims := dpop.Claims{
+		ID:         "1",
+		HTTPMethod: "POST",
+		HTTPURI:    "http://server.example.com/token",
+		IssuedAt:   time.Now().Unix(),
+	}
+
+	t.Run("verify proof at the token endpoint", func(t *testing.T) {
+		v := dpop.NewVerifier()
+		r := httptest.NewRequest("POST", "http://server.example.com/token", nil)
+		r.Header.Set(dpop.Header, newProof(t, key, claims))
+
+		proof, err := v.Verify(r, "")
+		assert.Nil(err)
+		assert.Equal(thumbprint, proof.Thumbprint)
+
+		_, err = v.Verify(r, "")
+		assert.NotNil(err, "should not accept replayed proof")
+	})
+
+	t.Run("verify proof with other method", func(t *testing.T) {
+		v := dpop.NewVerifier()
+		r := httptest.NewRequest("GET", "http://server.example.com/token", nil)
+		r.Header.Set(dpop.Header, newProof(t, key, claims))
+
+		_, err := v.Verify(r, "")
+		assert.NotNil(err)
+	})
+
+	t.Run("verify proof with access token", func(t *testing.T) {
+		v := dpop.NewVerifier()
+		c := dpop.Claims{
+			ID:              "2",
+			HTTPMethod:      "GET",
+			HTTPURI:         "http://server.example.com/userinfo",
+			IssuedAt:        time.Now().Unix(),
+			AccessTokenHash: dpop.AccessTokenHash("token"),
+		}
+		r := httptest.NewRequest("GET", "http://server.example.com/userinfo?a=b", nil)
+		r.Header.Set(dpop.Header, newProof(t, key, c))
+
+		_, err := v.Verify(r, "other")
+		assert.NotNil(err, "should not accept proof for another token")
+
+		_, err = v.Verify(r, "token")
+		assert.Nil(err)
+	})
+
+	t.Run("verify proof with nonce", func(t *testing.T) {
+		v := dpop.NewVerifier(dpop.RequireNonce())
+		r := httptest.NewRequest("POST", "http://server.example.com/token", nil)
+		r.Header.Set(dpop.Header, newProof(t, key, claims))
+
+		_, err := v.Verify(r, "")
+		assert.Equal(dpop.ErrUseNonce, err)
+
+		c := claims
+		c.ID = "3"
+		c.Nonce, err = v.Nonce()
+		assert.Nil(err)
+		r.Header.Set(dpop.Header, newProof(t, key, c))
+		_, err = v.Verify(r, "")
+		assert.Nil(err)
+	})
+
+	t.Run("verify stale proof", func(t *testing.T) {
+		v := dpop.NewVerifier()
+		c := claims
+		c.IssuedAt = time.Now().Add(-time.Hour).Unix()
+		r := httptest.NewRequest("POST", "http://server.example.com/token", nil)
+		r.Header.Set(dpop.Header, newProof(t, key, c))
+
+		_, err := v.Verify(r, "")
+		assert.NotNil(err)
+	})
+}
@@ -22,8 +22,21 @@ import (
 // openssl req -new -x509 -sha256 -key server.key -out server.crt -days 3650
 // Set the FQDN as localhost:8080
 
+// TLSOption configures the tls server.
+type TLSOption func(cfg *tls.Config)
+
+// RequestClientCert requests a certificate from the client, without requiring
+// it. The certificate is not verified during the handshake, since
+// self-signed certificates are allowed too, and must be verified by the
+// handlers that use it.
+func RequestClientCert() TLSOption {
+	return func(cfg *tls.Config) {
+		cfg.ClientAuth = tls.RequestClientCert
+	}
+}
+
 // NewTLS returns a server with graceful shutdown.
-func NewTLS(port int, r http.Handler, tlsCert, tlsKey string) <-chan struct{} {
+func NewTLS(port int, r http.Handler, tlsCert, tlsKey string, opts ...TLSOption) <-chan struct{} {
 	cfg := &tls.Config{
 		MinVersion:               tls.VersionTLS12,
 		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
@@ -35,6 +48,9 @@ func NewTLS(port int, r http.Handler, tlsCert, tlsKey string) <-chan struct{} {
 		//         tls.TLS_RSA_WITH_AES_256_CBC_SHA,
 		// },
 	}
+	for _, o := range opts {
+		o(cfg)
+	}
 
 	srv := http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
@@ -1,9 +1,12 @@
 package html5
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"sync"
 )
 
@@ -11,41 +14,223 @@ import (
 type Template struct {
 	once      *sync.Once
 	datadir   string
+	locales   []string
+	displays  []string
+	catalogs  map[string]Catalog
 	templates map[string]*template.Template
 }
 
+// Option represents the option for the Template.
+type Option func(*Template)
+
+// Locales sets the locales supported by the Template. The first locale is
+// the default locale.
+func Locales(locales ...string) Option {
+	return func(h *Template) {
+		h.locales = locales
+	}
+}
+
+// Displays sets the displays that have their own template variants.
+func Displays(displays ...string) Option {
+	return func(h *Template) {
+		h.displays = displays
+	}
+}
+
 // New returns a new Template struct.
-func New(datadir string) *Template {
-	return &Template{
+func New(datadir string, opts ...Option) *Template {
+	h := &Template{
 		once:      new(sync.Once),
 		datadir:   datadir,
+		locales:   []string{"en"},
+		catalogs:  make(map[string]Catalog),
 		templates: make(map[string]*template.Template),
 	}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+type renderOptions struct {
+	locale  string
+	display string
+	status  int
+}
+
+// RenderOption represents the option when rendering a template.
+type RenderOption func(*renderOptions)
+
+// Locale renders the template for the given locale. The locale should be one
+// that is returned by Negotiate.
+func Locale(locale string) RenderOption {
+	return func(o *renderOptions) {
+		o.locale = locale
+	}
+}
+
+// Display renders the variant of the template for the given display, e.g.
+// "login.popup" for the display popup. The default template is rendered if
+// the variant does not exist.
+func Display(display string) RenderOption {
+	return func(o *renderOptions) {
+		o.display = display
+	}
+}
+
+// Status renders the template with the given status code instead of 200.
+func Status(code int) RenderOption {
+	return func(o *renderOptions) {
+		o.status = code
+	}
 }
 
 // Render renders the html output with the given data.
-func (h Template) Render(w http.ResponseWriter, name string, data interface{}) {
-	t, ok := h.templates[name]
+func (h Template) Render(w http.ResponseWriter, name string, data interface{}, opts ...RenderOption) {
+	o := renderOptions{locale: h.DefaultLocale()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t, ok := h.lookup(o.locale, name, o.display)
 	if !ok {
 		msg := fmt.Sprintf("renderError: template with the name %s does not exist", name)
 		http.Error(w, msg, http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", o.locale)
+	if o.status != 0 {
+		w.WriteHeader(o.status)
+	}
 	t.Execute(w, data)
 }
 
+// DefaultLocale returns the default locale of the templates.
+func (h Template) DefaultLocale() string {
+	return h.locales[0]
+}
+
+// Negotiate returns the best supported locale, based on the ui_locales
+// parameter first, then the Accept-Language header, and lastly the default
+// locale.
+func (h Template) Negotiate(uiLocales, acceptLanguage string) string {
+	return Negotiate(h.locales, uiLocales, acceptLanguage)
+}
+
+// Translate returns the message in the catalog of the given locale, or the
+// default locale if the message does not exist.
+func (h Template) Translate(locale, key string) string {
+	if msg, ok := h.catalogs[locale][key]; ok {
+		return msg
+	}
+	if msg, ok := h.catalogs[h.DefaultLocale()][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// lookup returns the template for the locale and display, falling back to
+// the default locale and then the default display.
+func (h Template) lookup(locale, name, display string) (*template.Template, bool) {
+	var names []string
+	if display != "" {
+		names = append(names, variant(name, display))
+	}
+	names = append(names, name)
+	for _, n := range names {
+		if t, ok := h.templates[h.key(locale, n)]; ok {
+			return t, true
+		}
+		if t, ok := h.templates[h.key(h.DefaultLocale(), n)]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func variant(name, display string) string {
+	return fmt.Sprintf("%s.%s", name, display)
+}
+
+func (h *Template) key(locale, name string) string {
+	return fmt.Sprintf("%s/%s", locale, name)
+}
+
 func (h *Template) path(f string) string {
 	return fmt.Sprintf("%s/%s.tmpl", h.datadir, f)
 }
 
-// Load loads the file from the base path.
+// localePath returns the path of the template for the given locale, or the
+// default template if the locale does not override it.
+func (h *Template) localePath(locale, f string) string {
+	p := fmt.Sprintf("%s/%s/%s.tmpl", h.datadir, locale, f)
+	if _, err := os.Stat(p); err != nil {
+		return h.path(f)
+	}
+	return p
+}
+
+func (h *Template) funcs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(key string) string {
+			return h.Translate(locale, key)
+		},
+		"locale": func() string {
+			return locale
+		},
+	}
+}
+
+// Load loads the file from the base path. Variants of the file for other
+// displays, e.g. "login.popup.tmpl", are loaded if they exist.
 func (h *Template) Load(files ...string) {
 	h.once.Do(func() {
-		layout := template.Must(template.New("base").ParseFiles(h.path("base")))
-		for _, f := range files {
-			clone := template.Must(layout.Clone())
-			h.templates[f] = template.Must(clone.ParseFiles(h.path(f)))
+		files = h.withVariants(files)
+		for _, locale := range h.locales {
+			catalog, err := loadCatalog(fmt.Sprintf("%s/locales/%s.json", h.datadir, locale))
+			if err != nil {
+				panic(err)
+			}
+			h.catalogs[locale] = catalog
+
+			layout := template.Must(template.New("base").Funcs(h.funcs(locale)).ParseFiles(h.localePath(locale, "base")))
+			for _, f := range files {
+				clone := template.Must(layout.Clone())
+				h.templates[h.key(locale, f)] = template.Must(clone.ParseFiles(h.localePath(locale, f)))
+			}
 		}
 	})
 }
+
+func (h *Template) withVariants(files []string) []string {
+	var result []string
+	for _, f := range files {
+		result = append(result, f)
+		for _, display := range h.displays {
+			v := variant(f, display)
+			if _, err := os.Stat(h.path(v)); err == nil {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+// Catalog represents the translated messages of a locale.
+type Catalog map[string]string
+
+// loadCatalog loads the message catalog from the json file. A missing catalog
+// is treated as empty.
+func loadCatalog(path string) (Catalog, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Catalog{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c Catalog
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
@@ -0,0 +1,88 @@
+package html5
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Negotiate returns the supported locale that best matches the space
+// separated ui_locales, followed by the Accept-Language header. The first
+// supported locale is returned if nothing matches.
+func Negotiate(supported []string, uiLocales, acceptLanguage string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+	tags := append(strings.Fields(uiLocales), parseAcceptLanguage(acceptLanguage)...)
+	for _, tag := range tags {
+		if locale, ok := match(supported, tag); ok {
+			return locale
+		}
+	}
+	return supported[0]
+}
+
+// match returns the supported locale for the language tag. An exact match
+// is preferred, then a match of the primary language subtag, e.g. "ja-JP"
+// matches "ja".
+func match(supported []string, tag string) (string, bool) {
+	for _, s := range supported {
+		if strings.EqualFold(s, tag) {
+			return s, true
+		}
+	}
+	base := primary(tag)
+	for _, s := range supported {
+		if strings.EqualFold(primary(s), base) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+func primary(tag string) string {
+	if i := strings.IndexAny(tag, "-_"); i > 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// parseAcceptLanguage returns the language tags in the Accept-Language
+// header, ordered by their quality value.
+func parseAcceptLanguage(header string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		l := language{tag: part, quality: 1}
+		if i := strings.Index(part, ";"); i > 0 {
+			l.tag = strings.TrimSpace(part[:i])
+			params := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(params, "q=") {
+				q, err := strconv.ParseFloat(params[2:], 64)
+				if err != nil {
+					continue
+				}
+				l.quality = q
+			}
+		}
+		if l.tag == "*" || l.quality <= 0 {
+			continue
+		}
+		languages = append(languages, l)
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+	tags := make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.tag
+	}
+	return tags
+}
@@ -0,0 +1,29 @@
+package html5_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-openid/pkg/html5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	assert := assert.New(t)
+
+	supported := []string{"en", "ja", "ms"}
+	tests := []struct {
+		uiLocales, acceptLanguage, locale string
+	}{
+		{"", "", "en"},
+		{"ja", "ms", "ja"},
+		{"fr-CA ja-JP", "", "ja"},
+		{"fr", "ms;q=0.5, ja;q=0.8", "ja"},
+		{"", "fr, ms-MY;q=0.9", "ms"},
+		{"de", "fr", "en"},
+	}
+
+	for _, tt := range tests {
+		locale := html5.Negotiate(supported, tt.uiLocales, tt.acceptLanguage)
+		assert.Equal(tt.locale, locale, "should negotiate %q, %q", tt.uiLocales, tt.acceptLanguage)
+	}
+}
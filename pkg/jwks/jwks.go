@@ -0,0 +1,135 @@
+// Package jwks manages the asymmetric signing keys of the provider, and
+// publishes their public keys as a JSON Web Key Set.
+package jwks
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"sync"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// Algorithm is the signing algorithm of the keys.
+const Algorithm = "RS256"
+
+// Key is a signing key of the provider.
+type Key struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+}
+
+// NewKey returns the key with its id set to the JWK thumbprint of the public
+// key.
+func NewKey(priv *rsa.PrivateKey) (Key, error) {
+	jwk := jose.JSONWebKey{Key: &priv.PublicKey}
+	b, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{
+		ID:         base64.RawURLEncoding.EncodeToString(b),
+		PrivateKey: priv,
+	}, nil
+}
+
+// GenerateKey returns a new 2048 bit RSA key.
+func GenerateKey() (Key, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return Key{}, err
+	}
+	return NewKey(priv)
+}
+
+// ParsePEM parses the PKCS #1 or PKCS #8 encoded RSA private key.
+func ParsePEM(b []byte) (Key, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return Key{}, errors.New("key is not pem encoded")
+	}
+	if priv, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewKey(priv)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return Key{}, err
+	}
+	priv, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return Key{}, errors.New("key is not an rsa private key")
+	}
+	return NewKey(priv)
+}
+
+// KeySet holds the signing keys of the provider. The latest key signs the
+// tokens, while the previous keys stay published so that the tokens they
+// signed can still be verified.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []Key
+}
+
+// New returns a key set with the given keys, where the last key is the
+// signing key.
+func New(keys ...Key) *KeySet {
+	return &KeySet{keys: keys}
+}
+
+// DefaultKeySet is the key set of the provider. A key is generated when the
+// first token is signed if none was added when the server was assembled.
+var DefaultKeySet = New()
+
+// Add adds the key, which becomes the signing key.
+func (s *KeySet) Add(key Key) {
+	s.mu.Lock()
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+}
+
+// SigningKey returns the key that signs the tokens.
+func (s *KeySet) SigningKey() (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) == 0 {
+		key, err := GenerateKey()
+		if err != nil {
+			return Key{}, err
+		}
+		s.keys = append(s.keys, key)
+	}
+	return s.keys[len(s.keys)-1], nil
+}
+
+// PublicKey returns the public key with the given id.
+func (s *KeySet) PublicKey(kid string) (*rsa.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, key := range s.keys {
+		if key.ID == kid {
+			return &key.PrivateKey.PublicKey, true
+		}
+	}
+	return nil, false
+}
+
+// JSONWebKeySet returns the public keys of the set.
+func (s *KeySet) JSONWebKeySet() jose.JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var set jose.JSONWebKeySet
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       &key.PrivateKey.PublicKey,
+			KeyID:     key.ID,
+			Algorithm: Algorithm,
+			Use:       "sig",
+		})
+	}
+	return set
+}
@@ -0,0 +1,66 @@
+package jwks
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySet(t *testing.T) {
+	assert := assert.New(t)
+
+	s := New()
+	first, err := s.SigningKey()
+	assert.Nil(err, "should generate a key")
+	again, err := s.SigningKey()
+	assert.Nil(err)
+	assert.Equal(first.ID, again.ID, "should keep the generated key")
+
+	second, err := GenerateKey()
+	assert.Nil(err)
+	s.Add(second)
+
+	key, err := s.SigningKey()
+	assert.Nil(err)
+	assert.Equal(second.ID, key.ID, "should sign with the latest key")
+
+	pub, ok := s.PublicKey(first.ID)
+	assert.True(ok, "should keep the previous keys")
+	assert.Equal(&first.PrivateKey.PublicKey, pub)
+
+	b, err := json.Marshal(s.JSONWebKeySet())
+	assert.Nil(err)
+	var set struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	assert.Nil(json.Unmarshal(b, &set))
+	assert.Len(set.Keys, 2)
+	assert.Equal(first.ID, set.Keys[0]["kid"])
+	assert.Equal("RS256", set.Keys[0]["alg"])
+	assert.Nil(set.Keys[0]["d"], "should not publish the private key")
+}
+
+func TestParsePEM(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := GenerateKey()
+	assert.Nil(err)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key.PrivateKey)})
+	parsed, err := ParsePEM(pkcs1)
+	assert.Nil(err)
+	assert.Equal(key.ID, parsed.ID)
+
+	b, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	assert.Nil(err)
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b})
+	parsed, err = ParsePEM(pkcs8)
+	assert.Nil(err)
+	assert.Equal(key.ID, parsed.ID)
+
+	_, err = ParsePEM([]byte("key"))
+	assert.NotNil(err)
+}
@@ -0,0 +1,137 @@
+// Package mtls implements the helpers for mutual-TLS client authentication
+// and certificate-bound access tokens as described in RFC 8705.
+package mtls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrMissingCertificate  = errors.New("client certificate is required")
+	ErrCertificateMismatch = errors.New("client certificate does not match")
+)
+
+// Subject represents the expected subject of the client certificate for the
+// tls_client_auth method. Only one of the fields is set.
+type Subject struct {
+	DN       string
+	SANDNS   string
+	SANURI   string
+	SANIP    string
+	SANEmail string
+}
+
+// Certificate returns the client certificate of the request, if any.
+func Certificate(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, false
+	}
+	return r.TLS.PeerCertificates[0], true
+}
+
+// Intermediates returns the certificates that the client sent after its own
+// certificate, which may link it to a trusted root.
+func Intermediates(r *http.Request) []*x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) < 2 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[1:]
+}
+
+// Thumbprint returns the base64url encoded SHA-256 thumbprint of the DER
+// encoded certificate, which is the value of the x5t#S256 confirmation
+// method.
+func Thumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyChain verifies that the certificate is issued by one of the roots,
+// either directly or through the intermediates sent by the client.
+func VerifyChain(cert *x509.Certificate, intermediates []*x509.Certificate, roots *x509.CertPool) error {
+	if roots == nil {
+		return errors.New("no client certificate authorities are configured")
+	}
+	pool := x509.NewCertPool()
+	for _, c := range intermediates {
+		pool.AddCert(c)
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: pool,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// MatchSubject returns an error if the certificate does not match the
+// expected subject.
+func MatchSubject(cert *x509.Certificate, s Subject) error {
+	var ok bool
+	switch {
+	case s.DN != "":
+		ok = cert.Subject.String() == s.DN
+	case s.SANDNS != "":
+		ok = contains(cert.DNSNames, s.SANDNS)
+	case s.SANURI != "":
+		for _, u := range cert.URIs {
+			if u.String() == s.SANURI {
+				ok = true
+			}
+		}
+	case s.SANIP != "":
+		for _, ip := range cert.IPAddresses {
+			if ip.String() == s.SANIP {
+				ok = true
+			}
+		}
+	case s.SANEmail != "":
+		ok = contains(cert.EmailAddresses, s.SANEmail)
+	default:
+		return errors.New("client certificate subject is not registered")
+	}
+	if !ok {
+		return ErrCertificateMismatch
+	}
+	return nil
+}
+
+// MatchCertificates returns an error if the certificate is not one of the
+// registered certificates. This is used for self_signed_tls_client_auth,
+// where the certificates are registered through the client's jwks.
+func MatchCertificates(cert *x509.Certificate, registered []*x509.Certificate) error {
+	for _, c := range registered {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return nil
+		}
+	}
+	return ErrCertificateMismatch
+}
+
+// VerifyBinding checks that the certificate of the request matches the
+// thumbprint the access token is bound to. Resource servers should call this
+// for every token with a x5t#S256 confirmation.
+func VerifyBinding(r *http.Request, thumbprint string) error {
+	cert, ok := Certificate(r)
+	if !ok {
+		return ErrMissingCertificate
+	}
+	if Thumbprint(cert) != thumbprint {
+		return ErrCertificateMismatch
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
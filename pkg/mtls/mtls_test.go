@@ -0,0 +1,121 @@
+package mtls_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-openid/pkg/mtls"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCertificate(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestClientCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	ca, caKey := newCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	client, _ := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client", Organization: []string{"Example"}},
+		DNSNames:     []string{"client.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	other, _ := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil, nil)
+
+	intermediate, intermediateKey := newCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(4),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, ca, caKey)
+	leaf, _ := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(5),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, intermediate, intermediateKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	t.Run("verify chain", func(t *testing.T) {
+		assert.Nil(mtls.VerifyChain(client, nil, roots))
+		assert.NotNil(mtls.VerifyChain(other, nil, roots), "should not accept self-signed certificate")
+		assert.Nil(mtls.VerifyChain(leaf, []*x509.Certificate{intermediate}, roots), "should build the chain with the intermediates")
+		assert.NotNil(mtls.VerifyChain(leaf, nil, roots), "should require the intermediates")
+	})
+
+	t.Run("intermediates", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/token", nil)
+		assert.Nil(mtls.Intermediates(r))
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, intermediate}}
+		assert.Equal([]*x509.Certificate{intermediate}, mtls.Intermediates(r))
+	})
+
+	t.Run("match subject", func(t *testing.T) {
+		assert.Nil(mtls.MatchSubject(client, mtls.Subject{DN: "CN=client,O=Example"}))
+		assert.Nil(mtls.MatchSubject(client, mtls.Subject{SANDNS: "client.example.com"}))
+		assert.Equal(mtls.ErrCertificateMismatch, mtls.MatchSubject(client, mtls.Subject{SANDNS: "other.example.com"}))
+	})
+
+	t.Run("match certificates", func(t *testing.T) {
+		assert.Nil(mtls.MatchCertificates(other, []*x509.Certificate{client, other}))
+		assert.Equal(mtls.ErrCertificateMismatch, mtls.MatchCertificates(other, []*x509.Certificate{client}))
+	})
+
+	t.Run("verify binding", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/userinfo", nil)
+		assert.Equal(mtls.ErrMissingCertificate, mtls.VerifyBinding(r, mtls.Thumbprint(client)))
+
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}
+		assert.Nil(mtls.VerifyBinding(r, mtls.Thumbprint(client)))
+		assert.Equal(mtls.ErrCertificateMismatch, mtls.VerifyBinding(r, mtls.Thumbprint(other)))
+	})
+}
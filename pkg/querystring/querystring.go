@@ -28,7 +28,7 @@ func Decode(u url.Values, in interface{}) error {
 				continue
 			}
 			tagName := tags[0]
-			if strings.TrimSpace(tagName) == "" {
+			if strings.TrimSpace(tagName) == "" || tagName == "-" {
 				continue
 			}
 			field := v.FieldByName(f.Name)
@@ -44,6 +44,11 @@ func Decode(u url.Values, in interface{}) error {
 				field.SetString(val)
 			case reflect.Bool:
 				field.SetBool(val == "true")
+			case reflect.Slice:
+				// Repeated parameters are decoded into a string slice.
+				if f.Type.Elem().Kind() == reflect.String {
+					field.Set(reflect.ValueOf(append([]string(nil), u[tagName]...)))
+				}
 			}
 		}
 		return nil
@@ -71,7 +76,17 @@ func Encode(u url.Values, in interface{}) url.Values {
 				continue
 			}
 			name := tags[0]
-			if strings.TrimSpace(name) == "" {
+			if strings.TrimSpace(name) == "" || name == "-" {
+				continue
+			}
+			// Each element of a string slice is added as a repeated
+			// parameter.
+			if f.Type.Kind() == reflect.Slice {
+				if f.Type.Elem().Kind() == reflect.String {
+					for _, s := range v.Interface().([]string) {
+						u.Add(name, s)
+					}
+				}
 				continue
 			}
 			z := reflect.Zero(v.Type())
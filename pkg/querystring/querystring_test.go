@@ -38,3 +38,32 @@ func TestDecode(t *testing.T) {
 	assert.Equal(10, o.Age, "should decode age")
 	assert.Equal(true, o.IsMarried, "should decode is_married")
 }
+
+func TestRepeatedParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	type request struct {
+		Resource []string `json:"resource,omitempty"`
+	}
+	u, _ := url.ParseQuery("resource=https%3A%2F%2Fa.example.com&resource=https%3A%2F%2Fb.example.com")
+
+	var o request
+	assert.Nil(Decode(u, &o))
+	assert.Equal([]string{"https://a.example.com", "https://b.example.com"}, o.Resource, "should decode repeated parameters")
+	assert.Equal(u, Encode(url.Values{}, &o), "should encode repeated parameters")
+}
+
+func TestIgnoredFields(t *testing.T) {
+	assert := assert.New(t)
+
+	type request struct {
+		Code   string `json:"code"`
+		Status int    `json:"-"`
+	}
+	u := Encode(url.Values{}, request{Code: "abc", Status: 400})
+	assert.Equal(url.Values{"code": {"abc"}}, u, "should not encode ignored fields")
+
+	var o request
+	assert.Nil(Decode(url.Values{"code": {"abc"}, "-": {"400"}}, &o))
+	assert.Equal(0, o.Status, "should not decode ignored fields")
+}
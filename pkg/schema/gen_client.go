@@ -36,7 +36,10 @@ var clientJSON = `{
 				"enum": [
 					"authorization_code",
 					"implicit",
-					"refresh_token"
+					"refresh_token",
+					"urn:ietf:params:oauth:grant-type:token-exchange",
+					"urn:ietf:params:oauth:grant-type:jwt-bearer",
+					"urn:openid:params:grant-type:ciba"
 				],
 				"default": "authorization_code"
 			}
@@ -129,6 +132,8 @@ var clientJSON = `{
 				"client_secret_basic",
 				"client_secret_jwt",
 				"private_key_jwt",
+				"tls_client_auth",
+				"self_signed_tls_client_auth",
 				"none"
 			]
 		},
@@ -147,6 +152,58 @@ var clientJSON = `{
 		"initiate_login_uri": {
 			"type": "string"
 		},
+		"tls_client_auth_subject_dn": {
+			"type": "string"
+		},
+		"tls_client_auth_san_dns": {
+			"type": "string"
+		},
+		"tls_client_auth_san_uri": {
+			"type": "string",
+			"format": "uri"
+		},
+		"tls_client_auth_san_ip": {
+			"type": "string"
+		},
+		"tls_client_auth_san_email": {
+			"type": "string",
+			"format": "email"
+		},
+		"tls_client_certificate_bound_access_tokens": {
+			"type": "boolean"
+		},
+		"dpop_bound_access_tokens": {
+			"type": "boolean"
+		},
+		"backchannel_logout_uri": {
+			"type": "string",
+			"format": "uri"
+		},
+		"backchannel_logout_session_required": {
+			"type": "boolean"
+		},
+		"backchannel_token_delivery_mode": {
+			"type": "string",
+			"enum": ["poll", "ping", "push"]
+		},
+		"backchannel_client_notification_endpoint": {
+			"type": "string",
+			"format": "uri"
+		},
+		"frontchannel_logout_uri": {
+			"type": "string",
+			"format": "uri"
+		},
+		"frontchannel_logout_session_required": {
+			"type": "boolean"
+		},
+		"post_logout_redirect_uris": {
+			"type": "array",
+			"items": {
+				"type": "string",
+				"format": "uri"
+			}
+		},
 		"request_uris": {
 			"type": "array",
 			"items": {
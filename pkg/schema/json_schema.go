@@ -0,0 +1,25 @@
+package schema
+
+import (
+	jsonschema "github.com/xeipuuv/gojsonschema"
+)
+
+// JSON represents the struct to validate data against a json schema that is
+// only known at runtime, e.g. the schemas of the registered authorization
+// details types.
+type JSON struct {
+	schema *jsonschema.Schema
+}
+
+// NewJSONValidator returns a new pointer to the JSON validator of the given
+// json schema.
+func NewJSONValidator(source string) (*JSON, error) {
+	schema, err := loadSchema(source)
+	return &JSON{schema}, err
+}
+
+// Validate validates the given data and returns the corresponding errors.
+func (j *JSON) Validate(data interface{}) (*Result, error) {
+	result, err := validate(j.schema, data)
+	return result, err
+}
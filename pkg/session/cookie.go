@@ -19,3 +19,12 @@ func NewCookie(id string, now time.Time) *http.Cookie {
 		// Secure:   true,
 	}
 }
+
+// NewBrowserStateCookie returns a new cookie for the browser state, which
+// expires together with the session cookie.
+func NewBrowserStateCookie(state string, now time.Time) *http.Cookie {
+	c := NewCookie(state, now)
+	c.Name = BrowserStateKey
+	c.HttpOnly = false
+	return c
+}
@@ -28,3 +28,13 @@ func TestCookie(t *testing.T) {
 	assert.Equal(path, cookie.Path, "should have default path set to /")
 	assert.Equal(httpOnly, cookie.HttpOnly, "should default to true for httpOnly")
 }
+
+func TestBrowserStateCookie(t *testing.T) {
+	assert := assert.New(t)
+	cookie := session.NewBrowserStateCookie("xyz", time.Unix(0, 10))
+
+	assert.Equal("obs", cookie.Name)
+	assert.Equal("xyz", cookie.Value)
+	assert.False(cookie.HttpOnly, "should be readable by the check_session_iframe")
+	assert.Equal(time.Unix(0, 10).Add(20*time.Minute), cookie.Expires, "should expire with the session cookie")
+}
@@ -1,13 +1,16 @@
 package session
 
 import (
+	"context"
 	"net/http"
+	"sync"
 	"time"
 )
 
 // Manager represents the manager that handles the session creation and
 // cleanup.
 type Manager struct {
+	mu   sync.Mutex
 	repo Repository
 }
 
@@ -47,6 +50,65 @@ func (m *Manager) SetSession(w http.ResponseWriter, userID string) {
 	m.repo.Put(s.SessionID, s)
 
 	http.SetCookie(w, c)
+	m.SetBrowserState(w)
+}
+
+// SetBrowserState sets a new browser state in the response. It must be called
+// whenever the session changes, so that the clients can detect the change.
+func (m *Manager) SetBrowserState(w http.ResponseWriter) {
+	now := time.Now().UTC()
+	http.SetCookie(w, NewBrowserStateCookie(NewBrowserState(now), now))
+}
+
+// AddClient records that the client was issued tokens in the session, so
+// that the client can be notified when the session ends.
+func (m *Manager) AddClient(sessionID, clientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, err := m.repo.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if sess.HasClient(clientID) {
+		return nil
+	}
+	sess.ClientIDs = append(sess.ClientIDs, clientID)
+	return m.repo.Put(sessionID, sess)
+}
+
+// OnExpire sets the function that is called with the sessions that are
+// deleted because they expired, e.g. to notify the clients of the session.
+func (m *Manager) OnExpire(fn func(*Session)) {
+	m.repo.OnExpire(fn)
+}
+
+// RevokeClient removes the deleted client from the sessions, so that it is
+// not notified when the sessions end.
+func (m *Manager) RevokeClient(ctx context.Context, clientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions, err := m.repo.List()
+	if err != nil {
+		return err
+	}
+	for _, sess := range sessions {
+		if !sess.HasClient(clientID) {
+			continue
+		}
+		var clientIDs []string
+		for _, id := range sess.ClientIDs {
+			if id != clientID {
+				clientIDs = append(clientIDs, id)
+			}
+		}
+		sess.ClientIDs = clientIDs
+		if err := m.repo.Put(sess.SessionID, sess); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Delete removes a session from the session store.
@@ -0,0 +1,55 @@
+package session_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alextanhongpin/go-openid/pkg/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerAddClient(t *testing.T) {
+	assert := assert.New(t)
+
+	mgr := session.NewManager()
+	rr := httptest.NewRecorder()
+	mgr.SetSession(rr, "john.doe@mail.com")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Cookie", rr.Header().Get("Set-Cookie"))
+	sess, err := mgr.GetSession(req)
+	assert.Nil(err)
+	assert.NotEqual(sess.SessionID, sess.SID, "should not expose the session id as the sid")
+
+	assert.Nil(mgr.AddClient(sess.SessionID, "1"))
+	assert.Nil(mgr.AddClient(sess.SessionID, "1"))
+	assert.Nil(mgr.AddClient(sess.SessionID, "2"))
+
+	sess, err = mgr.GetSession(req)
+	assert.Nil(err)
+	assert.Equal([]string{"1", "2"}, sess.ClientIDs, "should record each client once")
+
+	assert.NotNil(mgr.AddClient("unknown", "1"), "should fail for unknown sessions")
+}
+
+func TestManagerRevokeClient(t *testing.T) {
+	assert := assert.New(t)
+
+	mgr := session.NewManager()
+	rr := httptest.NewRecorder()
+	mgr.SetSession(rr, "john.doe@mail.com")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Cookie", rr.Header().Get("Set-Cookie"))
+	sess, err := mgr.GetSession(req)
+	assert.Nil(err)
+	assert.Nil(mgr.AddClient(sess.SessionID, "1"))
+	assert.Nil(mgr.AddClient(sess.SessionID, "2"))
+
+	assert.Nil(mgr.RevokeClient(context.Background(), "1"))
+
+	sess, err = mgr.GetSession(req)
+	assert.Nil(err)
+	assert.Equal([]string{"2"}, sess.ClientIDs, "should remove the revoked client")
+}
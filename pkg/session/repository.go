@@ -12,6 +12,8 @@ type Repository interface {
 	Close()
 	Delete(id string) error
 	Get(id string) (*Session, error)
+	List() ([]*Session, error)
+	OnExpire(fn func(*Session))
 	Open()
 	Put(id string, s *Session) error
 }
@@ -20,6 +22,10 @@ type repositoryInMemoryImpl struct {
 	batch int // The size of the keys to gather during cleanup.
 	data  map[string]*Session
 	quit  chan struct{}
+
+	// onExpire is called with the sessions that are deleted because they
+	// expired.
+	onExpire func(*Session)
 	sync.Once
 	sync.RWMutex
 }
@@ -52,10 +58,28 @@ func (r *repositoryInMemoryImpl) clean() {
 	r.RUnlock()
 
 	log.Printf("mgr: found %d expires sessions\n", len(expiredSIDs))
-	r.Lock()
 	for _, v := range expiredSIDs {
-		r.Delete(v)
+		r.expire(v)
 	}
+}
+
+// expire deletes the expired session, and notifies the listener.
+func (r *repositoryInMemoryImpl) expire(id string) {
+	r.Lock()
+	sess, exist := r.data[id]
+	delete(r.data, id)
+	onExpire := r.onExpire
+	r.Unlock()
+
+	if exist && onExpire != nil {
+		onExpire(sess)
+	}
+}
+
+// OnExpire sets the function that is called with the expired sessions.
+func (r *repositoryInMemoryImpl) OnExpire(fn func(*Session)) {
+	r.Lock()
+	r.onExpire = fn
 	r.Unlock()
 }
 
@@ -96,12 +120,26 @@ func (r *repositoryInMemoryImpl) Get(id string) (*Session, error) {
 	// Current time is greater than the expire at time (>= 0).
 	if time.Since(sess.ExpireAt) >= 0 {
 		// Passive session deletion.
-		r.Delete(id)
+		r.expire(id)
 		return nil, errors.New("session expired")
 	}
 	return sess, nil
 }
 
+// List returns the sessions that have not expired.
+func (r *repositoryInMemoryImpl) List() ([]*Session, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var result []*Session
+	for _, sess := range r.data {
+		if time.Since(sess.ExpireAt) < 0 {
+			result = append(result, sess)
+		}
+	}
+	return result, nil
+}
+
 // Delete remove the session from the storage.
 func (r *repositoryInMemoryImpl) Delete(id string) error {
 	r.Lock()
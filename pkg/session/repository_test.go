@@ -0,0 +1,30 @@
+package session_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-openid/pkg/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryOnExpire(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := session.NewInMemoryRepository()
+	var expired []*session.Session
+	repo.OnExpire(func(s *session.Session) {
+		expired = append(expired, s)
+	})
+
+	sess := session.NewSession("john.doe@mail.com")
+	sess.ExpireAt = time.Now().Add(-time.Minute)
+	assert.Nil(repo.Put(sess.SessionID, sess))
+
+	_, err := repo.Get(sess.SessionID)
+	assert.NotNil(err, "should not return the expired session")
+	_, err = repo.Get(sess.SessionID)
+	assert.NotNil(err)
+
+	assert.Equal([]*session.Session{sess}, expired, "should notify the expiry once")
+}
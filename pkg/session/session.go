@@ -1,6 +1,10 @@
 package session
 
 import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"math/rand"
 	"time"
 
@@ -10,6 +14,10 @@ import (
 // Key represents a general name for the cookie.
 const Key = "id"
 
+// BrowserStateKey is the name of the cookie that holds the browser state.
+// Unlike the session cookie, it is readable by the check_session_iframe.
+const BrowserStateKey = "obs"
+
 // Session represents the information that is tracked by the session.
 type Session struct {
 	CreatedAt time.Time
@@ -19,6 +27,13 @@ type Session struct {
 	SessionID string
 	UserAgent string
 	UserID    string
+
+	// SID identifies the session to the clients, and unlike the
+	// SessionID it is not a secret.
+	SID string
+
+	// ClientIDs are the clients that were issued tokens in the session.
+	ClientIDs []string
 }
 
 // NewSession returns a new session and cookie.
@@ -31,10 +46,41 @@ func NewSession(userID string) *Session {
 		SessionID: NewSessionID(now),
 		UserAgent: "",
 		UserID:    userID,
+		SID:       ulid.MustNew(ulid.Timestamp(now), crand.Reader).String(),
 	}
 	return sess
 }
 
+// CSRFToken returns the token that a form for the action must be submitted
+// with. It is derived from the secret session id, so that other sites cannot
+// submit the form on behalf of the end-user.
+func (s *Session) CSRFToken(action string) string {
+	mac := hmac.New(sha256.New, []byte(s.SessionID))
+	mac.Write([]byte(action))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCSRFToken returns true if the token was issued for the action in this
+// session.
+func (s *Session) VerifyCSRFToken(action, token string) bool {
+	return hmac.Equal([]byte(s.CSRFToken(action)), []byte(token))
+}
+
+// HasClient returns true if the client was issued tokens in the session.
+func (s *Session) HasClient(clientID string) bool {
+	for _, id := range s.ClientIDs {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// NewBrowserState returns a new random browser state.
+func NewBrowserState(t time.Time) string {
+	return ulid.MustNew(ulid.Timestamp(t), crand.Reader).String()
+}
+
 // NewSessionID creates a new session id from the given time.
 func NewSessionID(t time.Time) string {
 	entropy := rand.New(rand.NewSource(t.UnixNano()))
@@ -0,0 +1,21 @@
+package session_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-openid/pkg/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionCSRFToken(t *testing.T) {
+	assert := assert.New(t)
+
+	sess := session.NewSession("john.doe@mail.com")
+	other := session.NewSession("john.doe@mail.com")
+
+	token := sess.CSRFToken("client_id=1")
+	assert.True(sess.VerifyCSRFToken("client_id=1", token))
+	assert.False(sess.VerifyCSRFToken("client_id=2", token), "should be bound to the action")
+	assert.False(other.VerifyCSRFToken("client_id=1", token), "should be bound to the session")
+	assert.False(sess.VerifyCSRFToken("client_id=1", ""))
+}
@@ -14,7 +14,7 @@ type Signer struct {
 func NewSigner(secret string) *Signer {
 	return &Signer{[]byte(secret)}
 }
-func (s *Signer) Sign(claims *jwt.StandardClaims) {
+func (s *Signer) Sign(claims *jwt.StandardClaims) (string, error) {
 	return Sign(s.secret, claims)
 }
 
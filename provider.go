@@ -0,0 +1,105 @@
+package openid
+
+// ProviderMetadata represents the OpenID Provider Metadata that is returned
+// by the discovery endpoint.
+type ProviderMetadata struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint,omitempty"`
+	JwksURI                                    string   `json:"jwks_uri,omitempty"`
+	RegistrationEndpoint                       string   `json:"registration_endpoint,omitempty"`
+	IntrospectionEndpoint                      string   `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint                         string   `json:"revocation_endpoint,omitempty"`
+	EndSessionEndpoint                         string   `json:"end_session_endpoint,omitempty"`
+	CheckSessionIframe                         string   `json:"check_session_iframe,omitempty"`
+	ScopesSupported                            []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	DisplayValuesSupported                     []string `json:"display_values_supported,omitempty"`
+	ClaimsLocalesSupported                     []string `json:"claims_locales_supported,omitempty"`
+	UILocalesSupported                         []string `json:"ui_locales_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	RevocationEndpointAuthMethodsSupported     []string `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	TLSClientCertificateBoundAccessTokens      bool     `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	DPoPSigningAlgValuesSupported              []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	FrontchannelLogoutSupported                bool     `json:"frontchannel_logout_supported,omitempty"`
+	FrontchannelLogoutSessionSupported         bool     `json:"frontchannel_logout_session_supported,omitempty"`
+	BackchannelLogoutSupported                 bool     `json:"backchannel_logout_supported,omitempty"`
+	BackchannelLogoutSessionSupported          bool     `json:"backchannel_logout_session_supported,omitempty"`
+	BackchannelAuthenticationEndpoint          string   `json:"backchannel_authentication_endpoint,omitempty"`
+	BackchannelTokenDeliveryModesSupported     []string `json:"backchannel_token_delivery_modes_supported,omitempty"`
+	AuthorizationDetailsTypesSupported         []string `json:"authorization_details_types_supported,omitempty"`
+	ResponseModesSupported                     []string `json:"response_modes_supported,omitempty"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported,omitempty"`
+
+	// MTLSEndpointAliases are the endpoints that request a client
+	// certificate, which the clients use for mutual-TLS (RFC 8705).
+	MTLSEndpointAliases *MTLSEndpointAliases `json:"mtls_endpoint_aliases,omitempty"`
+}
+
+// MTLSEndpointAliases represents the endpoints of the mutual-TLS server.
+type MTLSEndpointAliases struct {
+	TokenEndpoint                     string `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                  string `json:"userinfo_endpoint,omitempty"`
+	IntrospectionEndpoint             string `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint                string `json:"revocation_endpoint,omitempty"`
+	BackchannelAuthenticationEndpoint string `json:"backchannel_authentication_endpoint,omitempty"`
+}
+
+// NewProviderMetadata returns the metadata of the provider with the given
+// issuer. The endpoints are relative to the issuer.
+func NewProviderMetadata(issuer string) *ProviderMetadata {
+	return &ProviderMetadata{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserinfoEndpoint:                  issuer + "/userinfo",
+		RegistrationEndpoint:              issuer + "/connect/register",
+		IntrospectionEndpoint:             issuer + "/introspect",
+		RevocationEndpoint:                issuer + "/revoke",
+		JwksURI:                           issuer + "/jwks.json",
+		EndSessionEndpoint:                issuer + "/end_session",
+		CheckSessionIframe:                issuer + "/check_session",
+		BackchannelAuthenticationEndpoint: issuer + "/bc-authorize",
+		ScopesSupported:                   DefaultScopeRegistry.Names(),
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", TokenExchange.String(), JWTBearer.String(), CIBA.String()},
+		SubjectTypesSupported:             []string{SubjectTypePublic, SubjectTypePairwise},
+		IDTokenSigningAlgValuesSupported:  []string{"HS256"},
+		DisplayValuesSupported:            []string{"page", "popup", "touch", "wap"},
+		ClaimsLocalesSupported:            []string{"en", "ja"},
+		UILocalesSupported:                []string{"en", "ja"},
+		// The endpoint authentication methods are listed from
+		// TokenEndpointAuthMethods so that they are always in sync.
+		TokenEndpointAuthMethodsSupported:          append([]string(nil), TokenEndpointAuthMethods...),
+		TokenEndpointAuthSigningAlgValuesSupported: []string{"HS256", "RS256", "ES256"},
+		RevocationEndpointAuthMethodsSupported:     append([]string(nil), TokenEndpointAuthMethods...),
+		TLSClientCertificateBoundAccessTokens:      true,
+		DPoPSigningAlgValuesSupported:              []string{"ES256", "ES384", "ES512", "RS256", "PS256"},
+		FrontchannelLogoutSupported:                true,
+		FrontchannelLogoutSessionSupported:         true,
+		BackchannelLogoutSupported:                 true,
+		BackchannelLogoutSessionSupported:          true,
+		BackchannelTokenDeliveryModesSupported:     append([]string(nil), CIBADeliveryModes...),
+		AuthorizationDetailsTypesSupported:         DefaultAuthorizationDetailTypes.Names(),
+		ResponseModesSupported:                     append([]string(nil), ResponseModes...),
+		AuthorizationResponseIssParameterSupported: true,
+	}
+}
+
+// SetMTLSEndpointAliases publishes the endpoints of the mutual-TLS server
+// with the given base url. The endpoints are the same as the ones relative to
+// the issuer.
+func (m *ProviderMetadata) SetMTLSEndpointAliases(base string) {
+	m.MTLSEndpointAliases = &MTLSEndpointAliases{
+		TokenEndpoint:                     base + "/token",
+		UserinfoEndpoint:                  base + "/userinfo",
+		IntrospectionEndpoint:             base + "/introspect",
+		RevocationEndpoint:                base + "/revoke",
+		BackchannelAuthenticationEndpoint: base + "/bc-authorize",
+	}
+}
@@ -0,0 +1,22 @@
+package openid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewProviderMetadata("https://server.example.com")
+	assert.Equal("https://server.example.com/token", m.TokenEndpoint)
+	assert.Equal(TokenEndpointAuthMethods, m.TokenEndpointAuthMethodsSupported)
+	assert.Equal(TokenEndpointAuthMethods, m.RevocationEndpointAuthMethodsSupported)
+	assert.Contains(m.SubjectTypesSupported, SubjectTypePairwise)
+	assert.Nil(m.MTLSEndpointAliases)
+
+	m.SetMTLSEndpointAliases("https://mtls.server.example.com")
+	assert.Equal("https://mtls.server.example.com/token", m.MTLSEndpointAliases.TokenEndpoint)
+	assert.Equal("https://mtls.server.example.com/revoke", m.MTLSEndpointAliases.RevocationEndpoint)
+}
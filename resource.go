@@ -0,0 +1,124 @@
+package openid
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Resource describes a protected API that access tokens can be issued for,
+// as identified by the resource parameter of RFC 8707.
+type Resource struct {
+	// URI is the resource indicator, which becomes the audience of the
+	// access tokens issued for the resource.
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+
+	// Scopes are the scopes that the resource accepts. Access tokens for
+	// the resource only carry these scopes. Every scope is accepted if it
+	// is empty.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// ReferenceTokens issues opaque access tokens for the resource, which
+	// the resource validates through the introspection endpoint instead
+	// of locally.
+	ReferenceTokens bool `json:"reference_tokens,omitempty"`
+}
+
+// Scope returns the part of the space separated scope that the resource
+// accepts.
+func (r *Resource) Scope(scope string) string {
+	if len(r.Scopes) == 0 {
+		return scope
+	}
+	accepted := make(map[string]bool)
+	for _, s := range r.Scopes {
+		accepted[s] = true
+	}
+	var result []string
+	for _, s := range strings.Fields(scope) {
+		if accepted[s] {
+			result = append(result, s)
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+// ResourceRegistry holds the protected APIs that the clients can request
+// access tokens for.
+type ResourceRegistry struct {
+	mu        sync.RWMutex
+	resources map[string]Resource
+}
+
+// NewResourceRegistry returns a new registry without resources.
+func NewResourceRegistry() *ResourceRegistry {
+	return &ResourceRegistry{
+		resources: make(map[string]Resource),
+	}
+}
+
+// DefaultResourceRegistry is the registry that the protected APIs are
+// registered to when the server is assembled.
+var DefaultResourceRegistry = NewResourceRegistry()
+
+// Register adds the resource to the registry. The resource indicator must be
+// an absolute uri without a fragment.
+func (r *ResourceRegistry) Register(res Resource) error {
+	if err := validateResourceURI(res.URI); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exist := r.resources[res.URI]; exist {
+		return fmt.Errorf("resource %q is already registered", res.URI)
+	}
+	r.resources[res.URI] = res
+	return nil
+}
+
+// Lookup returns the resource with the given indicator.
+func (r *ResourceRegistry) Lookup(uri string) (Resource, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res, ok := r.resources[uri]
+	return res, ok
+}
+
+// URIs returns the sorted indicators of the registered resources.
+func (r *ResourceRegistry) URIs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	uris := make([]string, 0, len(r.resources))
+	for uri := range r.resources {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// Validate checks that every resource indicator is registered.
+func (r *ResourceRegistry) Validate(uris []string) error {
+	for _, uri := range uris {
+		if err := validateResourceURI(uri); err != nil {
+			return ErrInvalidTarget.WithDescription(err.Error())
+		}
+		if _, ok := r.Lookup(uri); !ok {
+			return ErrInvalidTarget.WithDescription(fmt.Sprintf("resource %q is not registered", uri))
+		}
+	}
+	return nil
+}
+
+func validateResourceURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("resource %q is not an absolute uri", uri)
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return fmt.Errorf("resource %q must not contain a fragment", uri)
+	}
+	return nil
+}
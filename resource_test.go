@@ -0,0 +1,32 @@
+package openid_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	r := openid.NewResourceRegistry()
+	assert.Nil(r.Register(openid.Resource{
+		URI:    "https://api.example.com/orders",
+		Scopes: []string{"orders:read"},
+	}))
+	assert.NotNil(r.Register(openid.Resource{URI: "https://api.example.com/orders"}), "should reject duplicate resources")
+	assert.NotNil(r.Register(openid.Resource{URI: "/orders"}), "should reject relative uris")
+	assert.NotNil(r.Register(openid.Resource{URI: "https://api.example.com/#orders"}), "should reject fragments")
+
+	assert.Nil(r.Validate([]string{"https://api.example.com/orders"}))
+	err := r.Validate([]string{"https://api.example.com/orders", "https://api.example.com/users"})
+	assert.Equal("invalid_target", err.(*openid.ErrorJSON).Code)
+
+	res, ok := r.Lookup("https://api.example.com/orders")
+	assert.True(ok)
+	assert.Equal("orders:read", res.Scope("openid orders:read orders:write"), "should only keep the accepted scopes")
+
+	all := openid.Resource{URI: "https://api.example.com/all"}
+	assert.Equal("openid orders:write", all.Scope("openid orders:write"))
+}
@@ -0,0 +1,25 @@
+package openid
+
+// Response modes of the authorization response.
+const (
+	ResponseModeQuery    = "query"
+	ResponseModeFragment = "fragment"
+	ResponseModeFormPost = "form_post"
+)
+
+// ResponseModes are the supported response modes.
+var ResponseModes = []string{ResponseModeQuery, ResponseModeFragment, ResponseModeFormPost}
+
+// IsValidResponseMode returns true if the response mode is supported. The
+// default response mode of the response type is used when it is empty.
+func IsValidResponseMode(mode string) bool {
+	if mode == "" {
+		return true
+	}
+	for _, m := range ResponseModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
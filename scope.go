@@ -1,52 +1,297 @@
 package openid
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
 
 // -- scopes
 
-// By using bits, we save on a lot of checking. For example, we would not face
-// the issue of duplicate scopes "email email". Also, it's possible to check if
-// the scopes are an exact match, or contains one of the scope in the list.
+// ScopeDefinition describes a scope that the clients can request.
+type ScopeDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// ConsentText is shown to the end-user on the consent screen.
+	ConsentText string `json:"consent_text,omitempty"`
+
+	// Claims are the claims that are released when the scope is granted.
+	Claims []string `json:"claims,omitempty"`
+
+	// Clients restricts the scope to the given clients. Every client can
+	// request the scope if it is empty.
+	Clients []string `json:"clients,omitempty"`
+}
+
+// allows returns true if the client can request the scope.
+func (d *ScopeDefinition) allows(clientID string) bool {
+	if len(d.Clients) == 0 {
+		return true
+	}
+	for _, id := range d.Clients {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// StandardScopes are the scopes defined by OpenID Connect, which are always
+// registered.
+var StandardScopes = []ScopeDefinition{
+	{Name: "openid", Description: "OpenID Connect authentication"},
+	{Name: "profile", Description: "Default profile claims", Claims: []string{
+		"name", "family_name", "given_name", "middle_name", "nickname",
+		"preferred_username", "profile", "picture", "website", "gender",
+		"birthdate", "zoneinfo", "locale", "updated_at",
+	}},
+	{Name: "email", Description: "Email address", Claims: []string{"email", "email_verified"}},
+	{Name: "address", Description: "Postal address", Claims: []string{"address"}},
+	{Name: "phone", Description: "Phone number", Claims: []string{"phone_number", "phone_number_verified"}},
+	{Name: "offline_access", Description: "Refresh tokens for offline access"},
+}
+
+// ScopeRegistry holds the scopes that can be requested. Each registered scope
+// is assigned a bit, so that the set operations of Scope stay cheap.
+type ScopeRegistry struct {
+	mu    sync.RWMutex
+	defs  []ScopeDefinition
+	index map[string]int
+}
+
+// NewScopeRegistry returns a new registry with the standard scopes.
+func NewScopeRegistry() *ScopeRegistry {
+	r := &ScopeRegistry{
+		index: make(map[string]int),
+	}
+	for _, def := range StandardScopes {
+		if err := r.Register(def); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// DefaultScopeRegistry is the registry used by NewScope. Custom scopes are
+// registered to it when the server is assembled.
+var DefaultScopeRegistry = NewScopeRegistry()
+
+// Register adds the scope to the registry.
+func (r *ScopeRegistry) Register(def ScopeDefinition) error {
+	if !isScopeToken(def.Name) {
+		return fmt.Errorf("scope %q is invalid", def.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exist := r.index[def.Name]; exist {
+		return fmt.Errorf("scope %q is already registered", def.Name)
+	}
+	r.index[def.Name] = len(r.defs)
+	r.defs = append(r.defs, def)
+	return nil
+}
+
+// Lookup returns the definition of the scope.
+func (r *ScopeRegistry) Lookup(name string) (ScopeDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, exist := r.index[name]
+	if !exist {
+		return ScopeDefinition{}, false
+	}
+	return r.defs[i], true
+}
+
+// Names returns the names of the registered scopes.
+func (r *ScopeRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.defs))
+	for i, def := range r.defs {
+		names[i] = def.Name
+	}
+	return names
+}
+
+// Parse returns the set of the space separated scopes, and the scopes that
+// are not registered.
+func (r *ScopeRegistry) Parse(scope string) (Scope, []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s := Scope{registry: r}
+	var unknown []string
+	for _, name := range strings.Fields(scope) {
+		i, exist := r.index[name]
+		if !exist {
+			unknown = append(unknown, name)
+			continue
+		}
+		s = s.with(i)
+	}
+	return s, unknown
+}
+
+// Allowed returns the scopes of the set that the client can request.
+func (r *ScopeRegistry) Allowed(clientID string, s Scope) Scope {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := Scope{registry: r}
+	s.each(func(i int) {
+		if r.defs[i].allows(clientID) {
+			res = res.with(i)
+		}
+	})
+	return res
+}
+
+// Claims returns the claims that are released by the scopes of the set.
+func (r *ScopeRegistry) Claims(s Scope) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var claims []string
+	s.each(func(i int) {
+		claims = append(claims, r.defs[i].Claims...)
+	})
+	return claims
+}
 
-// Scope represents the openid scope.
-type Scope int
+// Scope represents a set of registered scopes.
+type Scope struct {
+	registry *ScopeRegistry
+	bits     []uint64
+}
 
-const (
-	ScopeNone Scope = 1 << iota
-	ScopeAddress
-	ScopeEmail
-	ScopeOpenID
-	ScopePhone
-	ScopeProfile
+// Standard scopes of the default registry. The standard scopes are registered
+// first, so they have the same bits in every registry.
+var (
+	ScopeOpenID, _        = DefaultScopeRegistry.Parse("openid")
+	ScopeProfile, _       = DefaultScopeRegistry.Parse("profile")
+	ScopeEmail, _         = DefaultScopeRegistry.Parse("email")
+	ScopeAddress, _       = DefaultScopeRegistry.Parse("address")
+	ScopePhone, _         = DefaultScopeRegistry.Parse("phone")
+	ScopeOfflineAccess, _ = DefaultScopeRegistry.Parse("offline_access")
 )
 
-// Has returns true if the given scope is in the list of scope. Can have multiple scope.
+// NewScope returns the set of the space separated scopes in the default
+// registry. Scopes that are not registered are dropped.
+func NewScope(scope string) Scope {
+	s, _ := DefaultScopeRegistry.Parse(scope)
+	return s
+}
+
+func (s Scope) with(i int) Scope {
+	bits := make([]uint64, len(s.bits))
+	copy(bits, s.bits)
+	for len(bits) <= i/64 {
+		bits = append(bits, 0)
+	}
+	bits[i/64] |= 1 << uint(i%64)
+	return Scope{s.registry, bits}
+}
+
+func (s Scope) each(fn func(i int)) {
+	for w, word := range s.bits {
+		for b := 0; b < 64; b++ {
+			if word&(1<<uint(b)) != 0 {
+				fn(w*64 + b)
+			}
+		}
+	}
+}
+
+func (s Scope) word(i int) uint64 {
+	if i < len(s.bits) {
+		return s.bits[i]
+	}
+	return 0
+}
+
+func (s Scope) combine(ss Scope, op func(a, b uint64) uint64) Scope {
+	n := len(s.bits)
+	if len(ss.bits) > n {
+		n = len(ss.bits)
+	}
+	bits := make([]uint64, n)
+	for i := range bits {
+		bits[i] = op(s.word(i), ss.word(i))
+	}
+	r := s.registry
+	if r == nil {
+		r = ss.registry
+	}
+	return Scope{r, bits}
+}
+
+// Has returns true if any of the given scopes is in the set.
 func (s Scope) Has(ss Scope) bool {
-	return s&ss != 0
+	return !s.Intersect(ss).IsEmpty()
 }
 
-// Is returns true if the scope is exactly one of the defined scope.
-func (s Scope) Is(ss Scope) bool {
-	return s&ss == s|ss
+// Contains returns true if all of the given scopes are in the set.
+func (s Scope) Contains(ss Scope) bool {
+	return ss.Difference(s).IsEmpty()
 }
 
-var scopemap = map[string]Scope{
-	"address": ScopeAddress,
-	"email":   ScopeEmail,
-	"openid":  ScopeOpenID,
-	"phone":   ScopePhone,
-	"profile": ScopeProfile,
+// Is returns true if the set contains exactly the given scopes.
+func (s Scope) Is(ss Scope) bool {
+	return s.Contains(ss) && ss.Contains(s)
 }
 
-func NewScope(scope string) (i Scope) {
-	scopes := strings.Split(scope, " ")
-	for _, s := range scopes {
-		if v, exist := scopemap[s]; exist {
-			i |= v
+// IsEmpty returns true if the set has no scopes.
+func (s Scope) IsEmpty() bool {
+	for _, word := range s.bits {
+		if word != 0 {
+			return false
 		}
 	}
-	if i == 0 {
-		i |= ScopeNone
+	return true
+}
+
+// Union returns the scopes in either set.
+func (s Scope) Union(ss Scope) Scope {
+	return s.combine(ss, func(a, b uint64) uint64 { return a | b })
+}
+
+// Intersect returns the scopes in both sets.
+func (s Scope) Intersect(ss Scope) Scope {
+	return s.combine(ss, func(a, b uint64) uint64 { return a & b })
+}
+
+// Difference returns the scopes that are not in the given set.
+func (s Scope) Difference(ss Scope) Scope {
+	return s.combine(ss, func(a, b uint64) uint64 { return a &^ b })
+}
+
+// Names returns the names of the scopes in the order of registration.
+func (s Scope) Names() []string {
+	if s.registry == nil {
+		return nil
+	}
+	s.registry.mu.RLock()
+	defer s.registry.mu.RUnlock()
+	var names []string
+	s.each(func(i int) {
+		names = append(names, s.registry.defs[i].Name)
+	})
+	return names
+}
+
+// String returns the space separated scopes.
+func (s Scope) String() string {
+	return strings.Join(s.Names(), " ")
+}
+
+// isScopeToken returns true if the name only contains the characters that
+// are allowed in a scope token.
+func isScopeToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c < 0x21 || c > 0x7e || c == '"' || c == '\\' {
+			return false
+		}
 	}
-	return
+	return true
 }
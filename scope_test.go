@@ -0,0 +1,61 @@
+package openid_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopeRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	r := openid.NewScopeRegistry()
+	assert.Nil(r.Register(openid.ScopeDefinition{
+		Name:        "orders:read",
+		ConsentText: "Read your orders",
+		Claims:      []string{"customer_id"},
+		Clients:     []string{"shop"},
+	}))
+	assert.NotNil(r.Register(openid.ScopeDefinition{Name: "openid"}), "should reject duplicate scopes")
+	assert.NotNil(r.Register(openid.ScopeDefinition{Name: "a b"}), "should reject invalid scope tokens")
+
+	t.Run("parse custom scopes", func(t *testing.T) {
+		s, unknown := r.Parse("orders:read openid unknown openid")
+		assert.Equal([]string{"unknown"}, unknown)
+		assert.Equal("openid orders:read", s.String(), "should normalize the order and duplicates")
+		assert.True(s.Has(openid.ScopeOpenID))
+	})
+
+	t.Run("set operations", func(t *testing.T) {
+		a, _ := r.Parse("openid email orders:read")
+		b, _ := r.Parse("openid email")
+		assert.True(a.Contains(b))
+		assert.False(b.Contains(a))
+		assert.Equal("orders:read", a.Difference(b).String())
+		assert.Equal("openid email", a.Intersect(b).String())
+		assert.True(a.Union(b).Is(a))
+		assert.True(a.Difference(a).IsEmpty())
+	})
+
+	t.Run("per-client allow-list", func(t *testing.T) {
+		s, _ := r.Parse("openid orders:read")
+		assert.Equal("openid orders:read", r.Allowed("shop", s).String())
+		assert.Equal("openid", r.Allowed("other", s).String())
+	})
+
+	t.Run("claims of scopes", func(t *testing.T) {
+		s, _ := r.Parse("email orders:read")
+		assert.Equal([]string{"email", "email_verified", "customer_id"}, r.Claims(s))
+	})
+
+	t.Run("more than 64 scopes", func(t *testing.T) {
+		r := openid.NewScopeRegistry()
+		for i := 0; i < 100; i++ {
+			assert.Nil(r.Register(openid.ScopeDefinition{Name: string(rune('A'+i%26)) + string(rune('0'+i/26))}))
+		}
+		s, unknown := r.Parse("Z2 A0 openid")
+		assert.Empty(unknown)
+		assert.Equal("openid A0 Z2", s.String())
+	})
+}
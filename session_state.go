@@ -0,0 +1,36 @@
+package openid
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+)
+
+// SessionState returns the session_state of OpenID Connect Session Management.
+// The check_session_iframe computes the same value from the browser state
+// cookie, and reports a change when they differ.
+func SessionState(clientID, origin, browserState, salt string) string {
+	h := sha256.Sum256([]byte(clientID + " " + origin + " " + browserState + " " + salt))
+	return base64.RawURLEncoding.EncodeToString(h[:]) + "." + salt
+}
+
+// NewSessionState returns the session_state with a random salt for the client
+// that receives the authentication response at the redirect uri.
+func NewSessionState(clientID, redirectURI, browserState string) (string, error) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.New("redirect_uri must be an absolute uri")
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	origin := u.Scheme + "://" + u.Host
+	return SessionState(clientID, origin, browserState, hex.EncodeToString(b)), nil
+}
@@ -0,0 +1,25 @@
+package openid_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionState(t *testing.T) {
+	assert := assert.New(t)
+
+	state, err := openid.NewSessionState("1", "https://client.example.com/cb?x=1", "obs")
+	assert.Nil(err)
+
+	i := strings.LastIndex(state, ".")
+	assert.True(i > 0, "should end with the salt")
+	salt := state[i+1:]
+	assert.Equal(state, openid.SessionState("1", "https://client.example.com", "obs", salt), "should hash the origin of the redirect_uri")
+	assert.NotEqual(state, openid.SessionState("1", "https://client.example.com", "changed", salt), "should change with the browser state")
+
+	_, err = openid.NewSessionState("1", "/cb", "obs")
+	assert.NotNil(err)
+}
@@ -0,0 +1,26 @@
+package openid
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Subject types supported by the provider.
+const (
+	SubjectTypePublic   = "public"
+	SubjectTypePairwise = "pairwise"
+)
+
+// PairwiseSubject returns the pairwise subject identifier for the local user
+// id. The same sector identifier and local id always results in the same
+// subject, but the subject cannot be correlated across sectors without
+// knowing the salt.
+//
+//	sub = base64url(sha256(sector_identifier || local_id || salt))
+func PairwiseSubject(sectorIdentifier, localID string, salt []byte) string {
+	h := sha256.New()
+	h.Write([]byte(sectorIdentifier))
+	h.Write([]byte(localID))
+	h.Write(salt)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
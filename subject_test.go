@@ -0,0 +1,28 @@
+package openid_test
+
+import (
+	"testing"
+
+	openid "github.com/alextanhongpin/go-openid"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairwiseSubject(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		salt    = []byte("salt")
+		localID = "bd9n1ls3n9g26rp1jv4g"
+	)
+
+	a := openid.PairwiseSubject("client.example.com", localID, salt)
+	b := openid.PairwiseSubject("client.example.com", localID, salt)
+	c := openid.PairwiseSubject("other.example.com", localID, salt)
+	d := openid.PairwiseSubject("client.example.com", localID, []byte("pepper"))
+
+	assert.Equal(a, b, "should be stable for the same sector")
+	assert.NotEqual(a, c, "should differ across sectors")
+	assert.NotEqual(a, d, "should differ with a different salt")
+	assert.NotEqual(localID, a, "should not expose the local id")
+}
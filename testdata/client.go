@@ -1,6 +1,8 @@
 package testdata
 
 import (
+	"context"
+
 	openid "github.com/alextanhongpin/go-openid"
 	"github.com/alextanhongpin/go-openid/service"
 	"github.com/stretchr/testify/mock"
@@ -15,8 +17,17 @@ func NewClientService() *clientService {
 	return &clientService{}
 }
 
-func (c *clientService) Register(req *openid.Client) (*openid.Client, error) {
-	args := c.Called(req)
+func (c *clientService) Register(ctx context.Context, req *openid.Client) (*openid.Client, error) {
+	args := c.Called(ctx, req)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.(*openid.Client), args.Error(1)
+}
+
+func (c *clientService) Read(ctx context.Context, clientID, registrationAccessToken string) (*openid.Client, error) {
+	args := c.Called(ctx, clientID, registrationAccessToken)
 	res := args.Get(0)
 	if res == nil {
 		return nil, args.Error(1)
@@ -24,11 +35,16 @@ func (c *clientService) Register(req *openid.Client) (*openid.Client, error) {
 	return res.(*openid.Client), args.Error(1)
 }
 
-func (c *clientService) Read(clientID string) (*openid.Client, error) {
-	args := c.Called(clientID)
+func (c *clientService) Update(ctx context.Context, clientID, registrationAccessToken string, req *openid.Client, rotateSecret bool) (*openid.Client, error) {
+	args := c.Called(ctx, clientID, registrationAccessToken, req, rotateSecret)
 	res := args.Get(0)
 	if res == nil {
 		return nil, args.Error(1)
 	}
 	return res.(*openid.Client), args.Error(1)
 }
+
+func (c *clientService) Delete(ctx context.Context, clientID, registrationAccessToken string) error {
+	args := c.Called(ctx, clientID, registrationAccessToken)
+	return args.Error(0)
+}
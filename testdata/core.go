@@ -22,6 +22,16 @@ func (c *coreService) PreAuthenticate(req *openid.AuthenticationRequest) error {
 	return args.Error(0)
 }
 
+func (c *coreService) ValidateRedirectURI(req *openid.AuthenticationRequest) error {
+	args := c.Called(req)
+	return args.Error(0)
+}
+
+func (c *coreService) CheckSession(ctx context.Context, req *openid.AuthenticationRequest) error {
+	args := c.Called(ctx, req)
+	return args.Error(0)
+}
+
 func (c *coreService) Authenticate(ctx context.Context, req *openid.AuthenticationRequest) (*openid.AuthenticationResponse, error) {
 	args := c.Called(ctx, req)
 	res := args.Get(0)
@@ -39,3 +49,56 @@ func (c *coreService) Token(ctx context.Context, req *openid.AccessTokenRequest)
 	}
 	return res.(*openid.AccessTokenResponse), args.Error(1)
 }
+
+func (c *coreService) UserInfo(ctx context.Context, accessToken string) (*openid.UserInfo, error) {
+	args := c.Called(ctx, accessToken)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.(*openid.UserInfo), args.Error(1)
+}
+
+func (c *coreService) Introspect(ctx context.Context, token string, creds openid.ClientCredentials) (*openid.IntrospectionResponse, error) {
+	args := c.Called(ctx, token, creds)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.(*openid.IntrospectionResponse), args.Error(1)
+}
+
+func (c *coreService) Revoke(ctx context.Context, token string, creds openid.ClientCredentials) error {
+	args := c.Called(ctx, token, creds)
+	return args.Error(0)
+}
+
+func (c *coreService) EndSession(ctx context.Context, req *openid.EndSessionRequest) (*openid.EndSessionResponse, error) {
+	args := c.Called(ctx, req)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.(*openid.EndSessionResponse), args.Error(1)
+}
+
+func (c *coreService) FrontChannelLogout(ctx context.Context, sid string, clientIDs []string) []string {
+	args := c.Called(ctx, sid, clientIDs)
+	uris, _ := args.Get(0).([]string)
+	return uris
+}
+
+func (c *coreService) BackChannelLogout(ctx context.Context, userID, sid string, clientIDs []string) []openid.BackChannelLogoutRequest {
+	args := c.Called(ctx, userID, sid, clientIDs)
+	reqs, _ := args.Get(0).([]openid.BackChannelLogoutRequest)
+	return reqs
+}
+
+func (c *coreService) Consent(ctx context.Context, req *openid.AuthenticationRequest) (*openid.ConsentPrompt, error) {
+	args := c.Called(ctx, req)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.(*openid.ConsentPrompt), args.Error(1)
+}
@@ -1,7 +1,7 @@
 package openid
 
 import (
-	"errors"
+	"time"
 )
 
 const (
@@ -17,7 +17,30 @@ type AccessTokenRequest struct {
 	GrantType   string `json:"grant_type,omitempty"`
 	Code        string `json:"code,omitempty"`
 	RedirectURI string `json:"redirect_uri,omitempty"`
-	ClientID    string `json:"client_id,omitempty"`
+
+	// RefreshToken and Scope are used by the refresh_token grant.
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+
+	// Resource is the resource indicator of the protected API that the
+	// access token is issued for.
+	Resource []string `json:"resource,omitempty"`
+
+	// The token exchange grant exchanges the subject token, optionally
+	// presented together with the token of the actor, for a new token.
+	Audience           []string `json:"audience,omitempty"`
+	SubjectToken       string   `json:"subject_token,omitempty"`
+	SubjectTokenType   string   `json:"subject_token_type,omitempty"`
+	ActorToken         string   `json:"actor_token,omitempty"`
+	ActorTokenType     string   `json:"actor_token_type,omitempty"`
+	RequestedTokenType string   `json:"requested_token_type,omitempty"`
+
+	// Assertion is the signed assertion of the JWT bearer grant.
+	Assertion string `json:"assertion,omitempty"`
+
+	// AuthReqID identifies the backchannel authentication request.
+	AuthReqID string `json:"auth_req_id,omitempty"`
+	ClientCredentials
 }
 
 // Validate performs an initial validation on the required field.
@@ -47,6 +70,36 @@ type AccessTokenResponse struct {
 	ExpiresIn    int64  `json:"expires_in,omitempty"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+
+	// IssuedTokenType is the type of the token issued by the token
+	// exchange grant.
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+
+	// AuthorizationDetails are the fine-grained permissions that the
+	// access token was granted.
+	AuthorizationDetails []AuthorizationDetail `json:"authorization_details,omitempty"`
+}
+
+// ReferenceToken is an opaque access token, which refers to the access token
+// claims kept by the provider.
+type ReferenceToken struct {
+	ID        string
+	ClientID  string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// Expired returns true if the reference token has expired.
+func (r *ReferenceToken) Expired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
+
+// Confirmation represents the cnf claim that binds a token to a key held by
+// the client.
+type Confirmation struct {
+	X5tS256 string `json:"x5t#S256,omitempty"` // SHA-256 thumbprint of the client certificate.
+	JKT     string `json:"jkt,omitempty"`      // SHA-256 thumbprint of the DPoP public key.
 }
 
 // RefreshTokenRequest represents the refresh token request.
@@ -60,11 +113,11 @@ type RefreshTokenRequest struct {
 
 // Validate checks for required fields.
 func (r *RefreshTokenRequest) Validate() error {
-	if r.GrantType != "refresh_token" {
-		return errors.New("invalid_grant_type")
+	if !RefreshToken.Equal(r.GrantType) {
+		return ErrUnsupportedGrantType
 	}
 	if r.RefreshToken == "" {
-		return errors.New("invalid_request")
+		return ErrInvalidRequest.WithDescription("refresh_token is required")
 	}
 	if r.Scope == "" {
 		// TODO: Handle validation for scope.
@@ -0,0 +1,90 @@
+package openid
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Token type identifiers of the token exchange grant.
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeIDToken     = "urn:ietf:params:oauth:token-type:id_token"
+)
+
+// Actor represents the act claim, which identifies the party that acts on
+// behalf of the subject. The nested actor is the prior actor in a chain of
+// delegation.
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientID string `json:"client_id,omitempty"`
+	Actor    *Actor `json:"act,omitempty"`
+}
+
+// TokenExchangePolicy describes the tokens that a client can obtain through
+// the token exchange grant.
+type TokenExchangePolicy struct {
+	ClientID string `json:"client_id"`
+
+	// Audiences are the resource indicators that the client can exchange
+	// tokens for.
+	Audiences []string `json:"audiences"`
+
+	// Scopes are the scopes that the exchanged tokens can carry. Every
+	// scope of the subject token is allowed if it is empty.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Impersonation allows the client to exchange tokens without an actor
+	// token, which issues tokens for the subject without an act claim.
+	Impersonation bool `json:"impersonation,omitempty"`
+}
+
+// AllowsAudience returns true if the client can exchange tokens for the
+// audience.
+func (p *TokenExchangePolicy) AllowsAudience(aud string) bool {
+	for _, a := range p.Audiences {
+		if a == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenExchangePolicies holds the token exchange policies of the clients.
+// Clients without a policy cannot use the token exchange grant.
+type TokenExchangePolicies struct {
+	mu       sync.RWMutex
+	policies map[string]TokenExchangePolicy
+}
+
+// NewTokenExchangePolicies returns a new registry without policies.
+func NewTokenExchangePolicies() *TokenExchangePolicies {
+	return &TokenExchangePolicies{
+		policies: make(map[string]TokenExchangePolicy),
+	}
+}
+
+// DefaultTokenExchangePolicies is the registry that the policies are
+// registered to when the server is assembled.
+var DefaultTokenExchangePolicies = NewTokenExchangePolicies()
+
+// Register adds the policy of the client.
+func (t *TokenExchangePolicies) Register(p TokenExchangePolicy) error {
+	if p.ClientID == "" {
+		return fmt.Errorf("token exchange policy requires a client_id")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, exist := t.policies[p.ClientID]; exist {
+		return fmt.Errorf("token exchange policy of client %q is already registered", p.ClientID)
+	}
+	t.policies[p.ClientID] = p
+	return nil
+}
+
+// Lookup returns the policy of the client.
+func (t *TokenExchangePolicies) Lookup(clientID string) (TokenExchangePolicy, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	p, ok := t.policies[clientID]
+	return p, ok
+}
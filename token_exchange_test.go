@@ -0,0 +1,28 @@
+package openid_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenExchangePolicies(t *testing.T) {
+	assert := assert.New(t)
+
+	p := openid.NewTokenExchangePolicies()
+	assert.Nil(p.Register(openid.TokenExchangePolicy{
+		ClientID:  "svc",
+		Audiences: []string{"https://orders.example.com"},
+	}))
+	assert.NotNil(p.Register(openid.TokenExchangePolicy{ClientID: "svc"}), "should reject duplicate policies")
+	assert.NotNil(p.Register(openid.TokenExchangePolicy{}), "should require a client_id")
+
+	policy, ok := p.Lookup("svc")
+	assert.True(ok)
+	assert.True(policy.AllowsAudience("https://orders.example.com"))
+	assert.False(policy.AllowsAudience("https://billing.example.com"))
+
+	_, ok = p.Lookup("app")
+	assert.False(ok)
+}
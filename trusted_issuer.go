@@ -0,0 +1,100 @@
+package openid
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Subject mappings of the trusted issuers.
+const (
+	// SubjectMappingID uses the subject of the assertion as the local
+	// user id.
+	SubjectMappingID = "id"
+
+	// SubjectMappingEmail finds the local user by the email address in
+	// the subject of the assertion.
+	SubjectMappingEmail = "email"
+)
+
+// TrustedIssuer describes a partner whose signed assertions can be exchanged
+// for access tokens through the JWT bearer grant.
+type TrustedIssuer struct {
+	Issuer string `json:"issuer"`
+
+	// Jwks or JwksURI are the public keys that sign the assertions.
+	Jwks    json.RawMessage `json:"jwks,omitempty"`
+	JwksURI string          `json:"jwks_uri,omitempty"`
+
+	// Clients are the clients that can present the assertions of the
+	// issuer.
+	Clients []string `json:"clients"`
+
+	// Scopes are the scopes that the access tokens can carry.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Subjects maps the subjects of the assertions to the local user ids.
+	// Subjects that are not mapped are resolved with the SubjectMapping,
+	// and are rejected if it is empty.
+	Subjects       map[string]string `json:"subjects,omitempty"`
+	SubjectMapping string            `json:"subject_mapping,omitempty"`
+}
+
+// AllowsClient returns true if the client can present the assertions of the
+// issuer.
+func (t *TrustedIssuer) AllowsClient(clientID string) bool {
+	for _, id := range t.Clients {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedIssuers holds the issuers whose assertions are accepted by the JWT
+// bearer grant.
+type TrustedIssuers struct {
+	mu      sync.RWMutex
+	issuers map[string]TrustedIssuer
+}
+
+// NewTrustedIssuers returns a new registry without issuers.
+func NewTrustedIssuers() *TrustedIssuers {
+	return &TrustedIssuers{
+		issuers: make(map[string]TrustedIssuer),
+	}
+}
+
+// DefaultTrustedIssuers is the registry that the issuers are registered to
+// when the server is assembled.
+var DefaultTrustedIssuers = NewTrustedIssuers()
+
+// Register adds the trusted issuer.
+func (t *TrustedIssuers) Register(issuer TrustedIssuer) error {
+	if issuer.Issuer == "" {
+		return fmt.Errorf("trusted issuer requires an issuer")
+	}
+	if len(issuer.Jwks) == 0 && issuer.JwksURI == "" {
+		return fmt.Errorf("trusted issuer %q requires jwks or jwks_uri", issuer.Issuer)
+	}
+	switch issuer.SubjectMapping {
+	case "", SubjectMappingID, SubjectMappingEmail:
+	default:
+		return fmt.Errorf("subject_mapping %q is not supported", issuer.SubjectMapping)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, exist := t.issuers[issuer.Issuer]; exist {
+		return fmt.Errorf("trusted issuer %q is already registered", issuer.Issuer)
+	}
+	t.issuers[issuer.Issuer] = issuer
+	return nil
+}
+
+// Lookup returns the trusted issuer with the given issuer identifier.
+func (t *TrustedIssuers) Lookup(issuer string) (TrustedIssuer, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	i, ok := t.issuers[issuer]
+	return i, ok
+}
@@ -0,0 +1,34 @@
+package openid_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-openid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedIssuers(t *testing.T) {
+	assert := assert.New(t)
+
+	r := openid.NewTrustedIssuers()
+	assert.Nil(r.Register(openid.TrustedIssuer{
+		Issuer:  "https://idp.partner.com",
+		JwksURI: "https://idp.partner.com/jwks.json",
+		Clients: []string{"partner"},
+	}))
+	assert.NotNil(r.Register(openid.TrustedIssuer{
+		Issuer:  "https://idp.partner.com",
+		JwksURI: "https://idp.partner.com/jwks.json",
+	}), "should reject duplicate issuers")
+	assert.NotNil(r.Register(openid.TrustedIssuer{Issuer: "https://idp.other.com"}), "should require keys")
+	assert.NotNil(r.Register(openid.TrustedIssuer{
+		Issuer:         "https://idp.other.com",
+		JwksURI:        "https://idp.other.com/jwks.json",
+		SubjectMapping: "phone",
+	}), "should reject unknown subject mappings")
+
+	issuer, ok := r.Lookup("https://idp.partner.com")
+	assert.True(ok)
+	assert.True(issuer.AllowsClient("partner"))
+	assert.False(issuer.AllowsClient("app"))
+}
@@ -16,15 +16,26 @@ type Authenticator interface {
 // that the End User be authenticated by the Authorization Server.
 
 type AuthenticationRequest struct {
-	AcrValues    string `json:"acr_values,omitempty"`
-	ClientID     string `json:"client_id,omitempty"`
-	Display      string `json:"display,omitempty"`
-	IDTokenHint  string `json:"id_token_hint,omitempty"`
-	LoginHint    string `json:"login_hint,omitempty"`
-	MaxAge       int64  `json:"max_age,omitempty"`
-	Nonce        string `json:"nonce,omitempty"`
-	Prompt       string `json:"prompt,omitempty"`
-	RedirectURI  string `json:"redirect_uri,omitempty"`
+	AcrValues     string `json:"acr_values,omitempty"`
+	Claims        string `json:"claims,omitempty"`
+	ClaimsLocales string `json:"claims_locales,omitempty"`
+	ClientID      string `json:"client_id,omitempty"`
+	Display       string `json:"display,omitempty"`
+	IDTokenHint   string `json:"id_token_hint,omitempty"`
+	LoginHint     string `json:"login_hint,omitempty"`
+	MaxAge        int64  `json:"max_age,omitempty"`
+	Nonce         string `json:"nonce,omitempty"`
+	Prompt        string `json:"prompt,omitempty"`
+	RedirectURI   string `json:"redirect_uri,omitempty"`
+
+	// Resource are the resource indicators of the protected APIs that the
+	// client wants to access.
+	Resource []string `json:"resource,omitempty"`
+
+	// AuthorizationDetails is the json array of the fine-grained
+	// permissions that the client requests.
+	AuthorizationDetails string `json:"authorization_details,omitempty"`
+
 	ResponseMode string `json:"response_mode,omitempty"`
 	ResponseType string `json:"response_type,omitempty"`
 	Scope        string `json:"scope,omitempty"`
@@ -42,7 +53,7 @@ func (a *AuthenticationRequest) GetResponseType() ResponseType {
 	return openid.NewResponseType(a.ResponseType)
 }
 
-// GetScope returns the scope as bitwise int.
+// GetScope returns the scope as a set of the registered scopes.
 func (a *AuthenticationRequest) GetScope() Scope {
 	return openid.NewScope(a.Scope)
 }
@@ -56,8 +67,9 @@ func (a *AuthenticationRequest) FromQueryString(u url.Values) error {
 // returned from the OP's Authorization Endpoint in response to the
 // Authorization Request message sent by the RP.
 type AuthenticationResponse struct {
-	Code  string `json:"code,omitempty"`
-	State string `json:"state,omitempty"`
+	Code         string `json:"code,omitempty"`
+	State        string `json:"state,omitempty"`
+	SessionState string `json:"session_state,omitempty"`
 }
 
 // ToQueryString converts the response struct into url.Values.
@@ -1,11 +1,20 @@
 package openid
 
-import "github.com/alextanhongpin/passwd"
+import (
+	"sort"
+	"strings"
+
+	"github.com/alextanhongpin/passwd"
+)
 
 // User represents the user struct.
 type User struct {
 	hashedPassword string
 	ID             string `json:"id,omitempty"`
+
+	// LocalizedClaims contains the claims in other languages and scripts,
+	// keyed by the claim name and language tag, e.g. "name#ja-Kana-JP".
+	LocalizedClaims map[string]string `json:"-"`
 	Address
 	Email
 	Phone
@@ -42,3 +51,48 @@ func (u *User) ToIDToken() *IDToken {
 
 	return idToken
 }
+
+// ClaimsForLocales returns the localized claims that best match the given
+// locales, in order of preference. For each claim, only one language tag is
+// returned: a tag equal to the locale is preferred over a tag that only
+// shares its primary language, and the tags are otherwise compared in sorted
+// order, so that the result does not depend on the map order.
+func (u *User) ClaimsForLocales(locales []string) map[string]interface{} {
+	keys := make([]string, 0, len(u.LocalizedClaims))
+	for key := range u.LocalizedClaims {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	claims := make(map[string]interface{})
+	matched := make(map[string]bool)
+	for _, locale := range locales {
+		for _, exact := range []bool{true, false} {
+			for _, key := range keys {
+				i := strings.Index(key, "#")
+				if i < 0 {
+					continue
+				}
+				name, tag := key[:i], key[i+1:]
+				if matched[name] || !matchLanguageTag(tag, locale, exact) {
+					continue
+				}
+				matched[name] = true
+				claims[key] = u.LocalizedClaims[key]
+			}
+		}
+	}
+	return claims
+}
+
+// -- helpers
+
+// matchLanguageTag returns true if the tag matches the locale exactly. Unless
+// exact is set, it also returns true if the locale only specifies the primary
+// language of the tag, e.g. the locale "ja" matches the tag "ja-Kana-JP".
+func matchLanguageTag(tag, locale string, exact bool) bool {
+	if strings.EqualFold(tag, locale) {
+		return true
+	}
+	return !exact && strings.HasPrefix(strings.ToLower(tag), strings.ToLower(locale)+"-")
+}
@@ -0,0 +1,33 @@
+package openid_test
+
+import (
+	"testing"
+
+	openid "github.com/alextanhongpin/go-openid"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserClaimsForLocales(t *testing.T) {
+	assert := assert.New(t)
+
+	user := &openid.User{
+		LocalizedClaims: map[string]string{
+			"name#ja-Kana-JP": "ヤマダタロウ",
+			"name#ja-Hani-JP": "山田太郎",
+			"name#en":         "Taro Yamada",
+		},
+	}
+
+	for i := 0; i < 20; i++ {
+		claims := user.ClaimsForLocales([]string{"ja"})
+		assert.Equal(map[string]interface{}{"name#ja-Hani-JP": "山田太郎"}, claims, "should match the same tag every time")
+	}
+
+	user.LocalizedClaims["name#JA"] = "やまだたろう"
+	claims := user.ClaimsForLocales([]string{"ja"})
+	assert.Equal(map[string]interface{}{"name#JA": "やまだたろう"}, claims, "should prefer the exact tag")
+
+	claims = user.ClaimsForLocales([]string{"fr", "en", "ja"})
+	assert.Equal(map[string]interface{}{"name#en": "Taro Yamada"}, claims, "should follow the order of preference")
+}
@@ -0,0 +1,76 @@
+package openid
+
+import "encoding/json"
+
+// UserInfo represents the claims about the authenticated end-user returned by
+// the userinfo endpoint.
+type UserInfo struct {
+	Subject string   `json:"sub"`
+	Address *Address `json:"address,omitempty"`
+	Email
+	Phone
+	Profile
+
+	// Claims are the additional claims of the claims providers.
+	Claims map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON merges the additional claims into the userinfo claims. The
+// additional claims cannot override the existing claims.
+func (u *UserInfo) MarshalJSON() ([]byte, error) {
+	type userInfo UserInfo
+	b, err := json.Marshal((*userInfo)(u))
+	if err != nil {
+		return nil, err
+	}
+	return mergeClaims(b, u.Claims)
+}
+
+// NewUserInfo returns the claims of the user with the given subject
+// identifier, which may differ from the local user id for pairwise clients.
+func NewUserInfo(sub string, user *User) *UserInfo {
+	u := user.Clone()
+	info := UserInfo{
+		Subject: sub,
+		Email:   u.Email,
+		Phone:   u.Phone,
+		Profile: u.Profile,
+	}
+	if u.Address != (Address{}) {
+		info.Address = &u.Address
+	}
+	return &info
+}
+
+// IntrospectionResponse represents the response of the token introspection
+// endpoint.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+
+	Cnf   *Confirmation `json:"cnf,omitempty"`
+	Actor *Actor        `json:"act,omitempty"`
+
+	AuthorizationDetails []AuthorizationDetail `json:"authorization_details,omitempty"`
+
+	// Claims are the additional claims of the claims providers.
+	Claims map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON merges the additional claims into the introspection response.
+// The additional claims cannot override the existing claims.
+func (i *IntrospectionResponse) MarshalJSON() ([]byte, error) {
+	type introspectionResponse IntrospectionResponse
+	b, err := json.Marshal((*introspectionResponse)(i))
+	if err != nil {
+		return nil, err
+	}
+	return mergeClaims(b, i.Claims)
+}